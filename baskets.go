@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
@@ -12,24 +14,97 @@ import (
 
 const toMs = int64(time.Millisecond) / int64(time.Nanosecond)
 
-// DoNotForwardHeader indicates whether request can (0) or cannot (1) be forwarded
+// DoNotForwardHeader indicates whether request can (0) or cannot (1) be forwarded, the header is respected in
+// incoming requests while forwarding loops are detected with hop count and "Via" header
 const DoNotForwardHeader = "X-Do-Not-Forward"
 
 // BasketConfig describes single basket configuration.
 type BasketConfig struct {
-	ForwardURL    string `json:"forward_url"`
-	ProxyResponse bool   `json:"proxy_response"`
-	InsecureTLS   bool   `json:"insecure_tls"`
-	ExpandPath    bool   `json:"expand_path"`
-	Capacity      int    `json:"capacity"`
+	ForwardURL     string            `json:"forward_url"`
+	ProxyResponse  bool              `json:"proxy_response"`
+	StreamProxy    bool              `json:"stream_proxy,omitempty"`
+	WebSocket      bool              `json:"websocket,omitempty"`
+	Recording      string            `json:"recording,omitempty"`
+	InsecureTLS    bool              `json:"insecure_tls"`
+	ExpandPath     bool              `json:"expand_path"`
+	ForwardFilters []ForwardFilter   `json:"forward_filters,omitempty"`
+	ForwardTargets []ForwardTarget   `json:"forward_targets,omitempty"`
+	Retry          *RetryPolicy      `json:"retry,omitempty"`
+	HTTPClient     *HTTPClientConfig `json:"http_client,omitempty"`
+	Capacity       int               `json:"capacity"`
+	Validation     *BasketValidation `json:"validation,omitempty"`
+	CORS           *CORSConfig       `json:"cors,omitempty"`
+}
+
+// CORSConfig describes Cross-Origin Resource Sharing (CORS) settings of basket.
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins,omitempty"`
+	AllowedMethods   []string `json:"allowed_methods,omitempty"`
+	AllowedHeaders   []string `json:"allowed_headers,omitempty"`
+	ExposedHeaders   []string `json:"exposed_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAge           int      `json:"max_age,omitempty"`
+	SkipPreflight    bool     `json:"skip_preflight,omitempty"`
+}
+
+// BasketValidation describes validation of HTTP requests collected by basket.
+type BasketValidation struct {
+	Schema  *JSONSchema `json:"schema,omitempty"`
+	Methods []string    `json:"methods,omitempty"`
+	Reject  bool        `json:"reject,omitempty"`
 }
 
 // ResponseConfig describes response that is generates by service upon HTTP request sent to a basket.
 type ResponseConfig struct {
-	Status     int         `json:"status"`
-	Headers    http.Header `json:"headers"`
-	Body       string      `json:"body"`
-	IsTemplate bool        `json:"is_template"`
+	Status         int                `json:"status"`
+	StatusTemplate string             `json:"status_template,omitempty"`
+	Headers        http.Header        `json:"headers"`
+	Body           string             `json:"body"`
+	IsTemplate     bool               `json:"is_template"`
+	Echo           string             `json:"echo,omitempty"`
+	Script         string             `json:"script,omitempty"`
+	Delay          *ResponseDelay     `json:"delay,omitempty"`
+	Faults         []ResponseFault    `json:"faults,omitempty"`
+	Payload        []byte             `json:"payload,omitempty"`
+	PayloadType    string             `json:"payload_type,omitempty"`
+	PayloadName    string             `json:"payload_name,omitempty"`
+	PayloadSize    int                `json:"payload_size,omitempty"`
+	Validation     *RequestValidation `json:"validation,omitempty"`
+}
+
+// RequestValidation describes constraints of HTTP requests accepted by basket, e.g. imported from OpenAPI document.
+type RequestValidation struct {
+	Operation    string             `json:"operation,omitempty"`
+	Parameters   []RequestParameter `json:"parameters,omitempty"`
+	ContentTypes []string           `json:"content_types,omitempty"`
+	BodyRequired bool               `json:"body_required,omitempty"`
+	Body         *JSONSchema        `json:"body,omitempty"`
+}
+
+// RequestParameter describes expected parameter of HTTP request.
+type RequestParameter struct {
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required,omitempty"`
+	Schema   *JSONSchema `json:"schema,omitempty"`
+}
+
+// ResponseDelay describes latency (in milliseconds) that is applied before basket response is sent.
+type ResponseDelay struct {
+	Distribution string `json:"distribution"`
+	Value        int    `json:"value,omitempty"`
+	Min          int    `json:"min,omitempty"`
+	Max          int    `json:"max,omitempty"`
+	StdDev       int    `json:"stddev,omitempty"`
+}
+
+// ResponseFault describes failure that is injected into basket response with configured probability.
+type ResponseFault struct {
+	Probability float64 `json:"probability"`
+	Type        string  `json:"type"`
+	Status      int     `json:"status,omitempty"`
+	ChunkSize   int     `json:"chunk_size,omitempty"`
+	Interval    int     `json:"interval,omitempty"`
 }
 
 // BasketAuth describes basket authentication response that is sent when new basket is created.
@@ -39,13 +114,25 @@ type BasketAuth struct {
 
 // RequestData describes collected request data.
 type RequestData struct {
-	Date          int64       `json:"date"`
-	Header        http.Header `json:"headers"`
-	ContentLength int64       `json:"content_length"`
-	Body          string      `json:"body"`
-	Method        string      `json:"method"`
-	Path          string      `json:"path"`
-	Query         string      `json:"query"`
+	ID            string        `json:"id,omitempty"`
+	Date          int64         `json:"date"`
+	Header        http.Header   `json:"headers"`
+	ContentLength int64         `json:"content_length"`
+	Body          string        `json:"body"`
+	Method        string        `json:"method"`
+	Path          string        `json:"path"`
+	Query         string        `json:"query"`
+	Truncated     bool          `json:"truncated,omitempty"`
+	Violations    []string      `json:"violations,omitempty"`
+	Response      *ResponseData `json:"response,omitempty"`
+}
+
+// ResponseData describes response of forward target that was proxied back to the client of collected request.
+type ResponseData struct {
+	Status    int         `json:"status"`
+	Header    http.Header `json:"headers"`
+	Body      string      `json:"body"`
+	Truncated bool        `json:"truncated,omitempty"`
 }
 
 // RequestsPage describes a page with collected requests.
@@ -104,6 +191,28 @@ type Basket interface {
 	GetResponse(method string) *ResponseConfig
 	SetResponse(method string, response ResponseConfig)
 
+	GetPathResponses(method string) map[string]*ResponseConfig
+	SetPathResponse(method string, path string, response ResponseConfig)
+	DeletePathResponse(method string, path string)
+
+	GetResponsePayload(method string, path string) []byte
+
+	GetState() map[string]string
+	GetStateValue(key string) (string, bool)
+	SetStateValue(key string, value string) error
+	DeleteStateValue(key string)
+	ClearState()
+
+	GetForwardRetries(dead bool) []*ForwardRetry
+	GetForwardRetry(id string) *ForwardRetry
+	SaveForwardRetry(retry *ForwardRetry) error
+	DeleteForwardRetry(id string)
+
+	GetWebSocketSessions() []*WebSocketSession
+	GetWebSocketSession(id string) *WebSocketSession
+	SaveWebSocketSession(session *WebSocketSession) error
+	DeleteWebSocketSession(id string)
+
 	Add(req *http.Request) *RequestData
 	Clear()
 
@@ -131,7 +240,13 @@ type BasketsDatabase interface {
 func ToRequestData(req *http.Request) *RequestData {
 	data := new(RequestData)
 
-	data.Date = time.Now().UnixNano() / toMs
+	if peeked := peekedRequest(req); peeked != nil {
+		data.ID = peeked.ID
+		data.Date = peeked.Date
+	} else {
+		data.ID = GenerateID()
+		data.Date = time.Now().UnixNano() / toMs
+	}
 	data.Header = make(http.Header)
 	for k, v := range req.Header {
 		data.Header[k] = v
@@ -144,32 +259,55 @@ func ToRequestData(req *http.Request) *RequestData {
 
 	body, _ := ioutil.ReadAll(req.Body)
 	data.Body = string(body)
+	data.Truncated = isTruncatedBody(req)
+	data.Violations = requestViolations(req)
+	data.Response = proxiedResponse(req)
 
 	return data
 }
 
-// Forward forwards request data to specified URL
+// Forward forwards request data to forward URL of basket
 func (req *RequestData) Forward(client *http.Client, config BasketConfig, basket string) (*http.Response, error) {
-	forwardURL, err := url.ParseRequestURI(config.ForwardURL)
+	return req.ForwardTo(context.Background(), client,
+		ForwardTarget{URL: config.ForwardURL, ExpandPath: config.ExpandPath}, basket)
+}
+
+// ForwardTo forwards request data to specified target, the forwarding is cancelled along with the given context
+func (req *RequestData) ForwardTo(ctx context.Context, client *http.Client, target ForwardTarget,
+	basket string) (*http.Response, error) {
+	forwardReq, err := req.newForwardRequest(ctx, target, basket,
+		strings.NewReader(rewriteForwardBody(req.Body, target)))
 	if err != nil {
-		return nil, fmt.Errorf("invalid forward URL: %s - %s", config.ForwardURL, err)
+		return nil, err
+	}
+
+	return doForward(client, forwardReq, target, basket)
+}
+
+// newForwardRequest creates request to forward request data with the given body to specified target, the request is
+// cancelled along with the given context
+func (req *RequestData) newForwardRequest(ctx context.Context, target ForwardTarget, basket string,
+	body io.Reader) (*http.Request, error) {
+	forwardURL, err := url.ParseRequestURI(target.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid forward URL: %s - %s", target.URL, err)
 	}
 
 	// expand path
-	if config.ExpandPath && len(req.Path) > len(basket)+1 {
+	if target.ExpandPath && len(req.Path) > len(basket)+1 {
 		forwardURL.Path = expandURL(forwardURL.Path, req.Path, basket)
 	}
 
 	// append query
-	if len(req.Query) > 0 {
+	if query := rewriteForwardQuery(req.Query, target); len(query) > 0 {
 		if len(forwardURL.RawQuery) > 0 {
-			forwardURL.RawQuery += "&" + req.Query
+			forwardURL.RawQuery += "&" + query
 		} else {
-			forwardURL.RawQuery = req.Query
+			forwardURL.RawQuery = query
 		}
 	}
 
-	forwardReq, err := http.NewRequest(req.Method, forwardURL.String(), strings.NewReader(req.Body))
+	forwardReq, err := http.NewRequestWithContext(ctx, req.Method, forwardURL.String(), body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create forward request: %s", err)
 	}
@@ -182,16 +320,24 @@ func (req *RequestData) Forward(client *http.Client, config BasketConfig, basket
 	}
 	// headers cleanup
 	forwardHeadersCleanup(forwardReq)
-	// set do not forward header
-	forwardReq.Header.Set(DoNotForwardHeader, "1")
+	// rewrite headers
+	rewriteForwardHeaders(forwardReq, req, target, basket)
+	// count forward hops to detect loops
+	setForwardHops(forwardReq, req.Header)
+
+	return forwardReq, nil
+}
 
-	// forward request
+// doForward sends forward request, failures to reach the target are reported with 502 - Bad Gateway response,
+// connections denied by egress policy are reported with 403 - Forbidden
+func doForward(client *http.Client, forwardReq *http.Request, target ForwardTarget, basket string) (*http.Response,
+	error) {
 	response, err := client.Do(forwardReq)
 	if err != nil {
-		// HTTP issue during forwarding - HTTP 502 Bad Gateway
-		log.Printf("[warn] failed to forward request for basket: %s - %s", basket, err)
+		// HTTP issue during forwarding - HTTP 502 Bad Gateway (403 Forbidden if denied by egress policy)
+		log.Printf("[warn] failed to forward request for basket: %s to %s - %s", basket, target.URL, err)
 		badGatewayResp := &http.Response{
-			StatusCode: http.StatusBadGateway,
+			StatusCode: forwardErrorStatus(err, http.StatusBadGateway),
 			Header:     http.Header{},
 			Body:       ioutil.NopCloser(strings.NewReader(fmt.Sprintf("Failed to forward request: %s", err)))}
 		badGatewayResp.Header.Set("Content-Type", "text/plain")
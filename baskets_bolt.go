@@ -30,6 +30,12 @@ var (
 	boltKeyCount      = []byte("count")
 	boltKeyRequests   = []byte("requests")
 	boltKeyResponses  = []byte("responses")
+	boltKeyPaths      = []byte("paths")
+	boltKeyPayloads   = []byte("payloads")
+	boltKeyConfig     = []byte("config")
+	boltKeyState      = []byte("state")
+	boltKeyRetries    = []byte("retries")
+	boltKeyWebSockets = []byte("websockets")
 )
 
 func itob(i int) []byte {
@@ -57,6 +63,12 @@ func toOpts(config BasketConfig) []byte {
 	return []byte{opts}
 }
 
+// toConfig serializes complete basket configuration, settings without dedicated keys are restored from it
+func toConfig(config BasketConfig) []byte {
+	data, _ := json.Marshal(config)
+	return data
+}
+
 func fromOpts(opts []byte, config *BasketConfig) {
 	if len(opts) > 0 {
 		config.ExpandPath = opts[0]&boltOptExpandPath != 0
@@ -110,6 +122,10 @@ func (basket *boltBasket) Config() BasketConfig {
 	config := BasketConfig{}
 
 	basket.view(func(b *bolt.Bucket) error {
+		if data := b.Get(boltKeyConfig); data != nil {
+			json.Unmarshal(data, &config)
+		}
+
 		config.ForwardURL = string(b.Get(boltKeyForwardURL))
 		config.Capacity = btoi(b.Get(boltKeyCapacity))
 
@@ -129,6 +145,7 @@ func (basket *boltBasket) Update(config BasketConfig) {
 		b.Put(boltKeyForwardURL, []byte(config.ForwardURL))
 		b.Put(boltKeyOptions, toOpts(config))
 		b.Put(boltKeyCapacity, itob(config.Capacity))
+		b.Put(boltKeyConfig, toConfig(config))
 
 		if oldCap != config.Capacity && curCount > config.Capacity {
 			// remove overflow requests
@@ -182,6 +199,9 @@ func (basket *boltBasket) GetResponse(method string) *ResponseConfig {
 
 func (basket *boltBasket) SetResponse(method string, response ResponseConfig) {
 	basket.update(func(b *bolt.Bucket) error {
+		if err := setBoltPayload(b, method, "", detachPayload(&response)); err != nil {
+			return err
+		}
 		respj, err := json.Marshal(response)
 		if err != nil {
 			return err
@@ -200,6 +220,317 @@ func (basket *boltBasket) SetResponse(method string, response ResponseConfig) {
 	})
 }
 
+func (basket *boltBasket) GetPathResponses(method string) map[string]*ResponseConfig {
+	responses := make(map[string]*ResponseConfig)
+
+	basket.view(func(b *bolt.Bucket) error {
+		if paths := b.Bucket(boltKeyPaths); paths != nil {
+			if resps := paths.Bucket([]byte(method)); resps != nil {
+				return resps.ForEach(func(path []byte, resp []byte) error {
+					// parse response configuration
+					response := new(ResponseConfig)
+					if err := json.Unmarshal(resp, response); err != nil {
+						return err
+					}
+					responses[string(path)] = response
+					return nil
+				})
+			}
+		}
+
+		return nil
+	})
+
+	return responses
+}
+
+func (basket *boltBasket) SetPathResponse(method string, path string, response ResponseConfig) {
+	basket.update(func(b *bolt.Bucket) error {
+		if err := setBoltPayload(b, method, path, detachPayload(&response)); err != nil {
+			return err
+		}
+		respj, err := json.Marshal(response)
+		if err != nil {
+			return err
+		}
+
+		// first time declaring path response
+		paths, err := b.CreateBucketIfNotExists(boltKeyPaths)
+		if err != nil {
+			return err
+		}
+		resps, err := paths.CreateBucketIfNotExists([]byte(method))
+		if err != nil {
+			return err
+		}
+
+		// save configuration
+		return resps.Put([]byte(path), respj)
+	})
+}
+
+func (basket *boltBasket) DeletePathResponse(method string, path string) {
+	basket.update(func(b *bolt.Bucket) error {
+		if err := setBoltPayload(b, method, path, nil); err != nil {
+			return err
+		}
+		if paths := b.Bucket(boltKeyPaths); paths != nil {
+			if resps := paths.Bucket([]byte(method)); resps != nil {
+				return resps.Delete([]byte(path))
+			}
+		}
+
+		return nil
+	})
+}
+
+func (basket *boltBasket) GetResponsePayload(method string, path string) []byte {
+	var payload []byte
+
+	basket.view(func(b *bolt.Bucket) error {
+		if payloads := b.Bucket(boltKeyPayloads); payloads != nil {
+			if data := payloads.Get([]byte(payloadKey(method, path))); data != nil {
+				payload = make([]byte, len(data))
+				copy(payload, data)
+			}
+		}
+
+		return nil
+	})
+
+	return payload
+}
+
+// setBoltPayload saves binary payload of basket response apart from response configuration, empty payload is deleted
+func setBoltPayload(b *bolt.Bucket, method string, path string, payload []byte) error {
+	if len(payload) == 0 {
+		if payloads := b.Bucket(boltKeyPayloads); payloads != nil {
+			return payloads.Delete([]byte(payloadKey(method, path)))
+		}
+		return nil
+	}
+
+	payloads, err := b.CreateBucketIfNotExists(boltKeyPayloads)
+	if err != nil {
+		return err
+	}
+	return payloads.Put([]byte(payloadKey(method, path)), payload)
+}
+
+func (basket *boltBasket) GetState() map[string]string {
+	state := make(map[string]string)
+
+	basket.view(func(b *bolt.Bucket) error {
+		if entries := b.Bucket(boltKeyState); entries != nil {
+			return entries.ForEach(func(key []byte, value []byte) error {
+				state[string(key)] = string(value)
+				return nil
+			})
+		}
+
+		return nil
+	})
+
+	return state
+}
+
+func (basket *boltBasket) GetStateValue(key string) (string, bool) {
+	var value []byte
+
+	basket.view(func(b *bolt.Bucket) error {
+		if entries := b.Bucket(boltKeyState); entries != nil {
+			if v := entries.Get([]byte(key)); v != nil {
+				value = make([]byte, len(v))
+				copy(value, v)
+			}
+		}
+
+		return nil
+	})
+
+	return string(value), value != nil
+}
+
+func (basket *boltBasket) SetStateValue(key string, value string) error {
+	if err := validateStateEntry(key, value); err != nil {
+		return err
+	}
+
+	return basket.update(func(b *bolt.Bucket) error {
+		// first time declaring state
+		entries, err := b.CreateBucketIfNotExists(boltKeyState)
+		if err != nil {
+			return err
+		}
+
+		if err = checkStateLimit(entries.Stats().KeyN, entries.Get([]byte(key)) != nil); err != nil {
+			return err
+		}
+
+		return entries.Put([]byte(key), []byte(value))
+	})
+}
+
+func (basket *boltBasket) DeleteStateValue(key string) {
+	basket.update(func(b *bolt.Bucket) error {
+		if entries := b.Bucket(boltKeyState); entries != nil {
+			return entries.Delete([]byte(key))
+		}
+
+		return nil
+	})
+}
+
+func (basket *boltBasket) ClearState() {
+	basket.update(func(b *bolt.Bucket) error {
+		if b.Bucket(boltKeyState) != nil {
+			return b.DeleteBucket(boltKeyState)
+		}
+
+		return nil
+	})
+}
+
+func (basket *boltBasket) GetForwardRetries(dead bool) []*ForwardRetry {
+	retries := make([]*ForwardRetry, 0)
+
+	basket.view(func(b *bolt.Bucket) error {
+		if entries := b.Bucket(boltKeyRetries); entries != nil {
+			return entries.ForEach(func(key []byte, value []byte) error {
+				retry := new(ForwardRetry)
+				if err := json.Unmarshal(value, retry); err != nil {
+					log.Printf("[error] failed to parse forward retry %s of basket: %s - %s", key, basket.name, err)
+				} else if retry.Dead == dead {
+					retries = append(retries, retry)
+				}
+				return nil
+			})
+		}
+
+		return nil
+	})
+
+	return sortForwardRetries(retries)
+}
+
+func (basket *boltBasket) GetForwardRetry(id string) *ForwardRetry {
+	var retry *ForwardRetry
+
+	basket.view(func(b *bolt.Bucket) error {
+		if entries := b.Bucket(boltKeyRetries); entries != nil {
+			if value := entries.Get([]byte(id)); value != nil {
+				retry = new(ForwardRetry)
+				if err := json.Unmarshal(value, retry); err != nil {
+					log.Printf("[error] failed to parse forward retry %s of basket: %s - %s", id, basket.name, err)
+					retry = nil
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return retry
+}
+
+func (basket *boltBasket) SaveForwardRetry(retry *ForwardRetry) error {
+	data, err := json.Marshal(retry)
+	if err != nil {
+		return err
+	}
+
+	return basket.update(func(b *bolt.Bucket) error {
+		entries, err := b.CreateBucketIfNotExists(boltKeyRetries)
+		if err != nil {
+			return err
+		}
+
+		if err = checkRetryLimit(entries.Stats().KeyN, entries.Get([]byte(retry.ID)) != nil); err != nil {
+			return err
+		}
+
+		return entries.Put([]byte(retry.ID), data)
+	})
+}
+
+func (basket *boltBasket) DeleteForwardRetry(id string) {
+	basket.update(func(b *bolt.Bucket) error {
+		if entries := b.Bucket(boltKeyRetries); entries != nil {
+			return entries.Delete([]byte(id))
+		}
+
+		return nil
+	})
+}
+
+func (basket *boltBasket) GetWebSocketSessions() []*WebSocketSession {
+	sessions := make([]*WebSocketSession, 0)
+
+	basket.view(func(b *bolt.Bucket) error {
+		if entries := b.Bucket(boltKeyWebSockets); entries != nil {
+			return entries.ForEach(func(key []byte, value []byte) error {
+				session := new(WebSocketSession)
+				if err := json.Unmarshal(value, session); err != nil {
+					log.Printf("[error] failed to parse WebSocket session %s of basket: %s - %s", key, basket.name, err)
+				} else {
+					sessions = append(sessions, session)
+				}
+				return nil
+			})
+		}
+
+		return nil
+	})
+
+	return sortWebSocketSessions(sessions)
+}
+
+func (basket *boltBasket) GetWebSocketSession(id string) *WebSocketSession {
+	var session *WebSocketSession
+
+	basket.view(func(b *bolt.Bucket) error {
+		if entries := b.Bucket(boltKeyWebSockets); entries != nil {
+			if value := entries.Get([]byte(id)); value != nil {
+				session = new(WebSocketSession)
+				if err := json.Unmarshal(value, session); err != nil {
+					log.Printf("[error] failed to parse WebSocket session %s of basket: %s - %s", id, basket.name, err)
+					session = nil
+				}
+			}
+		}
+
+		return nil
+	})
+
+	return session
+}
+
+func (basket *boltBasket) SaveWebSocketSession(session *WebSocketSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	return basket.update(func(b *bolt.Bucket) error {
+		entries, err := b.CreateBucketIfNotExists(boltKeyWebSockets)
+		if err != nil {
+			return err
+		}
+
+		return entries.Put([]byte(session.ID), data)
+	})
+}
+
+func (basket *boltBasket) DeleteWebSocketSession(id string) {
+	basket.update(func(b *bolt.Bucket) error {
+		if entries := b.Bucket(boltKeyWebSockets); entries != nil {
+			return entries.Delete([]byte(id))
+		}
+
+		return nil
+	})
+}
+
 func (basket *boltBasket) Add(req *http.Request) *RequestData {
 	data := ToRequestData(req)
 
@@ -365,6 +696,7 @@ func (bdb *boltDatabase) Create(name string, config BasketConfig) (BasketAuth, e
 		b.Put(boltKeyForwardURL, []byte(config.ForwardURL))
 		b.Put(boltKeyOptions, toOpts(config))
 		b.Put(boltKeyCapacity, itob(config.Capacity))
+		b.Put(boltKeyConfig, toConfig(config))
 		b.Put(boltKeyTotalCount, itob(0))
 		b.Put(boltKeyCount, itob(0))
 		b.CreateBucket(boltKeyRequests)
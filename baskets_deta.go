@@ -13,17 +13,25 @@ import (
 // DbTypedeta defines name of detabase storage
 const DbTypeDeta = "deta"
 
+// maxDetaPayloadSize limits response payloads, since the whole basket is kept within a single Deta item
+const maxDetaPayloadSize = 128 * 1024
+
 /// Basket interface ///
 
 type detaBasket struct {
 	sync.RWMutex
 	base       *base.Base
-	Key        string                     `json:"key"` // json struct tag 'key' used to denote the key
-	Token      string                     `json:"token"`
-	BConfig    BasketConfig               `json:"config"`
-	Requests   []*RequestData             `json:"requests"`
-	TotalCount int                        `json:"totalCount"`
-	Responses  map[string]*ResponseConfig `json:"responses"`
+	Key        string                                `json:"key"` // json struct tag 'key' used to denote the key
+	Token      string                                `json:"token"`
+	BConfig    BasketConfig                          `json:"config"`
+	Requests   []*RequestData                        `json:"requests"`
+	TotalCount int                                   `json:"totalCount"`
+	Responses  map[string]*ResponseConfig            `json:"responses"`
+	Paths      map[string]map[string]*ResponseConfig `json:"paths"`
+	Payloads   map[string][]byte                     `json:"payloads"`
+	State      map[string]string                     `json:"state"`
+	Retries    map[string]*ForwardRetry              `json:"retries"`
+	WebSockets map[string]*WebSocketSession          `json:"websockets"`
 }
 
 func (basket *detaBasket) applyLimit() {
@@ -71,12 +79,264 @@ func (basket *detaBasket) SetResponse(method string, response ResponseConfig) {
 	basket.Lock()
 	defer basket.Unlock()
 
+	basket.setPayload(method, "", detachPayload(&response))
 	basket.Responses[method] = &response
 	basket.base.Update(basket.Key, base.Updates{
 		fmt.Sprint("responses.", method): response,
 	})
 }
 
+func (basket *detaBasket) GetPathResponses(method string) map[string]*ResponseConfig {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	responses := make(map[string]*ResponseConfig, len(basket.Paths[method]))
+	for path, response := range basket.Paths[method] {
+		responses[path] = response
+	}
+
+	return responses
+}
+
+func (basket *detaBasket) SetPathResponse(method string, path string, response ResponseConfig) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	if basket.Paths == nil {
+		basket.Paths = make(map[string]map[string]*ResponseConfig)
+	}
+	if _, exists := basket.Paths[method]; !exists {
+		basket.Paths[method] = make(map[string]*ResponseConfig)
+	}
+	basket.setPayload(method, path, detachPayload(&response))
+	basket.Paths[method][path] = &response
+	// path patterns contain special characters, so the whole collection is updated
+	basket.base.Update(basket.Key, base.Updates{
+		"paths": basket.Paths,
+	})
+}
+
+func (basket *detaBasket) DeletePathResponse(method string, path string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	if _, exists := basket.Paths[method][path]; exists {
+		delete(basket.Paths[method], path)
+		basket.base.Update(basket.Key, base.Updates{
+			"paths": basket.Paths,
+		})
+	}
+	basket.setPayload(method, path, nil)
+}
+
+func (basket *detaBasket) GetResponsePayload(method string, path string) []byte {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.Payloads[payloadKey(method, path)]
+}
+
+func (basket *detaBasket) maxPayloadSize() int {
+	return maxDetaPayloadSize
+}
+
+func (basket *detaBasket) setPayload(method string, path string, payload []byte) {
+	key := payloadKey(method, path)
+	if _, exists := basket.Payloads[key]; !exists && len(payload) == 0 {
+		return
+	}
+
+	if basket.Payloads == nil {
+		basket.Payloads = make(map[string][]byte)
+	}
+	if len(payload) > 0 {
+		basket.Payloads[key] = payload
+	} else {
+		delete(basket.Payloads, key)
+	}
+	// keys of payloads contain special characters, so the whole collection is updated
+	basket.base.Update(basket.Key, base.Updates{
+		"payloads": basket.Payloads,
+	})
+}
+
+func (basket *detaBasket) GetState() map[string]string {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	state := make(map[string]string, len(basket.State))
+	for key, value := range basket.State {
+		state[key] = value
+	}
+
+	return state
+}
+
+func (basket *detaBasket) GetStateValue(key string) (string, bool) {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	value, exists := basket.State[key]
+	return value, exists
+}
+
+func (basket *detaBasket) SetStateValue(key string, value string) error {
+	if err := validateStateEntry(key, value); err != nil {
+		return err
+	}
+
+	basket.Lock()
+	defer basket.Unlock()
+
+	_, exists := basket.State[key]
+	if err := checkStateLimit(len(basket.State), exists); err != nil {
+		return err
+	}
+	if basket.State == nil {
+		basket.State = make(map[string]string)
+	}
+	basket.State[key] = value
+	// keys may contain special characters, so the whole collection is updated
+	basket.base.Update(basket.Key, base.Updates{
+		"state": basket.State,
+	})
+
+	return nil
+}
+
+func (basket *detaBasket) DeleteStateValue(key string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	if _, exists := basket.State[key]; exists {
+		delete(basket.State, key)
+		basket.base.Update(basket.Key, base.Updates{
+			"state": basket.State,
+		})
+	}
+}
+
+func (basket *detaBasket) ClearState() {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.State = make(map[string]string)
+	basket.base.Update(basket.Key, base.Updates{
+		"state": basket.State,
+	})
+}
+
+func (basket *detaBasket) GetForwardRetries(dead bool) []*ForwardRetry {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	retries := make([]*ForwardRetry, 0)
+	for _, retry := range basket.Retries {
+		if retry.Dead == dead {
+			r := *retry
+			retries = append(retries, &r)
+		}
+	}
+
+	return sortForwardRetries(retries)
+}
+
+func (basket *detaBasket) GetForwardRetry(id string) *ForwardRetry {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	if retry, exists := basket.Retries[id]; exists {
+		r := *retry
+		return &r
+	}
+
+	return nil
+}
+
+func (basket *detaBasket) SaveForwardRetry(retry *ForwardRetry) error {
+	basket.Lock()
+	defer basket.Unlock()
+
+	_, exists := basket.Retries[retry.ID]
+	if err := checkRetryLimit(len(basket.Retries), exists); err != nil {
+		return err
+	}
+	if basket.Retries == nil {
+		basket.Retries = make(map[string]*ForwardRetry)
+	}
+	r := *retry
+	basket.Retries[retry.ID] = &r
+
+	// retries are updated as a whole collection, same as state
+	return basket.base.Update(basket.Key, base.Updates{
+		"retries": basket.Retries,
+	})
+}
+
+func (basket *detaBasket) DeleteForwardRetry(id string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	if _, exists := basket.Retries[id]; exists {
+		delete(basket.Retries, id)
+		basket.base.Update(basket.Key, base.Updates{
+			"retries": basket.Retries,
+		})
+	}
+}
+
+func (basket *detaBasket) GetWebSocketSessions() []*WebSocketSession {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	sessions := make([]*WebSocketSession, 0, len(basket.WebSockets))
+	for _, session := range basket.WebSockets {
+		s := *session
+		sessions = append(sessions, &s)
+	}
+
+	return sortWebSocketSessions(sessions)
+}
+
+func (basket *detaBasket) GetWebSocketSession(id string) *WebSocketSession {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	if session, exists := basket.WebSockets[id]; exists {
+		s := *session
+		return &s
+	}
+
+	return nil
+}
+
+func (basket *detaBasket) SaveWebSocketSession(session *WebSocketSession) error {
+	basket.Lock()
+	defer basket.Unlock()
+
+	if basket.WebSockets == nil {
+		basket.WebSockets = make(map[string]*WebSocketSession)
+	}
+	s := *session
+	basket.WebSockets[session.ID] = &s
+
+	return basket.base.Update(basket.Key, base.Updates{
+		"websockets": basket.WebSockets,
+	})
+}
+
+func (basket *detaBasket) DeleteWebSocketSession(id string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	if _, exists := basket.WebSockets[id]; exists {
+		delete(basket.WebSockets, id)
+		basket.base.Update(basket.Key, base.Updates{
+			"websockets": basket.WebSockets,
+		})
+	}
+}
+
 func (basket *detaBasket) Add(req *http.Request) *RequestData {
 	basket.Lock()
 	defer basket.Unlock()
@@ -171,12 +431,15 @@ type detaDatabase struct {
 	keys []string
 }
 type basketData struct {
-	Key        string                     `json:"key"`
-	Token      string                     `json:"token"`
-	Config     BasketConfig               `json:"config"`
-	Requests   []*RequestData             `json:"requests"`
-	TotalCount int                        `json:"totalCount"`
-	Responses  map[string]*ResponseConfig `json:"responses"`
+	Key        string                                `json:"key"`
+	Token      string                                `json:"token"`
+	Config     BasketConfig                          `json:"config"`
+	Requests   []*RequestData                        `json:"requests"`
+	TotalCount int                                   `json:"totalCount"`
+	Responses  map[string]*ResponseConfig            `json:"responses"`
+	Paths      map[string]map[string]*ResponseConfig `json:"paths"`
+	State      map[string]string                     `json:"state"`
+	Retries    map[string]*ForwardRetry              `json:"retries"`
 }
 
 func (db *detaDatabase) Create(name string, config BasketConfig) (BasketAuth, error) {
@@ -195,6 +458,9 @@ func (db *detaDatabase) Create(name string, config BasketConfig) (BasketAuth, er
 		Requests:   make([]*RequestData, 0, config.Capacity),
 		Config:     config,
 		Responses:  make(map[string]*ResponseConfig),
+		Paths:      make(map[string]map[string]*ResponseConfig),
+		State:      make(map[string]string),
+		Retries:    make(map[string]*ForwardRetry),
 		TotalCount: 0,
 	}
 
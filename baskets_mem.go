@@ -20,6 +20,11 @@ type memoryBasket struct {
 	requests   []*RequestData
 	totalCount int
 	responses  map[string]*ResponseConfig
+	paths      map[string]map[string]*ResponseConfig
+	payloads   map[string][]byte
+	state      map[string]string
+	retries    map[string]*ForwardRetry
+	websockets map[string]*WebSocketSession
 }
 
 func (basket *memoryBasket) applyLimit() {
@@ -60,9 +65,197 @@ func (basket *memoryBasket) SetResponse(method string, response ResponseConfig)
 	basket.Lock()
 	defer basket.Unlock()
 
+	basket.setPayload(method, "", detachPayload(&response))
 	basket.responses[method] = &response
 }
 
+func (basket *memoryBasket) GetPathResponses(method string) map[string]*ResponseConfig {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	responses := make(map[string]*ResponseConfig, len(basket.paths[method]))
+	for path, response := range basket.paths[method] {
+		responses[path] = response
+	}
+
+	return responses
+}
+
+func (basket *memoryBasket) SetPathResponse(method string, path string, response ResponseConfig) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	if _, exists := basket.paths[method]; !exists {
+		basket.paths[method] = make(map[string]*ResponseConfig)
+	}
+	basket.setPayload(method, path, detachPayload(&response))
+	basket.paths[method][path] = &response
+}
+
+func (basket *memoryBasket) DeletePathResponse(method string, path string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	delete(basket.paths[method], path)
+	delete(basket.payloads, payloadKey(method, path))
+}
+
+func (basket *memoryBasket) GetResponsePayload(method string, path string) []byte {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	return basket.payloads[payloadKey(method, path)]
+}
+
+func (basket *memoryBasket) setPayload(method string, path string, payload []byte) {
+	if len(payload) > 0 {
+		basket.payloads[payloadKey(method, path)] = payload
+	} else {
+		delete(basket.payloads, payloadKey(method, path))
+	}
+}
+
+func (basket *memoryBasket) GetState() map[string]string {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	state := make(map[string]string, len(basket.state))
+	for key, value := range basket.state {
+		state[key] = value
+	}
+
+	return state
+}
+
+func (basket *memoryBasket) GetStateValue(key string) (string, bool) {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	value, exists := basket.state[key]
+	return value, exists
+}
+
+func (basket *memoryBasket) SetStateValue(key string, value string) error {
+	if err := validateStateEntry(key, value); err != nil {
+		return err
+	}
+
+	basket.Lock()
+	defer basket.Unlock()
+
+	_, exists := basket.state[key]
+	if err := checkStateLimit(len(basket.state), exists); err != nil {
+		return err
+	}
+	basket.state[key] = value
+
+	return nil
+}
+
+func (basket *memoryBasket) DeleteStateValue(key string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	delete(basket.state, key)
+}
+
+func (basket *memoryBasket) ClearState() {
+	basket.Lock()
+	defer basket.Unlock()
+
+	basket.state = make(map[string]string)
+}
+
+func (basket *memoryBasket) GetForwardRetries(dead bool) []*ForwardRetry {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	retries := make([]*ForwardRetry, 0)
+	for _, retry := range basket.retries {
+		if retry.Dead == dead {
+			r := *retry
+			retries = append(retries, &r)
+		}
+	}
+
+	return sortForwardRetries(retries)
+}
+
+func (basket *memoryBasket) GetForwardRetry(id string) *ForwardRetry {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	if retry, exists := basket.retries[id]; exists {
+		r := *retry
+		return &r
+	}
+
+	return nil
+}
+
+func (basket *memoryBasket) SaveForwardRetry(retry *ForwardRetry) error {
+	basket.Lock()
+	defer basket.Unlock()
+
+	_, exists := basket.retries[retry.ID]
+	if err := checkRetryLimit(len(basket.retries), exists); err != nil {
+		return err
+	}
+	r := *retry
+	basket.retries[retry.ID] = &r
+
+	return nil
+}
+
+func (basket *memoryBasket) DeleteForwardRetry(id string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	delete(basket.retries, id)
+}
+
+func (basket *memoryBasket) GetWebSocketSessions() []*WebSocketSession {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	sessions := make([]*WebSocketSession, 0, len(basket.websockets))
+	for _, session := range basket.websockets {
+		s := *session
+		sessions = append(sessions, &s)
+	}
+
+	return sortWebSocketSessions(sessions)
+}
+
+func (basket *memoryBasket) GetWebSocketSession(id string) *WebSocketSession {
+	basket.RLock()
+	defer basket.RUnlock()
+
+	if session, exists := basket.websockets[id]; exists {
+		s := *session
+		return &s
+	}
+
+	return nil
+}
+
+func (basket *memoryBasket) SaveWebSocketSession(session *WebSocketSession) error {
+	basket.Lock()
+	defer basket.Unlock()
+
+	s := *session
+	basket.websockets[session.ID] = &s
+
+	return nil
+}
+
+func (basket *memoryBasket) DeleteWebSocketSession(id string) {
+	basket.Lock()
+	defer basket.Unlock()
+
+	delete(basket.websockets, id)
+}
+
 func (basket *memoryBasket) Add(req *http.Request) *RequestData {
 	basket.Lock()
 	defer basket.Unlock()
@@ -170,6 +363,11 @@ func (db *memoryDatabase) Create(name string, config BasketConfig) (BasketAuth,
 	basket.requests = make([]*RequestData, 0, config.Capacity)
 	basket.totalCount = 0
 	basket.responses = make(map[string]*ResponseConfig)
+	basket.paths = make(map[string]map[string]*ResponseConfig)
+	basket.payloads = make(map[string][]byte)
+	basket.state = make(map[string]string)
+	basket.retries = make(map[string]*ForwardRetry)
+	basket.websockets = make(map[string]*WebSocketSession)
 
 	db.baskets[name] = basket
 	db.names = append(db.names, name)
@@ -12,42 +12,17 @@ import (
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
 )
 
 // DbTypeSQL defines name of SQL database storage
 const DbTypeSQL = "sql"
 
-// List of DDL statements to create database schema for baskets
-var sqlSchema = []string{
-	`CREATE TABLE rb_baskets (
-		basket_name varchar(250) PRIMARY KEY,
-		token varchar(100) NOT NULL,
-		capacity integer NOT NULL,
-		forward_url text NOT NULL,
-		proxy_response boolean NOT NULL,
-		insecure_tls boolean NOT NULL,
-		expand_path boolean NOT NULL,
-		requests_count integer NOT NULL DEFAULT 0,
-		created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
-	)`,
-	`CREATE TABLE rb_responses (
-		basket_name varchar(250) NOT NULL,
-		http_method varchar(20) NOT NULL,
-		response text NOT NULL,
-		PRIMARY KEY (basket_name, http_method),
-		FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
-	)`,
-	`CREATE TABLE rb_requests (
-		basket_name varchar(250) NOT NULL,
-		request text NOT NULL,
-		created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
-		FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
-	)`,
-	`CREATE INDEX rb_requests_name_time_index ON rb_requests (basket_name, created_at)`,
-	`CREATE TABLE rb_version (
-		version integer NOT NULL
-	)`,
-	`INSERT INTO rb_version (version) VALUES (1)`}
+// toExtraConfig serializes complete basket configuration, settings without dedicated columns are restored from it
+func toExtraConfig(config BasketConfig) string {
+	data, _ := json.Marshal(config)
+	return string(data)
+}
 
 /// Basket interface ///
 type sqlBasket struct {
@@ -73,11 +48,14 @@ func (basket *sqlBasket) applyLimit(capacity int) {
 	if size > capacity {
 		var cleanupSQL string
 
-		// Note: 'ctid' is PostgreSQL specific
+		// Note: 'ctid' is PostgreSQL specific, 'rowid' is SQLite specific
 		// see example for MySQL here: https://stackoverflow.com/questions/5170546
 		switch basket.dbType {
 		case "postgres":
 			cleanupSQL = "DELETE FROM rb_requests WHERE ctid IN (SELECT ctid FROM rb_requests WHERE basket_name = $1 ORDER BY created_at LIMIT $2)"
+		case "sqlite":
+			// SQLite does not support ORDER BY and LIMIT in DELETE statement unless compiled with special option
+			cleanupSQL = "DELETE FROM rb_requests WHERE rowid IN (SELECT rowid FROM rb_requests WHERE basket_name = ? ORDER BY created_at LIMIT ?)"
 		default:
 			cleanupSQL = "DELETE FROM rb_requests WHERE basket_name = ? ORDER BY created_at LIMIT ?"
 		}
@@ -93,24 +71,52 @@ func (basket *sqlBasket) getTotalRequestsCount() int {
 }
 
 func (basket *sqlBasket) getLastRequestDate() int64 {
-	var value time.Time
+	var value interface{}
 	if err := basket.db.QueryRow(unifySQL(basket.dbType,
 		"SELECT MAX(created_at) FROM rb_requests WHERE basket_name = $1"), basket.name).Scan(&value); err != nil {
 		log.Printf("[error] failed to get last request date of basket: %s - %s", basket.name, err)
 		return 0
 	}
 
-	return value.UnixNano() / toMs
+	date, err := toTime(value)
+	if err != nil {
+		log.Printf("[error] failed to parse last request date of basket: %s - %s", basket.name, err)
+		return 0
+	}
+
+	return date.UnixNano() / toMs
+}
+
+// toTime converts timestamp value returned by database driver, SQLite returns aggregated timestamps as text
+func toTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return time.Parse(sqliteTimeFormat, v)
+	case []byte:
+		return time.Parse(sqliteTimeFormat, string(v))
+	default:
+		return time.Time{}, fmt.Errorf("unexpected timestamp value: %v", value)
+	}
 }
 
 func (basket *sqlBasket) Config() BasketConfig {
 	config := BasketConfig{}
 
+	var extra sql.NullString
 	err := basket.db.QueryRow(
-		unifySQL(basket.dbType, "SELECT capacity, forward_url, proxy_response, insecure_tls, expand_path FROM rb_baskets WHERE basket_name = $1"),
-		basket.name).Scan(&config.Capacity, &config.ForwardURL, &config.ProxyResponse, &config.InsecureTLS, &config.ExpandPath)
+		unifySQL(basket.dbType, "SELECT extra_config, capacity, forward_url, proxy_response, insecure_tls, expand_path FROM rb_baskets WHERE basket_name = $1"),
+		basket.name).Scan(&extra, &config.Capacity, &config.ForwardURL, &config.ProxyResponse, &config.InsecureTLS, &config.ExpandPath)
 	if err != nil {
 		log.Printf("[error] failed to get basket config: %s - %s", basket.name, err)
+	} else if extra.Valid && len(extra.String) > 0 {
+		// settings without dedicated columns; dedicated columns take precedence
+		capacity, forwardURL, proxyResponse, insecureTLS, expandPath := config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath
+		if err = json.Unmarshal([]byte(extra.String), &config); err != nil {
+			log.Printf("[error] failed to parse basket config: %s - %s", basket.name, err)
+		}
+		config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath = capacity, forwardURL, proxyResponse, insecureTLS, expandPath
 	}
 
 	return config
@@ -118,8 +124,8 @@ func (basket *sqlBasket) Config() BasketConfig {
 
 func (basket *sqlBasket) Update(config BasketConfig) {
 	_, err := basket.db.Exec(
-		unifySQL(basket.dbType, "UPDATE rb_baskets SET capacity = $1, forward_url = $2, proxy_response = $3, insecure_tls = $4, expand_path = $5 WHERE basket_name = $6"),
-		config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath, basket.name)
+		unifySQL(basket.dbType, "UPDATE rb_baskets SET capacity = $1, forward_url = $2, proxy_response = $3, insecure_tls = $4, expand_path = $5, extra_config = $6 WHERE basket_name = $7"),
+		config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath, toExtraConfig(config), basket.name)
 	if err != nil {
 		log.Printf("[error] failed to update basket config: %s - %s", basket.name, err)
 	} else {
@@ -166,6 +172,7 @@ func (basket *sqlBasket) GetResponse(method string) *ResponseConfig {
 }
 
 func (basket *sqlBasket) SetResponse(method string, response ResponseConfig) {
+	payload := detachPayload(&response)
 	if respb, err := json.Marshal(response); err == nil {
 		// delete existing if present
 		basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_responses WHERE basket_name = $1 AND http_method = $2"), basket.name, method)
@@ -176,8 +183,323 @@ func (basket *sqlBasket) SetResponse(method string, response ResponseConfig) {
 
 		if err != nil {
 			log.Printf("[error] failed to update response for HTTP %s method of basket: %s - %s", method, basket.name, err)
+		} else {
+			basket.setPayload(method, "", payload)
+		}
+	}
+}
+
+func (basket *sqlBasket) GetPathResponses(method string) map[string]*ResponseConfig {
+	responses := make(map[string]*ResponseConfig)
+
+	rows, err := basket.db.Query(
+		unifySQL(basket.dbType, "SELECT path_pattern, response FROM rb_path_responses WHERE basket_name = $1 AND http_method = $2"),
+		basket.name, method)
+	if err != nil {
+		log.Printf("[error] failed to get path responses for HTTP %s method of basket: %s - %s", method, basket.name, err)
+		return responses
+	}
+	defer rows.Close()
+
+	var path, resp string
+	for rows.Next() {
+		if err = rows.Scan(&path, &resp); err == nil {
+			response := new(ResponseConfig)
+			if err = json.Unmarshal([]byte(resp), response); err != nil {
+				log.Printf("[error] failed to parse response for HTTP %s %s of basket: %s - %s", method, path, basket.name, err)
+			} else {
+				responses[path] = response
+			}
+		}
+	}
+
+	return responses
+}
+
+func (basket *sqlBasket) SetPathResponse(method string, path string, response ResponseConfig) {
+	payload := detachPayload(&response)
+	if respb, err := json.Marshal(response); err == nil {
+		// delete existing if present
+		basket.DeletePathResponse(method, path)
+		// insert new response (ignore concurrency)
+		_, err = basket.db.Exec(
+			unifySQL(basket.dbType, "INSERT INTO rb_path_responses (basket_name, http_method, path_pattern, response) VALUES ($1, $2, $3, $4)"),
+			basket.name, method, path, string(respb))
+
+		if err != nil {
+			log.Printf("[error] failed to update response for HTTP %s %s of basket: %s - %s", method, path, basket.name, err)
+		} else {
+			basket.setPayload(method, path, payload)
+		}
+	}
+}
+
+func (basket *sqlBasket) DeletePathResponse(method string, path string) {
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "DELETE FROM rb_path_responses WHERE basket_name = $1 AND http_method = $2 AND path_pattern = $3"),
+		basket.name, method, path)
+	if err != nil {
+		log.Printf("[error] failed to delete response for HTTP %s %s of basket: %s - %s", method, path, basket.name, err)
+	}
+	basket.setPayload(method, path, nil)
+}
+
+func (basket *sqlBasket) GetResponsePayload(method string, path string) []byte {
+	var payload []byte
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT payload FROM rb_payloads WHERE basket_name = $1 AND http_method = $2 AND path_pattern = $3"),
+		basket.name, method, path).Scan(&payload)
+	if err != nil && err != sql.ErrNoRows {
+		log.Printf("[error] failed to get response payload for HTTP %s %s of basket: %s - %s", method, path, basket.name, err)
+	}
+
+	return payload
+}
+
+// setPayload saves binary payload of basket response apart from response configuration, empty payload is deleted
+func (basket *sqlBasket) setPayload(method string, path string, payload []byte) {
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "DELETE FROM rb_payloads WHERE basket_name = $1 AND http_method = $2 AND path_pattern = $3"),
+		basket.name, method, path)
+	if err == nil && len(payload) > 0 {
+		_, err = basket.db.Exec(
+			unifySQL(basket.dbType, "INSERT INTO rb_payloads (basket_name, http_method, path_pattern, payload) VALUES ($1, $2, $3, $4)"),
+			basket.name, method, path, payload)
+	}
+	if err != nil {
+		log.Printf("[error] failed to update response payload for HTTP %s %s of basket: %s - %s", method, path, basket.name, err)
+	}
+}
+
+func (basket *sqlBasket) GetState() map[string]string {
+	state := make(map[string]string)
+
+	rows, err := basket.db.Query(
+		unifySQL(basket.dbType, "SELECT state_key, state_value FROM rb_state WHERE basket_name = $1"), basket.name)
+	if err != nil {
+		log.Printf("[error] failed to get state of basket: %s - %s", basket.name, err)
+		return state
+	}
+	defer rows.Close()
+
+	var key, value string
+	for rows.Next() {
+		if err = rows.Scan(&key, &value); err == nil {
+			state[key] = value
+		}
+	}
+
+	return state
+}
+
+func (basket *sqlBasket) GetStateValue(key string) (string, bool) {
+	var value string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT state_value FROM rb_state WHERE basket_name = $1 AND state_key = $2"),
+		basket.name, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false
+	} else if err != nil {
+		log.Printf("[error] failed to get state value %s of basket: %s - %s", key, basket.name, err)
+		return "", false
+	}
+
+	return value, true
+}
+
+func (basket *sqlBasket) SetStateValue(key string, value string) error {
+	if err := validateStateEntry(key, value); err != nil {
+		return err
+	}
+
+	_, exists := basket.GetStateValue(key)
+	size := basket.getInt("SELECT COUNT(*) FROM rb_state WHERE basket_name = $1", 0)
+	if err := checkStateLimit(size, exists); err != nil {
+		return err
+	}
+
+	// replace existing value if present (ignore concurrency)
+	basket.DeleteStateValue(key)
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "INSERT INTO rb_state (basket_name, state_key, state_value) VALUES ($1, $2, $3)"),
+		basket.name, key, value)
+	if err != nil {
+		log.Printf("[error] failed to update state value %s of basket: %s - %s", key, basket.name, err)
+		return fmt.Errorf("failed to update basket state")
+	}
+
+	return nil
+}
+
+func (basket *sqlBasket) DeleteStateValue(key string) {
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "DELETE FROM rb_state WHERE basket_name = $1 AND state_key = $2"), basket.name, key)
+	if err != nil {
+		log.Printf("[error] failed to delete state value %s of basket: %s - %s", key, basket.name, err)
+	}
+}
+
+func (basket *sqlBasket) ClearState() {
+	if _, err := basket.db.Exec(unifySQL(basket.dbType, "DELETE FROM rb_state WHERE basket_name = $1"), basket.name); err != nil {
+		log.Printf("[error] failed to delete state of basket: %s - %s", basket.name, err)
+	}
+}
+
+func (basket *sqlBasket) GetForwardRetries(dead bool) []*ForwardRetry {
+	retries := make([]*ForwardRetry, 0)
+
+	rows, err := basket.db.Query(
+		unifySQL(basket.dbType, "SELECT retry FROM rb_retries WHERE basket_name = $1 AND dead = $2"), basket.name, dead)
+	if err != nil {
+		log.Printf("[error] failed to get forward retries of basket: %s - %s", basket.name, err)
+		return retries
+	}
+	defer rows.Close()
+
+	var data string
+	for rows.Next() {
+		if err = rows.Scan(&data); err == nil {
+			retry := new(ForwardRetry)
+			if err = json.Unmarshal([]byte(data), retry); err == nil {
+				retries = append(retries, retry)
+			} else {
+				log.Printf("[error] failed to parse forward retry of basket: %s - %s", basket.name, err)
+			}
+		}
+	}
+
+	return sortForwardRetries(retries)
+}
+
+func (basket *sqlBasket) GetForwardRetry(id string) *ForwardRetry {
+	var data string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT retry FROM rb_retries WHERE basket_name = $1 AND retry_id = $2"),
+		basket.name, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get forward retry %s of basket: %s - %s", id, basket.name, err)
+		return nil
+	}
+
+	retry := new(ForwardRetry)
+	if err = json.Unmarshal([]byte(data), retry); err != nil {
+		log.Printf("[error] failed to parse forward retry %s of basket: %s - %s", id, basket.name, err)
+		return nil
+	}
+
+	return retry
+}
+
+func (basket *sqlBasket) SaveForwardRetry(retry *ForwardRetry) error {
+	data, err := json.Marshal(retry)
+	if err != nil {
+		return err
+	}
+
+	exists := basket.GetForwardRetry(retry.ID) != nil
+	size := basket.getInt("SELECT COUNT(*) FROM rb_retries WHERE basket_name = $1", 0)
+	if err = checkRetryLimit(size, exists); err != nil {
+		return err
+	}
+
+	// replace existing retry if present (ignore concurrency)
+	basket.DeleteForwardRetry(retry.ID)
+	_, err = basket.db.Exec(
+		unifySQL(basket.dbType, "INSERT INTO rb_retries (basket_name, retry_id, dead, retry) VALUES ($1, $2, $3, $4)"),
+		basket.name, retry.ID, retry.Dead, string(data))
+	if err != nil {
+		log.Printf("[error] failed to save forward retry %s of basket: %s - %s", retry.ID, basket.name, err)
+		return fmt.Errorf("failed to save forward retry")
+	}
+
+	return nil
+}
+
+func (basket *sqlBasket) DeleteForwardRetry(id string) {
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "DELETE FROM rb_retries WHERE basket_name = $1 AND retry_id = $2"), basket.name, id)
+	if err != nil {
+		log.Printf("[error] failed to delete forward retry %s of basket: %s - %s", id, basket.name, err)
+	}
+}
+
+func (basket *sqlBasket) GetWebSocketSessions() []*WebSocketSession {
+	sessions := make([]*WebSocketSession, 0)
+
+	rows, err := basket.db.Query(
+		unifySQL(basket.dbType, "SELECT session FROM rb_ws_sessions WHERE basket_name = $1"), basket.name)
+	if err != nil {
+		log.Printf("[error] failed to get WebSocket sessions of basket: %s - %s", basket.name, err)
+		return sessions
+	}
+	defer rows.Close()
+
+	var data string
+	for rows.Next() {
+		if err = rows.Scan(&data); err == nil {
+			session := new(WebSocketSession)
+			if err = json.Unmarshal([]byte(data), session); err == nil {
+				sessions = append(sessions, session)
+			} else {
+				log.Printf("[error] failed to parse WebSocket session of basket: %s - %s", basket.name, err)
+			}
 		}
 	}
+
+	return sortWebSocketSessions(sessions)
+}
+
+func (basket *sqlBasket) GetWebSocketSession(id string) *WebSocketSession {
+	var data string
+
+	err := basket.db.QueryRow(
+		unifySQL(basket.dbType, "SELECT session FROM rb_ws_sessions WHERE basket_name = $1 AND session_id = $2"),
+		basket.name, id).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		log.Printf("[error] failed to get WebSocket session %s of basket: %s - %s", id, basket.name, err)
+		return nil
+	}
+
+	session := new(WebSocketSession)
+	if err = json.Unmarshal([]byte(data), session); err != nil {
+		log.Printf("[error] failed to parse WebSocket session %s of basket: %s - %s", id, basket.name, err)
+		return nil
+	}
+
+	return session
+}
+
+func (basket *sqlBasket) SaveWebSocketSession(session *WebSocketSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	// replace existing session if present (ignore concurrency)
+	basket.DeleteWebSocketSession(session.ID)
+	_, err = basket.db.Exec(
+		unifySQL(basket.dbType, "INSERT INTO rb_ws_sessions (basket_name, session_id, session) VALUES ($1, $2, $3)"),
+		basket.name, session.ID, string(data))
+	if err != nil {
+		log.Printf("[error] failed to save WebSocket session %s of basket: %s - %s", session.ID, basket.name, err)
+		return fmt.Errorf("failed to save WebSocket session")
+	}
+
+	return nil
+}
+
+func (basket *sqlBasket) DeleteWebSocketSession(id string) {
+	_, err := basket.db.Exec(
+		unifySQL(basket.dbType, "DELETE FROM rb_ws_sessions WHERE basket_name = $1 AND session_id = $2"), basket.name, id)
+	if err != nil {
+		log.Printf("[error] failed to delete WebSocket session %s of basket: %s - %s", id, basket.name, err)
+	}
 }
 
 func (basket *sqlBasket) Add(req *http.Request) *RequestData {
@@ -340,8 +662,8 @@ func (sdb *sqlDatabase) Create(name string, config BasketConfig) (BasketAuth, er
 	}
 
 	basket, err := sdb.db.Exec(
-		unifySQL(sdb.dbType, "INSERT INTO rb_baskets (basket_name, token, capacity, forward_url, proxy_response, insecure_tls, expand_path) VALUES($1, $2, $3, $4, $5, $6, $7)"),
-		name, token, config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath)
+		unifySQL(sdb.dbType, "INSERT INTO rb_baskets (basket_name, token, capacity, forward_url, proxy_response, insecure_tls, expand_path, extra_config) VALUES($1, $2, $3, $4, $5, $6, $7, $8)"),
+		name, token, config.Capacity, config.ForwardURL, config.ProxyResponse, config.InsecureTLS, config.ExpandPath, toExtraConfig(config))
 	if err != nil {
 		return auth, fmt.Errorf("failed to create basket: %s - %s", name, err)
 	}
@@ -450,35 +772,70 @@ func (sdb *sqlDatabase) Release() {
 func NewSQLDatabase(connection string) BasketsDatabase {
 	log.Print("[info] using SQL database to store baskets")
 
+	db, driver := openSQLDatabase(connection)
+	if db == nil {
+		return nil
+	}
+
+	if err := initSchema(db, driver, sqlMigrations); err != nil {
+		log.Printf("[error] failed to initialize SQL schema: %s", err)
+	} else {
+		return &sqlDatabase{db, driver}
+	}
+
+	db.Close()
+	return nil
+}
+
+// openSQLDatabase opens connection to SQL database and verifies it is alive, returns database and its driver name
+func openSQLDatabase(connection string) (*sql.DB, string) {
 	driver, source := parseConnection(connection)
 	if len(driver) == 0 {
-		return nil
+		return nil, driver
 	}
 	log.Printf("[info] SQL database type: %s", driver)
 
 	db, err := sql.Open(driver, source)
 	if err != nil {
 		log.Printf("[error] failed to open database connection: %s - %s", connection, err)
-		return nil
+		return nil, driver
 	}
 
 	if err = db.Ping(); err != nil {
 		log.Printf("[error] database connection is not alive: %s - %s", connection, err)
-	} else if err = initSchema(db); err != nil {
-		log.Printf("[error] failed to initialize SQL schema: %s", err)
-	} else {
-		return &sqlDatabase{db, driver}
+		db.Close()
+		return nil, driver
 	}
 
-	db.Close()
-	return nil
+	return db, driver
 }
 
 var pgParams = regexp.MustCompile(`\$\d+`)
 
+// sqlitePragmas are applied to every SQLite connection: foreign keys to cascade deletes, busy timeout and WAL journal
+// to let concurrent requests wait for each other instead of failing on locked database
+const sqlitePragmas = "_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+
+// sqliteTimeFormat is the format of timestamps with milliseconds produced by SQLite
+const sqliteTimeFormat = "2006-01-02 15:04:05.999999999"
+
+// unifyDDL adapts DDL statement to the database dialect: SQLite has no CURRENT_TIMESTAMP with fractional seconds,
+// binary data is declared with PostgreSQL "bytea" type
+func unifyDDL(dbType string, ddl string) string {
+	switch dbType {
+	case "mysql":
+		return strings.ReplaceAll(ddl, " bytea ", " longblob ")
+	case "sqlite":
+		ddl = strings.ReplaceAll(ddl, " bytea ", " blob ")
+		return strings.ReplaceAll(ddl, "CURRENT_TIMESTAMP(3)", "(strftime('%Y-%m-%d %H:%M:%f', 'now'))")
+	default:
+		return ddl
+	}
+}
+
 func unifySQL(dbType string, sql string) string {
 	switch dbType {
-	case "mysql", "sqlite3":
+	case "mysql", "sqlite":
 		// replace $n with ?
 		return pgParams.ReplaceAllString(sql, "?")
 		// case "postgres", "sqlserver":
@@ -498,8 +855,13 @@ func parseConnection(connection string) (string, string) {
 			return driver, connection
 		case "mysql":
 			return driver, source + "?parseTime=true"
-		// case "sqlite3":
-		// 	return driver, source
+		case "sqlite3", "sqlite":
+			// foreign keys are disabled by default, but required to cascade deletion of baskets
+			separator := "?"
+			if strings.Contains(source, "?") {
+				separator = "&"
+			}
+			return "sqlite", "file:" + source + separator + sqlitePragmas
 		default:
 			return driver, connection
 		}
@@ -508,36 +870,3 @@ func parseConnection(connection string) (string, string) {
 	log.Printf("[error] failed to detect database type from connection: %s", connection)
 	return "", connection
 }
-
-func initSchema(db *sql.DB) error {
-	switch version := getSchemaVersion(db); version {
-	case 0:
-		return createSchema(db)
-	case 1:
-		log.Printf("[info] database schema already exists, version: %v", version)
-		return nil
-	default:
-		return fmt.Errorf("unknown database schema version: %v", version)
-	}
-}
-
-func getSchemaVersion(db *sql.DB) int {
-	var version int
-	if err := db.QueryRow("SELECT version FROM rb_version").Scan(&version); err != nil {
-		return 0
-	}
-
-	return version
-}
-
-func createSchema(db *sql.DB) error {
-	log.Printf("[info] creating database schema")
-	for idx, stmt := range sqlSchema {
-		if _, err := db.Exec(stmt); err != nil {
-			return fmt.Errorf("error in SQL statement #%v - %s", idx, err)
-		}
-	}
-
-	log.Printf("[info] database is created, version: %v", getSchemaVersion(db))
-	return nil
-}
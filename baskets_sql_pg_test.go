@@ -273,6 +273,176 @@ func TestPgSQLBasket_Update_Shrink(t *testing.T) {
 	}
 }
 
+func TestPgSQLBasket_Update_Validation(t *testing.T) {
+	name := "test110"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, ForwardURL: "http://localhost:12345/test"})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		config := basket.Config()
+		config.Validation = &BasketValidation{Methods: []string{"POST"}, Reject: true,
+			Schema: &JSONSchema{Type: schemaTypes{"object"}, Required: []string{"id"}}}
+		basket.Update(config)
+
+		// validation settings are preserved along with other settings
+		assert.Equal(t, config, basket.Config(), "wrong basket configuration")
+	}
+}
+
+func TestPgSQLBasket_State(t *testing.T) {
+	name := "test111"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Empty(t, basket.GetState(), "state is expected to be empty")
+
+		assert.NoError(t, basket.SetStateValue("order-1", "{\"id\":1}"))
+		assert.NoError(t, basket.SetStateValue("count", "1"))
+		assert.NoError(t, basket.SetStateValue("count", "2"))
+		assert.Error(t, basket.SetStateValue("", "empty key"))
+
+		value, exists := basket.GetStateValue("count")
+		assert.True(t, exists, "state value is expected")
+		assert.Equal(t, "2", value, "wrong state value")
+		_, exists = basket.GetStateValue("unknown")
+		assert.False(t, exists, "state value is not expected")
+		assert.Equal(t, map[string]string{"count": "2", "order-1": "{\"id\":1}"}, basket.GetState(), "wrong state")
+
+		basket.DeleteStateValue("count")
+		assert.Equal(t, map[string]string{"order-1": "{\"id\":1}"}, basket.GetState(), "wrong state")
+
+		basket.ClearState()
+		assert.Empty(t, basket.GetState(), "state is expected to be empty")
+	}
+}
+
+func TestPgSQLBasket_ForwardRetries(t *testing.T) {
+	name := "test112"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Empty(t, basket.GetForwardRetries(false), "forward retries are not expected")
+
+		request := &RequestData{Date: 1, Method: "POST", Body: "{}"}
+		retry := &ForwardRetry{ID: "r1", Target: ForwardTarget{URL: "http://localhost:81"}, Request: request, Attempts: 1}
+		assert.NoError(t, basket.SaveForwardRetry(retry))
+		assert.NoError(t, basket.SaveForwardRetry(&ForwardRetry{ID: "r2", Request: request, Attempts: 3, Dead: true}))
+
+		retries := basket.GetForwardRetries(false)
+		if assert.Len(t, retries, 1, "wrong number of forward retries") {
+			assert.Equal(t, "r1", retries[0].ID, "wrong forward retry")
+			assert.Equal(t, "http://localhost:81", retries[0].Target.URL, "wrong forward target")
+			assert.Equal(t, "{}", retries[0].Request.Body, "wrong forwarded request")
+		}
+
+		// update retry
+		retry.Attempts = 2
+		retry.LastStatus = 503
+		assert.NoError(t, basket.SaveForwardRetry(retry))
+		if saved := basket.GetForwardRetry("r1"); assert.NotNil(t, saved, "forward retry is expected") {
+			assert.Equal(t, 2, saved.Attempts, "wrong number of attempts")
+			assert.Equal(t, 503, saved.LastStatus, "wrong last status")
+		}
+
+		dead := basket.GetForwardRetries(true)
+		if assert.Len(t, dead, 1, "wrong number of dead letters") {
+			assert.Equal(t, "r2", dead[0].ID, "wrong dead letter")
+		}
+
+		basket.DeleteForwardRetry("r1")
+		assert.Nil(t, basket.GetForwardRetry("r1"), "forward retry is not expected")
+		assert.Empty(t, basket.GetForwardRetries(false), "forward retries are not expected")
+	}
+}
+
+func TestPgSQLBasket_WebSocketSessions(t *testing.T) {
+	name := "test113"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Empty(t, basket.GetWebSocketSessions(), "WebSocket sessions are not expected")
+
+		session := &WebSocketSession{ID: "s1", Date: 1, Path: "/" + name + "/ws", FrameCount: 1,
+			Frames: []WebSocketFrame{{Direction: WebSocketInbound, Opcode: 1, Payload: "hello", Size: 5, Date: 2}}}
+		assert.NoError(t, basket.SaveWebSocketSession(session))
+		assert.NoError(t, basket.SaveWebSocketSession(&WebSocketSession{ID: "s2", Date: 3}))
+
+		sessions := basket.GetWebSocketSessions()
+		if assert.Len(t, sessions, 2, "wrong number of WebSocket sessions") {
+			assert.Equal(t, "s2", sessions[0].ID, "newest session is expected first")
+			assert.Equal(t, "s1", sessions[1].ID, "wrong WebSocket session")
+		}
+
+		// update session
+		session.Closed = 4
+		session.FrameCount = 2
+		session.Frames = append(session.Frames, WebSocketFrame{Direction: WebSocketOutbound, Opcode: 8, Date: 4})
+		assert.NoError(t, basket.SaveWebSocketSession(session))
+		if saved := basket.GetWebSocketSession("s1"); assert.NotNil(t, saved, "WebSocket session is expected") {
+			assert.Equal(t, int64(4), saved.Closed, "wrong close date")
+			if assert.Len(t, saved.Frames, 2, "wrong number of frames") {
+				assert.Equal(t, "hello", saved.Frames[0].Payload, "wrong frame payload")
+				assert.Equal(t, WebSocketOutbound, saved.Frames[1].Direction, "wrong frame direction")
+			}
+		}
+
+		basket.DeleteWebSocketSession("s1")
+		assert.Nil(t, basket.GetWebSocketSession("s1"), "WebSocket session is not expected")
+		assert.Len(t, basket.GetWebSocketSessions(), 1, "wrong number of WebSocket sessions")
+	}
+}
+
+func TestPgSQLBasket_ResponsePayload(t *testing.T) {
+	name := "test114"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Nil(t, basket.GetResponsePayload("GET", ""), "payload is not expected")
+
+		// payloads are kept apart from response configuration
+		basket.SetResponse("GET", ResponseConfig{Status: 200, Payload: []byte{0, 1, 2}, PayloadSize: 3})
+		basket.SetPathResponse("GET", "/files/:id", ResponseConfig{Status: 200, Payload: []byte{3, 4}, PayloadSize: 2})
+		if response := basket.GetResponse("GET"); assert.NotNil(t, response, "response is expected") {
+			assert.Empty(t, response.Payload, "payload should not be loaded with response configuration")
+			assert.Equal(t, 3, response.PayloadSize, "wrong payload size")
+		}
+		assert.Empty(t, basket.GetPathResponses("GET")["/files/:id"].Payload, "payload should not be loaded")
+		assert.Equal(t, []byte{0, 1, 2}, basket.GetResponsePayload("GET", ""), "wrong payload of response")
+		assert.Equal(t, []byte{3, 4}, basket.GetResponsePayload("GET", "/files/:id"), "wrong payload of path response")
+
+		// payload is replaced along with response
+		basket.SetResponse("GET", ResponseConfig{Status: 204})
+		assert.Nil(t, basket.GetResponsePayload("GET", ""), "payload should be deleted")
+
+		basket.DeletePathResponse("GET", "/files/:id")
+		assert.Nil(t, basket.GetResponsePayload("GET", "/files/:id"), "payload of deleted response is not expected")
+	}
+}
+
 func TestPgSQLBasket_GetRequests(t *testing.T) {
 	name := "test105"
 	db := NewSQLDatabase(pgTestConnection)
@@ -466,6 +636,46 @@ func TestPgSQLBasket_SetResponse_Error(t *testing.T) {
 	}
 }
 
+func TestPgSQLBasket_SetPathResponse(t *testing.T) {
+	name := "test109"
+	method := "GET"
+	db := NewSQLDatabase(pgTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no path responses
+		assert.Empty(t, basket.GetPathResponses(method))
+
+		// Set responses
+		basket.SetPathResponse(method, "/v1/users/:id", ResponseConfig{Status: 200, Body: "user"})
+		basket.SetPathResponse(method, "/v1/orders", ResponseConfig{Status: 200, Body: "orders"})
+		basket.SetPathResponse(method, "/v1/orders", ResponseConfig{Status: 202, Body: "orders updated"})
+		basket.SetPathResponse("POST", "/v1/orders", ResponseConfig{Status: 201})
+
+		// Get and validate
+		responses := basket.GetPathResponses(method)
+		assert.Len(t, responses, 2, "wrong number of path responses")
+		if assert.NotNil(t, responses["/v1/orders"], "response for path is expected") {
+			assert.Equal(t, 202, responses["/v1/orders"].Status, "wrong HTTP response status")
+			assert.Equal(t, "orders updated", responses["/v1/orders"].Body, "wrong HTTP response body")
+		}
+		// default response is not affected
+		assert.Nil(t, basket.GetResponse(method))
+
+		// Delete and validate
+		basket.DeletePathResponse(method, "/v1/users/:id")
+		basket.DeletePathResponse(method, "/unknown")
+		responses = basket.GetPathResponses(method)
+		assert.Len(t, responses, 1, "wrong number of path responses")
+		assert.Nil(t, responses["/v1/users/:id"], "deleted path response is not expected")
+		assert.Len(t, basket.GetPathResponses("POST"), 1, "wrong number of path responses")
+	}
+}
+
 func TestPgSQLDatabase_GetStats(t *testing.T) {
 	name := "test130"
 	db := NewSQLDatabase(pgTestConnection)
@@ -0,0 +1,724 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Note: since database file/schema is reused, these tests cannot run in parallel; the file is removed on shutdown
+const sqliteTestFile = "./sqlite_baskets_test.db"
+const sqliteTestConnection = "sqlite3://" + sqliteTestFile
+
+func TestSQLiteDatabase_Create(t *testing.T) {
+	name := "test1"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	auth, err := db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	if assert.NoError(t, err) {
+		assert.NotEmpty(t, auth.Token, "basket token may not be empty")
+		assert.False(t, len(auth.Token) < 30, "weak basket token: %v", auth.Token)
+	}
+}
+
+func TestSQLiteDatabase_Create_NameConflict(t *testing.T) {
+	name := "test2"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	auth, err := db.Create(name, BasketConfig{Capacity: 20})
+
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), ": "+name+" ", "error is not detailed enough")
+		assert.Empty(t, auth.Token, "basket token is not expected")
+	}
+}
+
+func TestSQLiteDatabase_Get(t *testing.T) {
+	name := "test3"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	auth, err := db.Create(name, BasketConfig{Capacity: 16})
+	defer db.Delete(name)
+
+	assert.NoError(t, err)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.True(t, basket.Authorize(auth.Token), "basket authorization has failed")
+		assert.Equal(t, 16, basket.Config().Capacity, "wrong capacity")
+	}
+}
+
+func TestSQLiteDatabase_Get_NotFound(t *testing.T) {
+	name := "test4"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	basket := db.Get(name)
+	assert.Nil(t, basket, "basket with name: %v is not expected", name)
+}
+
+func TestSQLiteDatabase_Delete(t *testing.T) {
+	name := "test5"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	assert.NotNil(t, db.Get(name), "basket with name: %v is expected", name)
+
+	db.Delete(name)
+	assert.Nil(t, db.Get(name), "basket with name: %v is not expected", name)
+}
+
+func TestSQLiteDatabase_Delete_Multi(t *testing.T) {
+	name := "test6"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 10}
+	for i := 0; i < 10; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+	}
+
+	dname := name + "_5"
+
+	assert.NotNil(t, db.Get(dname), "basket with name: %v is expected", name)
+	assert.Equal(t, 10, db.Size(), "wrong database size")
+
+	db.Delete(dname)
+
+	assert.Nil(t, db.Get(dname), "basket with name: %v is not expected", name)
+	assert.Equal(t, 9, db.Size(), "wrong database size")
+}
+
+func TestSQLiteDatabase_Size(t *testing.T) {
+	name := "test7"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 15}
+	for i := 0; i < 25; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+	}
+
+	assert.Equal(t, 25, db.Size(), "wrong database size")
+}
+
+func TestSQLiteDatabase_GetNames(t *testing.T) {
+	name := "test8"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 15}
+	for i := 0; i < 45; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+	}
+
+	// Get and validate page 1 (test8_0, test8_1, test8_10, test8_11, ... - sorted)
+	page1 := db.GetNames(10, 0)
+	assert.Equal(t, 45, page1.Count, "wrong baskets count")
+	assert.True(t, page1.HasMore, "expected more names")
+	assert.Len(t, page1.Names, 10, "wrong page size")
+	assert.Equal(t, "test8_10", page1.Names[2], "wrong basket name at index #2")
+
+	// Get and validate page 5 (test8_5, test8_6, test8_7, test8_8, test8_9)
+	page5 := db.GetNames(10, 40)
+	assert.Equal(t, 45, page5.Count, "wrong baskets count")
+	assert.False(t, page5.HasMore, "no more names are expected")
+	assert.Len(t, page5.Names, 5, "wrong page size")
+	assert.Equal(t, "test8_5", page5.Names[0], "wrong basket name at index #0")
+
+	// Corner cases
+	assert.Empty(t, db.GetNames(0, 0).Names, "names are not expected")
+	assert.False(t, db.GetNames(5, 40).HasMore, "no more names are expected")
+}
+
+func TestSQLiteDatabase_FindNames(t *testing.T) {
+	name := "test9"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 5}
+	for i := 0; i < 35; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+	}
+
+	res1 := db.FindNames("test9_2", 20, 0)
+	assert.False(t, res1.HasMore, "no more names are expected")
+	assert.Len(t, res1.Names, 11, "wrong number of found names")
+	for _, name := range res1.Names {
+		assert.Contains(t, name, "test9_2", "invalid name among search results")
+	}
+
+	res2 := db.FindNames("test9_1", 5, 0)
+	assert.True(t, res2.HasMore, "more names are expected")
+	assert.Len(t, res2.Names, 5, "wrong number of found names")
+
+	// Corner cases
+	assert.Len(t, db.FindNames("test9_1", 5, 10).Names, 1, "wrong number of returned names")
+	assert.Empty(t, db.FindNames("test9_2", 5, 20).Names, "names in this page are not expected")
+	assert.False(t, db.FindNames("test9_3", 5, 6).HasMore, "no more names are expected")
+	assert.False(t, db.FindNames("abc", 5, 0).HasMore, "no more names are expected")
+	assert.Empty(t, db.FindNames("xyz", 5, 0).Names, "names are not expected")
+}
+
+func TestSQLiteBasket_Add(t *testing.T) {
+	name := "test101"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// add 1st HTTP request
+		content := "{ \"user\": \"tester\", \"age\": 24 }"
+		data := basket.Add(createTestPOSTRequest(
+			fmt.Sprintf("http://localhost/%v/demo?name=abc&ver=12", name), content, "application/json"))
+
+		assert.Equal(t, 1, basket.Size(), "wrong basket size")
+
+		// detailed http.Request to RequestData tests should be covered by test of ToRequestData function
+		assert.Equal(t, content, data.Body, "wrong body")
+		assert.Equal(t, int64(len(content)), data.ContentLength, "wrong content length")
+
+		// add 2nd HTTP request
+		basket.Add(createTestPOSTRequest(fmt.Sprintf("http://localhost/%v/demo", name), "Hellow world", "text/plain"))
+		assert.Equal(t, 2, basket.Size(), "wrong basket size")
+	}
+}
+
+func TestSQLiteBasket_Add_ExceedLimit(t *testing.T) {
+	name := "test102"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 10})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 0; i < 35; i++ {
+			basket.Add(createTestPOSTRequest(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
+		}
+		assert.Equal(t, 10, basket.Size(), "wrong basket size")
+	}
+}
+
+func TestSQLiteBasket_Clear(t *testing.T) {
+	name := "test103"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 0; i < 15; i++ {
+			basket.Add(createTestPOSTRequest(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
+		}
+		assert.Equal(t, 15, basket.Size(), "wrong basket size")
+
+		// clean basket
+		basket.Clear()
+		assert.Equal(t, 0, basket.Size(), "wrong basket size, empty basket is expected")
+	}
+}
+
+func TestSQLiteBasket_Update_Shrink(t *testing.T) {
+	name := "test104"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 30})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 0; i < 25; i++ {
+			basket.Add(createTestPOSTRequest(
+				fmt.Sprintf("http://localhost/%v/demo", name), fmt.Sprintf("test%v", i), "text/plain"))
+		}
+		assert.Equal(t, 25, basket.Size(), "wrong basket size")
+
+		// update config with lower capacity
+		config := basket.Config()
+		config.Capacity = 12
+		basket.Update(config)
+		assert.Equal(t, config.Capacity, basket.Size(), "wrong basket size")
+	}
+}
+
+func TestSQLiteBasket_Update_Validation(t *testing.T) {
+	name := "test110"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20, ForwardURL: "http://localhost:12345/test"})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		config := basket.Config()
+		config.Validation = &BasketValidation{Methods: []string{"POST"}, Reject: true,
+			Schema: &JSONSchema{Type: schemaTypes{"object"}, Required: []string{"id"}}}
+		basket.Update(config)
+
+		// validation settings are preserved along with other settings
+		assert.Equal(t, config, basket.Config(), "wrong basket configuration")
+	}
+}
+
+func TestSQLiteBasket_State(t *testing.T) {
+	name := "test111"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Empty(t, basket.GetState(), "state is expected to be empty")
+
+		assert.NoError(t, basket.SetStateValue("order-1", "{\"id\":1}"))
+		assert.NoError(t, basket.SetStateValue("count", "1"))
+		assert.NoError(t, basket.SetStateValue("count", "2"))
+		assert.Error(t, basket.SetStateValue("", "empty key"))
+
+		value, exists := basket.GetStateValue("count")
+		assert.True(t, exists, "state value is expected")
+		assert.Equal(t, "2", value, "wrong state value")
+		_, exists = basket.GetStateValue("unknown")
+		assert.False(t, exists, "state value is not expected")
+		assert.Equal(t, map[string]string{"count": "2", "order-1": "{\"id\":1}"}, basket.GetState(), "wrong state")
+
+		basket.DeleteStateValue("count")
+		assert.Equal(t, map[string]string{"order-1": "{\"id\":1}"}, basket.GetState(), "wrong state")
+
+		basket.ClearState()
+		assert.Empty(t, basket.GetState(), "state is expected to be empty")
+	}
+}
+
+func TestSQLiteBasket_ForwardRetries(t *testing.T) {
+	name := "test112"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Empty(t, basket.GetForwardRetries(false), "forward retries are not expected")
+
+		request := &RequestData{Date: 1, Method: "POST", Body: "{}"}
+		retry := &ForwardRetry{ID: "r1", Target: ForwardTarget{URL: "http://localhost:81"}, Request: request, Attempts: 1}
+		assert.NoError(t, basket.SaveForwardRetry(retry))
+		assert.NoError(t, basket.SaveForwardRetry(&ForwardRetry{ID: "r2", Request: request, Attempts: 3, Dead: true}))
+
+		retries := basket.GetForwardRetries(false)
+		if assert.Len(t, retries, 1, "wrong number of forward retries") {
+			assert.Equal(t, "r1", retries[0].ID, "wrong forward retry")
+			assert.Equal(t, "http://localhost:81", retries[0].Target.URL, "wrong forward target")
+			assert.Equal(t, "{}", retries[0].Request.Body, "wrong forwarded request")
+		}
+
+		// update retry
+		retry.Attempts = 2
+		retry.LastStatus = 503
+		assert.NoError(t, basket.SaveForwardRetry(retry))
+		if saved := basket.GetForwardRetry("r1"); assert.NotNil(t, saved, "forward retry is expected") {
+			assert.Equal(t, 2, saved.Attempts, "wrong number of attempts")
+			assert.Equal(t, 503, saved.LastStatus, "wrong last status")
+		}
+
+		dead := basket.GetForwardRetries(true)
+		if assert.Len(t, dead, 1, "wrong number of dead letters") {
+			assert.Equal(t, "r2", dead[0].ID, "wrong dead letter")
+		}
+
+		basket.DeleteForwardRetry("r1")
+		assert.Nil(t, basket.GetForwardRetry("r1"), "forward retry is not expected")
+		assert.Empty(t, basket.GetForwardRetries(false), "forward retries are not expected")
+	}
+}
+
+func TestSQLiteBasket_WebSocketSessions(t *testing.T) {
+	name := "test113"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Empty(t, basket.GetWebSocketSessions(), "WebSocket sessions are not expected")
+
+		session := &WebSocketSession{ID: "s1", Date: 1, Path: "/" + name + "/ws", FrameCount: 1,
+			Frames: []WebSocketFrame{{Direction: WebSocketInbound, Opcode: 1, Payload: "hello", Size: 5, Date: 2}}}
+		assert.NoError(t, basket.SaveWebSocketSession(session))
+		assert.NoError(t, basket.SaveWebSocketSession(&WebSocketSession{ID: "s2", Date: 3}))
+
+		sessions := basket.GetWebSocketSessions()
+		if assert.Len(t, sessions, 2, "wrong number of WebSocket sessions") {
+			assert.Equal(t, "s2", sessions[0].ID, "newest session is expected first")
+			assert.Equal(t, "s1", sessions[1].ID, "wrong WebSocket session")
+		}
+
+		// update session
+		session.Closed = 4
+		session.FrameCount = 2
+		session.Frames = append(session.Frames, WebSocketFrame{Direction: WebSocketOutbound, Opcode: 8, Date: 4})
+		assert.NoError(t, basket.SaveWebSocketSession(session))
+		if saved := basket.GetWebSocketSession("s1"); assert.NotNil(t, saved, "WebSocket session is expected") {
+			assert.Equal(t, int64(4), saved.Closed, "wrong close date")
+			if assert.Len(t, saved.Frames, 2, "wrong number of frames") {
+				assert.Equal(t, "hello", saved.Frames[0].Payload, "wrong frame payload")
+				assert.Equal(t, WebSocketOutbound, saved.Frames[1].Direction, "wrong frame direction")
+			}
+		}
+
+		basket.DeleteWebSocketSession("s1")
+		assert.Nil(t, basket.GetWebSocketSession("s1"), "WebSocket session is not expected")
+		assert.Len(t, basket.GetWebSocketSessions(), 1, "wrong number of WebSocket sessions")
+	}
+}
+
+func TestSQLiteBasket_ResponsePayload(t *testing.T) {
+	name := "test114"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		assert.Nil(t, basket.GetResponsePayload("GET", ""), "payload is not expected")
+
+		// payloads are kept apart from response configuration
+		basket.SetResponse("GET", ResponseConfig{Status: 200, Payload: []byte{0, 1, 2}, PayloadSize: 3})
+		basket.SetPathResponse("GET", "/files/:id", ResponseConfig{Status: 200, Payload: []byte{3, 4}, PayloadSize: 2})
+		if response := basket.GetResponse("GET"); assert.NotNil(t, response, "response is expected") {
+			assert.Empty(t, response.Payload, "payload should not be loaded with response configuration")
+			assert.Equal(t, 3, response.PayloadSize, "wrong payload size")
+		}
+		assert.Empty(t, basket.GetPathResponses("GET")["/files/:id"].Payload, "payload should not be loaded")
+		assert.Equal(t, []byte{0, 1, 2}, basket.GetResponsePayload("GET", ""), "wrong payload of response")
+		assert.Equal(t, []byte{3, 4}, basket.GetResponsePayload("GET", "/files/:id"), "wrong payload of path response")
+
+		// payload is replaced along with response
+		basket.SetResponse("GET", ResponseConfig{Status: 204})
+		assert.Nil(t, basket.GetResponsePayload("GET", ""), "payload should be deleted")
+
+		basket.DeletePathResponse("GET", "/files/:id")
+		assert.Nil(t, basket.GetResponsePayload("GET", "/files/:id"), "payload of deleted response is not expected")
+	}
+}
+
+func TestSQLiteBasket_GetRequests(t *testing.T) {
+	name := "test105"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 25})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 1; i <= 35; i++ {
+			basket.Add(createTestPOSTRequest(
+				fmt.Sprintf("http://localhost/%v/demo?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain"))
+			time.Sleep(20 * time.Millisecond)
+		}
+		assert.Equal(t, 25, basket.Size(), "wrong basket size")
+
+		// Get and validate last 10 requests
+		page1 := basket.GetRequests(10, 0)
+		assert.True(t, page1.HasMore, "expected more requests")
+		assert.Len(t, page1.Requests, 10, "wrong page size")
+		assert.Equal(t, 25, page1.Count, "wrong requests count")
+		assert.Equal(t, 35, page1.TotalCount, "wrong requests total count")
+		assert.Equal(t, "req35", page1.Requests[0].Body, "last request #35 is expected at index #0")
+
+		// Get and validate 10 requests, skip 20
+		page3 := basket.GetRequests(10, 20)
+		assert.False(t, page3.HasMore, "no more requests are expected")
+		assert.Len(t, page3.Requests, 5, "wrong page size")
+		assert.Equal(t, 25, page3.Count, "wrong requests count")
+		assert.Equal(t, 35, page3.TotalCount, "wrong requests total count")
+		assert.Equal(t, "req15", page3.Requests[0].Body, "request #15 is expected at index #0")
+
+		// Get only collected statistics
+		page0 := basket.GetRequests(0, 0)
+		assert.True(t, page0.HasMore, "expected more requests")
+		assert.Empty(t, page0.Requests, "requests are not expected")
+		assert.Equal(t, 25, page1.Count, "wrong requests count")
+		assert.Equal(t, 35, page1.TotalCount, "wrong requests total count")
+	}
+}
+
+func TestSQLiteBasket_FindRequests(t *testing.T) {
+	name := "test106"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 100})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// fill basket
+		for i := 1; i <= 30; i++ {
+			r := createTestPOSTRequest(fmt.Sprintf("http://localhost/%v?id=%v", name, i), fmt.Sprintf("req%v", i), "text/plain")
+			r.Header.Add("HeaderId", fmt.Sprintf("header%v", i))
+			if i <= 10 {
+				r.Header.Add("ChocoPie", "yummy")
+			}
+			if i <= 20 {
+				r.Header.Add("Muffin", "tasty")
+			}
+			basket.Add(r)
+		}
+		assert.Equal(t, 30, basket.Size(), "wrong basket size")
+
+		// search everywhere
+		s1 := basket.FindRequests("req1", "any", 30, 0)
+		assert.False(t, s1.HasMore, "no more results are expected")
+		assert.Len(t, s1.Requests, 11, "wrong number of found requests")
+		for _, r := range s1.Requests {
+			assert.Contains(t, r.Body, "req1", "incorrect request among results")
+		}
+
+		// search everywhere (limited output)
+		s2 := basket.FindRequests("req2", "any", 5, 5)
+		assert.True(t, s2.HasMore, "more results are expected")
+		assert.Len(t, s2.Requests, 5, "wrong number of found requests")
+
+		// search everywhere with max = 0
+		assert.Empty(t, basket.FindRequests("req2", "any", 0, 0).Requests, "found unexpected requests")
+
+		// search in body (positive)
+		assert.Len(t, basket.FindRequests("req3", "body", 100, 0).Requests, 2, "wrong number of found requests")
+		// search in body (negative)
+		assert.Empty(t, basket.FindRequests("yummy", "body", 100, 0).Requests, "found unexpected requests")
+
+		// search in headers (positive)
+		assert.Len(t, basket.FindRequests("yummy", "headers", 100, 0).Requests, 10, "wrong number of found requests")
+		assert.Len(t, basket.FindRequests("tasty", "headers", 100, 0).Requests, 20, "wrong number of found requests")
+		// search in headers (negative)
+		assert.Empty(t, basket.FindRequests("req1", "headers", 100, 0).Requests, "found unexpected requests")
+
+		// search in query (positive)
+		assert.Len(t, basket.FindRequests("id=1", "query", 100, 0).Requests, 11, "wrong number of found requests")
+		// search in query (negative)
+		assert.Empty(t, basket.FindRequests("tasty", "query", 100, 0).Requests, "found unexpected requests")
+	}
+}
+
+func TestSQLiteBasket_SetResponse(t *testing.T) {
+	name := "test107"
+	method := "POST"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no response
+		assert.Nil(t, basket.GetResponse(method))
+
+		// Set response
+		basket.SetResponse(method, ResponseConfig{Status: 201, Body: "{ 'message' : 'created' }"})
+		// Get and validate
+		response := basket.GetResponse(method)
+		if assert.NotNil(t, response, "response for method: %v is expected", method) {
+			assert.Equal(t, 201, response.Status, "wrong HTTP response status")
+			assert.Equal(t, "{ 'message' : 'created' }", response.Body, "wrong HTTP response body")
+			assert.False(t, response.IsTemplate, "template is not expected")
+		}
+	}
+}
+
+func TestSQLiteBasket_SetResponse_Update(t *testing.T) {
+	name := "test108"
+	method := "GET"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Set response
+		basket.SetResponse(method, ResponseConfig{Status: 200, Body: ""})
+		// Update response
+		basket.SetResponse(method, ResponseConfig{Status: 200, Body: "welcome", IsTemplate: true})
+		// Get and validate
+		response := basket.GetResponse(method)
+		if assert.NotNil(t, response, "response for method: %v is expected", method) {
+			assert.Equal(t, 200, response.Status, "wrong HTTP response status")
+			assert.Equal(t, "welcome", response.Body, "wrong HTTP response body")
+			assert.True(t, response.IsTemplate, "template is expected")
+		}
+	}
+}
+
+func TestSQLiteBasket_Config_Error(t *testing.T) {
+	name := "test120"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 30, ForwardURL: "http://localhost:8080"})
+	basket := db.Get(name)
+	// delete basket
+	db.Delete(name)
+
+	// try to get configuration of deleted basket
+	config := basket.Config()
+	if assert.NotNil(t, config, "configuration is expected") {
+		// empty config is expected
+		assert.Equal(t, 0, config.Capacity, "Capacity is not expected")
+		assert.Empty(t, config.ForwardURL, "ForwardURL is not expected")
+	}
+}
+
+func TestSQLiteBasket_SetResponse_Error(t *testing.T) {
+	name := "test121"
+	method := "POST"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	basket := db.Get(name)
+	// delete basket, SQLite does not limit length of method name, but enforces foreign keys
+	db.Delete(name)
+
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Set response
+		basket.SetResponse(method, ResponseConfig{Status: 201, Body: "{ 'message' : 'created' }"})
+
+		// Ensure no response
+		assert.Nil(t, basket.GetResponse(method), "Response for deleted basket is not expected")
+	}
+}
+
+func TestSQLiteBasket_SetPathResponse(t *testing.T) {
+	name := "test109"
+	method := "GET"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	db.Create(name, BasketConfig{Capacity: 20})
+	defer db.Delete(name)
+
+	basket := db.Get(name)
+	if assert.NotNil(t, basket, "basket with name: %v is expected", name) {
+		// Ensure no path responses
+		assert.Empty(t, basket.GetPathResponses(method))
+
+		// Set responses
+		basket.SetPathResponse(method, "/v1/users/:id", ResponseConfig{Status: 200, Body: "user"})
+		basket.SetPathResponse(method, "/v1/orders", ResponseConfig{Status: 200, Body: "orders"})
+		basket.SetPathResponse(method, "/v1/orders", ResponseConfig{Status: 202, Body: "orders updated"})
+		basket.SetPathResponse("POST", "/v1/orders", ResponseConfig{Status: 201})
+
+		// Get and validate
+		responses := basket.GetPathResponses(method)
+		assert.Len(t, responses, 2, "wrong number of path responses")
+		if assert.NotNil(t, responses["/v1/orders"], "response for path is expected") {
+			assert.Equal(t, 202, responses["/v1/orders"].Status, "wrong HTTP response status")
+			assert.Equal(t, "orders updated", responses["/v1/orders"].Body, "wrong HTTP response body")
+		}
+		// default response is not affected
+		assert.Nil(t, basket.GetResponse(method))
+
+		// Delete and validate
+		basket.DeletePathResponse(method, "/v1/users/:id")
+		basket.DeletePathResponse(method, "/unknown")
+		responses = basket.GetPathResponses(method)
+		assert.Len(t, responses, 1, "wrong number of path responses")
+		assert.Nil(t, responses["/v1/users/:id"], "deleted path response is not expected")
+		assert.Len(t, basket.GetPathResponses("POST"), 1, "wrong number of path responses")
+	}
+}
+
+func TestSQLiteDatabase_GetStats(t *testing.T) {
+	name := "test130"
+	db := NewSQLDatabase(sqliteTestConnection)
+	defer db.Release()
+
+	config := BasketConfig{Capacity: 5}
+	for i := 0; i < 10; i++ {
+		bname := fmt.Sprintf("%s_%v", name, i)
+		db.Create(bname, config)
+		defer db.Delete(bname)
+
+		// fill basket
+		basket := db.Get(bname)
+		for j := 0; j < 9-i; j++ {
+			basket.Add(createTestPOSTRequest(
+				fmt.Sprintf("http://localhost/%v?id=%v", bname, j), fmt.Sprintf("req%v", j), "text/plain"))
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// get stats
+	stats := db.GetStats(3)
+	if assert.NotNil(t, stats, "database statistics is expected") {
+		assert.Equal(t, 10, stats.BasketsCount, "wrong BasketsCount stats")
+		assert.Equal(t, 1, stats.EmptyBasketsCount, "wrong EmptyBasketsCount stats")
+		assert.Equal(t, 9, stats.MaxBasketSize, "wrong MaxBasketSize stats")
+		assert.Equal(t, 35, stats.RequestsCount, "wrong RequestsCount stats")
+		assert.Equal(t, 45, stats.RequestsTotalCount, "wrong RequestsTotalCount stats")
+		assert.Equal(t, 5, stats.AvgBasketSize, "wrong AvgBasketSize stats")
+
+		// top 3 by date
+		if assert.NotNil(t, stats.TopBasketsByDate, "top baskets by date are expected") {
+			assert.Equal(t, 3, len(stats.TopBasketsByDate), "unexpected number of top baskets")
+			test_validateBasketStats(t, stats.TopBasketsByDate[0], fmt.Sprintf("%s_%v", name, 8), 1, 1)
+			test_validateBasketStats(t, stats.TopBasketsByDate[1], fmt.Sprintf("%s_%v", name, 7), 2, 2)
+			test_validateBasketStats(t, stats.TopBasketsByDate[2], fmt.Sprintf("%s_%v", name, 6), 3, 3)
+		}
+
+		// top 3 by size
+		if assert.NotNil(t, stats.TopBasketsBySize, "top baskets by size are expected") {
+			assert.Equal(t, 3, len(stats.TopBasketsBySize), "unexpected number of top baskets")
+			test_validateBasketStats(t, stats.TopBasketsBySize[0], fmt.Sprintf("%s_%v", name, 0), 5, 9)
+			test_validateBasketStats(t, stats.TopBasketsBySize[1], fmt.Sprintf("%s_%v", name, 1), 5, 8)
+			test_validateBasketStats(t, stats.TopBasketsBySize[2], fmt.Sprintf("%s_%v", name, 2), 5, 7)
+		}
+	}
+}
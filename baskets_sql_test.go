@@ -3,6 +3,7 @@ package main
 import (
 	"database/sql"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -198,3 +199,27 @@ func TestSQLBasket_applyLimit_SQLError(t *testing.T) {
 	basket.applyLimit(-1)
 	// TODO: find out how to capture the log output for validation
 }
+
+func TestSQLDatabase_parseConnection(t *testing.T) {
+	driver, source := parseConnection("mysql://rbaskets:pwd@/baskets")
+	assert.Equal(t, "mysql", driver)
+	assert.Equal(t, "rbaskets:pwd@/baskets?parseTime=true", source)
+
+	driver, source = parseConnection("sqlite3://./baskets.db")
+	assert.Equal(t, "sqlite", driver, "pure Go driver of SQLite is expected")
+	assert.Equal(t, "file:./baskets.db?"+sqlitePragmas, source)
+
+	driver, source = parseConnection("sqlite://./baskets.db?mode=rwc")
+	assert.Equal(t, "sqlite", driver)
+	assert.Equal(t, "file:./baskets.db?mode=rwc&"+sqlitePragmas, source)
+}
+
+func TestSQLDatabase_toTime(t *testing.T) {
+	date, err := toTime("2024-03-01 10:20:30.456")
+	if assert.NoError(t, err) {
+		assert.Equal(t, time.Date(2024, 3, 1, 10, 20, 30, 456000000, time.UTC), date)
+	}
+
+	_, err = toTime(nil)
+	assert.Error(t, err, "unexpected timestamp value")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maxCapturedResponseSize defines how many bytes of proxied response body are kept with collected request
+const maxCapturedResponseSize = 64 * 1024
+
+type proxiedResponseKey struct{}
+
+type peekedRequestKey struct{}
+
+type truncatedBodyKey struct{}
+
+// withProxiedResponse attaches response of forward target to HTTP request, so it is stored with collected request data
+func withProxiedResponse(r *http.Request, response *ResponseData) *http.Request {
+	if response == nil {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), proxiedResponseKey{}, response))
+}
+
+// proxiedResponse retrieves response of forward target attached to HTTP request
+func proxiedResponse(r *http.Request) *ResponseData {
+	response, _ := r.Context().Value(proxiedResponseKey{}).(*ResponseData)
+	return response
+}
+
+// withTruncatedBody marks HTTP request which body is only partially collected
+func withTruncatedBody(r *http.Request) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), truncatedBodyKey{}, true))
+}
+
+// isTruncatedBody checks whether body of HTTP request is only partially collected
+func isTruncatedBody(r *http.Request) bool {
+	truncated, _ := r.Context().Value(truncatedBodyKey{}).(bool)
+	return truncated
+}
+
+// newResponseData captures status and headers of target response, the body is captured while it is passed
+// to the client
+func newResponseData(response *http.Response) *ResponseData {
+	return &ResponseData{Status: response.StatusCode, Header: response.Header.Clone()}
+}
+
+// peekRequestData converts HTTP request into request data before it is collected, the request body is replaced
+// with a copy, so the request can be collected later; the returned HTTP request is collected with the same ID as
+// the peeked request data, so forwards and retries refer to the collected request
+func peekRequestData(r *http.Request) (*http.Request, *RequestData) {
+	data := ToRequestData(r)
+	r.Body = ioutil.NopCloser(strings.NewReader(data.Body))
+	return r.WithContext(context.WithValue(r.Context(), peekedRequestKey{}, data)), data
+}
+
+// peekedRequest retrieves request data peeked from HTTP request before it is collected
+func peekedRequest(r *http.Request) *RequestData {
+	data, _ := r.Context().Value(peekedRequestKey{}).(*RequestData)
+	return data
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeekRequestData(t *testing.T) {
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/peek?id=1", strings.NewReader("hello"))
+	r, data := peekRequestData(r)
+	assert.Equal(t, "hello", data.Body, "wrong body of request data")
+	assert.Equal(t, "id=1", data.Query, "wrong query of request data")
+
+	collected := ToRequestData(r)
+	assert.Equal(t, "hello", collected.Body, "request body should be available after peek")
+	assert.Equal(t, data.ID, collected.ID, "collected request should keep ID of peeked one")
+	assert.Equal(t, data.Date, collected.Date, "collected request should keep date of peeked one")
+}
+
+func TestAcceptBasketRequests_CaptureProxiedResponse(t *testing.T) {
+	basket := "capture01"
+
+	large := bytes.Repeat([]byte("x"), maxCapturedResponseSize+100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Target", "yes")
+		if r.Method == "GET" {
+			w.Write(large)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("accepted"))
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL, ProxyResponse: true})
+
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("ping"))
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+	assert.Equal(t, 202, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "accepted", w.Body.String(), "wrong proxied response")
+
+	r = httptest.NewRequest("GET", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	w = httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+	assert.Equal(t, len(large), w.Body.Len(), "full response should be proxied")
+
+	page := basketsDb.Get(basket).GetRequests(10, 0)
+	if assert.Len(t, page.Requests, 2, "requests should be collected") {
+		get, post := page.Requests[0], page.Requests[1]
+		assert.Equal(t, "ping", post.Body, "wrong collected request body")
+		if assert.NotNil(t, post.Response, "proxied response should be captured") {
+			assert.Equal(t, 202, post.Response.Status, "wrong captured status")
+			assert.Equal(t, "yes", post.Response.Header.Get("X-Target"), "wrong captured header")
+			assert.Equal(t, "accepted", post.Response.Body, "wrong captured body")
+			assert.False(t, post.Response.Truncated, "body should not be truncated")
+		}
+		if assert.NotNil(t, get.Response, "proxied response should be captured") {
+			assert.Equal(t, maxCapturedResponseSize, len(get.Response.Body), "captured body should be truncated")
+			assert.True(t, get.Response.Truncated, "body should be marked as truncated")
+		}
+	}
+}
+
+func TestAcceptBasketRequests_NoCaptureWithoutProxy(t *testing.T) {
+	basket := "capture02"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL})
+
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("ping"))
+	AcceptBasketRequests(httptest.NewRecorder(), r)
+
+	page := basketsDb.Get(basket).GetRequests(10, 0)
+	if assert.Len(t, page.Requests, 1, "request should be collected") {
+		assert.Nil(t, page.Requests[0].Response, "response should not be captured")
+	}
+}
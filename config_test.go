@@ -16,9 +16,21 @@ func TestCreateDefaultConfig(t *testing.T) {
 		assert.Equal(t, defaultPageSize, serverConfig.PageSize, "wrong page size")
 		assert.Equal(t, "./baskets.db", serverConfig.DbFile, "wrong DB file location")
 		assert.NotEmpty(t, serverConfig.MasterToken, "expected randomly generated master token")
+		assert.Equal(t, defaultForwardTimeout, serverConfig.HTTPClient.Timeout, "wrong forward timeout")
+		assert.Equal(t, defaultForwardConnectTimeout, serverConfig.HTTPClient.ConnectTimeout,
+			"wrong forward connect timeout")
+		assert.Equal(t, defaultForwardIdleConns, serverConfig.HTTPClient.MaxIdleConns, "wrong max idle connections")
+		assert.Equal(t, defaultMaxForwardHops, serverConfig.MaxForwardHops, "wrong max forward hops")
+		assert.False(t, serverConfig.Migrate, "schema migration is not expected by default")
+		assert.False(t, serverConfig.AutoMigrate, "automatic schema migration is not expected by default")
 	}
 }
 
+func TestSplitList(t *testing.T) {
+	assert.Equal(t, []string{"localhost", ".example.com", "10.0.0.0/8"}, splitList("localhost, .example.com,,10.0.0.0/8 "))
+	assert.Nil(t, splitList(""))
+}
+
 func TestArrayFlags(t *testing.T) {
 	var flags arrayFlags
 	flags.Set("123")
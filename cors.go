@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const maxCORSMaxAge = 24 * 60 * 60 // 1 day in sec.
+
+// validateCORSConfig validates CORS configuration of basket
+func validateCORSConfig(cors *CORSConfig) error {
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || len(u.Scheme) == 0 || len(u.Host) == 0 || (len(u.Path) > 0 && u.Path != "/") {
+			return fmt.Errorf("invalid CORS origin: %s, expected value like https://example.com or *", origin)
+		}
+	}
+
+	for _, method := range cors.AllowedMethods {
+		if _, err := validateMethod(method); err != nil {
+			return err
+		}
+	}
+
+	for _, header := range append(append([]string{}, cors.AllowedHeaders...), cors.ExposedHeaders...) {
+		if len(header) == 0 || strings.ContainsAny(header, " \t,:") {
+			return fmt.Errorf("invalid CORS header name: %q", header)
+		}
+	}
+
+	if cors.MaxAge < 0 || cors.MaxAge > maxCORSMaxAge {
+		return fmt.Errorf("CORS max age should be within [0, %d] seconds range, but was %d", maxCORSMaxAge, cors.MaxAge)
+	}
+
+	return nil
+}
+
+// isPreflightRequest checks if HTTP request is a CORS preflight request
+func isPreflightRequest(r *http.Request) bool {
+	return r.Method == http.MethodOptions && len(r.Header.Get("Origin")) > 0 &&
+		len(r.Header.Get("Access-Control-Request-Method")) > 0
+}
+
+// allowsOrigin checks if the given origin is allowed, wildcards like "https://*.example.com" are supported
+func (cors *CORSConfig) allowsOrigin(origin string) bool {
+	if len(cors.AllowedOrigins) == 0 {
+		return true
+	}
+
+	for _, allowed := range cors.AllowedOrigins {
+		allowed = strings.TrimSuffix(allowed, "/")
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+		if i := strings.Index(allowed, "*"); i >= 0 {
+			prefix, suffix := strings.ToLower(allowed[:i]), strings.ToLower(allowed[i+1:])
+			lower := strings.ToLower(origin)
+			if len(lower) > len(prefix)+len(suffix) && strings.HasPrefix(lower, prefix) && strings.HasSuffix(lower, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowsAnyOrigin checks if all origins are allowed
+func (cors *CORSConfig) allowsAnyOrigin() bool {
+	if len(cors.AllowedOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range cors.AllowedOrigins {
+		if allowed == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCORSHeaders decorates response to HTTP request with CORS headers, returns false if origin is not allowed
+func writeCORSHeaders(w http.ResponseWriter, r *http.Request, cors *CORSConfig) bool {
+	header := w.Header()
+	origin := r.Header.Get("Origin")
+	if len(origin) == 0 {
+		return true
+	}
+
+	if !cors.allowsAnyOrigin() || cors.AllowCredentials {
+		header.Add("Vary", "Origin")
+	}
+	if !cors.allowsOrigin(origin) {
+		return false
+	}
+
+	// wildcard cannot be used along with credentials
+	if cors.allowsAnyOrigin() && !cors.AllowCredentials {
+		header.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		header.Set("Access-Control-Allow-Origin", origin)
+	}
+	if cors.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !isPreflightRequest(r) {
+		if len(cors.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposedHeaders, ", "))
+		}
+		return true
+	}
+
+	// preflight request: requested method and headers are allowed if not restricted
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	if len(cors.AllowedMethods) > 0 {
+		header.Set("Access-Control-Allow-Methods", strings.ToUpper(strings.Join(cors.AllowedMethods, ", ")))
+	} else {
+		header.Set("Access-Control-Allow-Methods", r.Header.Get("Access-Control-Request-Method"))
+	}
+	if len(cors.AllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+	} else if requested := r.Header.Get("Access-Control-Request-Headers"); len(requested) > 0 {
+		header.Set("Access-Control-Allow-Headers", requested)
+	}
+	if cors.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+	}
+
+	return true
+}
+
+// isCORSHeader checks if header controls CORS, such headers of proxied responses are replaced by basket settings
+func isCORSHeader(name string) bool {
+	return strings.HasPrefix(http.CanonicalHeaderKey(name), "Access-Control-")
+}
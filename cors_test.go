@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCORSConfig(t *testing.T) {
+	assert.NoError(t, validateCORSConfig(&CORSConfig{}))
+	assert.NoError(t, validateCORSConfig(&CORSConfig{
+		AllowedOrigins: []string{"*", "https://app.example.com", "https://*.example.com", "http://localhost:3000/"},
+		AllowedMethods: []string{"get", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-Api-Key"},
+		ExposedHeaders: []string{"X-Request-Id"},
+		MaxAge:         600}))
+
+	err := validateCORSConfig(&CORSConfig{AllowedOrigins: []string{"example.com"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid CORS origin: example.com")
+	}
+	assert.Error(t, validateCORSConfig(&CORSConfig{AllowedOrigins: []string{"https://example.com/app"}}))
+	assert.Error(t, validateCORSConfig(&CORSConfig{AllowedMethods: []string{"SEND"}}))
+	assert.Error(t, validateCORSConfig(&CORSConfig{AllowedHeaders: []string{"X-A, X-B"}}))
+	assert.Error(t, validateCORSConfig(&CORSConfig{ExposedHeaders: []string{""}}))
+	assert.Error(t, validateCORSConfig(&CORSConfig{MaxAge: -1}))
+	assert.Error(t, validateCORSConfig(&CORSConfig{MaxAge: maxCORSMaxAge + 1}))
+}
+
+func TestCORSConfig_AllowsOrigin(t *testing.T) {
+	assert.True(t, (&CORSConfig{}).allowsOrigin("https://any.org"))
+	assert.True(t, (&CORSConfig{AllowedOrigins: []string{"*"}}).allowsOrigin("https://any.org"))
+
+	cors := &CORSConfig{AllowedOrigins: []string{"https://app.example.com/", "https://*.test.org"}}
+	assert.True(t, cors.allowsOrigin("https://app.example.com"))
+	assert.True(t, cors.allowsOrigin("https://APP.example.com"))
+	assert.True(t, cors.allowsOrigin("https://a.b.test.org"))
+	assert.False(t, cors.allowsOrigin("https://.test.org"))
+	assert.False(t, cors.allowsOrigin("http://app.example.com"))
+	assert.False(t, cors.allowsOrigin("https://evil.org"))
+	assert.False(t, cors.allowsAnyOrigin())
+}
+
+func TestWriteCORSHeaders(t *testing.T) {
+	cors := &CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true,
+		ExposedHeaders: []string{"X-Request-Id"}}
+
+	// no origin - not a CORS request
+	w := httptest.NewRecorder()
+	assert.True(t, writeCORSHeaders(w, httptest.NewRequest("GET", "/r/demo", nil), cors))
+	assert.Empty(t, w.Header())
+
+	r := httptest.NewRequest("GET", "/r/demo", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	w = httptest.NewRecorder()
+	assert.True(t, writeCORSHeaders(w, r, cors))
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "X-Request-Id", w.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, "Origin", w.Header().Get("Vary"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"), "methods are only expected for preflight requests")
+
+	r.Header.Set("Origin", "https://evil.org")
+	w = httptest.NewRecorder()
+	assert.False(t, writeCORSHeaders(w, r, cors))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// preflight, methods and headers are reflected if not restricted
+	r = httptest.NewRequest("OPTIONS", "/r/demo", nil)
+	r.Header.Set("Origin", "https://any.org")
+	r.Header.Set("Access-Control-Request-Method", "PUT")
+	r.Header.Set("Access-Control-Request-Headers", "content-type,x-api-key")
+	w = httptest.NewRecorder()
+	assert.True(t, writeCORSHeaders(w, r, &CORSConfig{MaxAge: 300}))
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "PUT", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type,x-api-key", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "300", w.Header().Get("Access-Control-Max-Age"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+	w = httptest.NewRecorder()
+	writeCORSHeaders(w, r, &CORSConfig{AllowedMethods: []string{"get", "post"}, AllowedHeaders: []string{"Content-Type"}})
+	assert.Equal(t, "GET, POST", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+}
+
+func TestAcceptBasketRequests_CORSPreflight(t *testing.T) {
+	basket := "cors01"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, CORS: &CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}})
+
+	r, err := http.NewRequest("OPTIONS", "http://localhost:55555/r/"+basket+"/events", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Set("Origin", "https://app.example.com")
+		r.Header.Set("Access-Control-Request-Method", "POST")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		// validate response: 204 - no content
+		assert.Equal(t, 204, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "POST", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, 1, basketsDb.Get(basket).GetRequests(5, 0).TotalCount, "preflight request is expected to be collected")
+	}
+
+	// do not collect preflight requests
+	config := basketsDb.Get(basket).Config()
+	config.CORS.SkipPreflight = true
+	basketsDb.Get(basket).Update(config)
+
+	r, err = http.NewRequest("OPTIONS", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Set("Origin", "https://app.example.com")
+		r.Header.Set("Access-Control-Request-Method", "DELETE")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 204, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, 1, basketsDb.Get(basket).GetRequests(5, 0).TotalCount, "preflight request is not expected to be collected")
+	}
+
+	// plain OPTIONS request is not a preflight
+	r, err = http.NewRequest("OPTIONS", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+		assert.Equal(t, 2, basketsDb.Get(basket).GetRequests(5, 0).TotalCount, "request is expected to be collected")
+	}
+}
+
+func TestAcceptBasketRequests_CORSResponse(t *testing.T) {
+	basket := "cors02"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, CORS: &CORSConfig{ExposedHeaders: []string{"X-Custom"}}})
+	basketsDb.Get(basket).SetResponse("POST", ResponseConfig{Status: 201, Headers: http.Header{"X-Custom": {"1"}}, Body: "ok"})
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("{}"))
+	if assert.NoError(t, err) {
+		r.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 201, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "ok", w.Body.String(), "wrong HTTP response body")
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "X-Custom", w.Header().Get("Access-Control-Expose-Headers"))
+	}
+}
+
+func TestAcceptBasketRequests_CORSWithProxyResponse(t *testing.T) {
+	basket := "cors03"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "https://backend.org")
+		w.Header().Set("X-Backend", "1")
+		w.WriteHeader(202)
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL, ProxyResponse: true,
+		CORS: &CORSConfig{AllowedOrigins: []string{"https://app.example.com"}}})
+
+	r, err := http.NewRequest("GET", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Set("Origin", "https://app.example.com")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 202, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "1", w.Header().Get("X-Backend"), "wrong proxied header")
+		assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
@@ -18,6 +18,10 @@ if [ -n "$CONN" ]; then
     args="$args -conn $CONN"
 fi
 
+if [ "$AUTO_MIGRATE" = "true" ]; then
+    args="$args -auto-migrate"
+fi
+
 if [ -n "$PORT" ]; then
     args="$args -p $PORT"
 fi
@@ -50,6 +54,50 @@ if [ -n "$MODE" ]; then
     args="$args -mode $MODE"
 fi
 
+if [ -n "$FORWARD_TIMEOUT" ]; then
+    args="$args -forward-timeout $FORWARD_TIMEOUT"
+fi
+
+if [ -n "$FORWARD_CONNECT_TIMEOUT" ]; then
+    args="$args -forward-connect-timeout $FORWARD_CONNECT_TIMEOUT"
+fi
+
+if [ -n "$FORWARD_CA" ]; then
+    args="$args -forward-ca $FORWARD_CA"
+fi
+
+if [ -n "$FORWARD_CERT" ]; then
+    args="$args -forward-cert $FORWARD_CERT"
+fi
+
+if [ -n "$FORWARD_KEY" ]; then
+    args="$args -forward-key $FORWARD_KEY"
+fi
+
+if [ -n "$FORWARD_PROXY" ]; then
+    args="$args -forward-proxy $FORWARD_PROXY"
+fi
+
+if [ -n "$FORWARD_NO_PROXY" ]; then
+    args="$args -forward-no-proxy $FORWARD_NO_PROXY"
+fi
+
+if [ -n "$FORWARD_ALLOW" ]; then
+    args="$args -forward-allow $FORWARD_ALLOW"
+fi
+
+if [ "$FORWARD_ALLOW_PRIVATE" = "true" ]; then
+    args="$args -forward-allow-private"
+fi
+
+if [ "$FORWARD_ALLOW_DIRECT" = "true" ]; then
+    args="$args -forward-allow-direct"
+fi
+
+if [ -n "$FORWARD_MAX_HOPS" ]; then
+    args="$args -forward-max-hops $FORWARD_MAX_HOPS"
+fi
+
 cmd="/bin/rbaskets $args"
 echo "Executing: $cmd"
 exec $cmd
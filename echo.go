@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Supported echo modes of basket responses
+const (
+	EchoRequest = "request"
+	EchoBody    = "body"
+)
+
+// echoedRequest describes collected HTTP request returned in basket response in "request" echo mode
+type echoedRequest struct {
+	Method        string      `json:"method"`
+	Path          string      `json:"path"`
+	Query         url.Values  `json:"query"`
+	Headers       http.Header `json:"headers"`
+	ContentLength int64       `json:"content_length"`
+	Body          string      `json:"body"`
+	JSON          interface{} `json:"json,omitempty"`
+}
+
+// validateEchoMode validates echo mode of basket response
+func validateEchoMode(mode string) error {
+	switch mode {
+	case "", EchoRequest, EchoBody:
+		return nil
+	default:
+		return fmt.Errorf("unknown echo mode of response: %s", mode)
+	}
+}
+
+// echoRequest builds body and content type of basket response that echoes collected HTTP request
+func echoRequest(mode string, request *RequestData) (string, []byte) {
+	if mode == EchoBody {
+		return request.Header.Get("Content-Type"), []byte(request.Body)
+	}
+
+	query, _ := url.ParseQuery(request.Query)
+	echoed := echoedRequest{
+		Method:        request.Method,
+		Path:          request.Path,
+		Query:         query,
+		Headers:       request.Header,
+		ContentLength: request.ContentLength,
+		Body:          request.Body}
+
+	// JSON body is provided in parsed form as well
+	if len(request.Body) > 0 {
+		json.Unmarshal([]byte(request.Body), &echoed.JSON)
+	}
+
+	body, err := json.MarshalIndent(echoed, "", "  ")
+	if err != nil {
+		body = []byte(fmt.Sprintf("{\"error\": %q}", err.Error()))
+	}
+	return "application/json; charset=UTF-8", body
+}
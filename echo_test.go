@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateEchoMode(t *testing.T) {
+	assert.NoError(t, validateEchoMode(""))
+	assert.NoError(t, validateEchoMode(EchoRequest))
+	assert.NoError(t, validateEchoMode(EchoBody))
+
+	err := validateEchoMode("headers")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown echo mode of response: headers")
+	}
+}
+
+func TestEchoRequest(t *testing.T) {
+	request := &RequestData{Method: "POST", Path: "/demo/items", Query: "a=1&a=2&b=x", ContentLength: 11,
+		Header: http.Header{"Content-Type": {"application/json"}}, Body: "{\"id\": 42}"}
+
+	contentType, body := echoRequest(EchoBody, request)
+	assert.Equal(t, "application/json", contentType)
+	assert.Equal(t, "{\"id\": 42}", string(body))
+
+	contentType, body = echoRequest(EchoRequest, request)
+	assert.Equal(t, "application/json; charset=UTF-8", contentType)
+	echoed := new(echoedRequest)
+	if assert.NoError(t, json.Unmarshal(body, echoed)) {
+		assert.Equal(t, "POST", echoed.Method)
+		assert.Equal(t, "/demo/items", echoed.Path)
+		assert.Equal(t, []string{"1", "2"}, echoed.Query["a"])
+		assert.Equal(t, "application/json", echoed.Headers.Get("Content-Type"))
+		assert.Equal(t, int64(11), echoed.ContentLength)
+		assert.Equal(t, "{\"id\": 42}", echoed.Body)
+		assert.Equal(t, map[string]interface{}{"id": float64(42)}, echoed.JSON)
+	}
+
+	// not a JSON body
+	_, body = echoRequest(EchoRequest, &RequestData{Method: "PUT", Body: "plain text"})
+	assert.NotContains(t, string(body), "\"json\"")
+}
+
+func TestAcceptBasketRequests_EchoRequest(t *testing.T) {
+	basket := "echo01"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("POST", ResponseConfig{Status: 202, Echo: EchoRequest,
+		Headers: http.Header{"X-Echo": {"yes"}}})
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket+"/anything?q=1", strings.NewReader("{\"name\":\"Joe\"}"))
+	if assert.NoError(t, err) {
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		// validate response
+		assert.Equal(t, 202, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "yes", w.Header().Get("X-Echo"), "wrong HTTP response header")
+		assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+		echoed := new(echoedRequest)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), echoed)) {
+			assert.Equal(t, "/r/"+basket+"/anything", echoed.Path)
+			assert.Equal(t, "1", echoed.Query.Get("q"))
+			assert.Equal(t, "{\"name\":\"Joe\"}", echoed.Body)
+			assert.Equal(t, map[string]interface{}{"name": "Joe"}, echoed.JSON)
+		}
+
+		// request is still collected
+		request := basketsDb.Get(basket).GetRequests(1, 0).Requests[0]
+		assert.Equal(t, "{\"name\":\"Joe\"}", request.Body, "wrong collected body")
+	}
+}
+
+func TestAcceptBasketRequests_EchoBody(t *testing.T) {
+	basket := "echo02"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("PUT", ResponseConfig{Status: 200, Echo: EchoBody})
+
+	r, err := http.NewRequest("PUT", "http://localhost:55555/r/"+basket, strings.NewReader("<note>hello</note>"))
+	if assert.NoError(t, err) {
+		r.Header.Set("Content-Type", "application/xml")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "application/xml", w.Header().Get("Content-Type"), "wrong Content-Type")
+		assert.Equal(t, "<note>hello</note>", w.Body.String(), "wrong HTTP response body")
+	}
+
+	// echo mode is configured per method
+	r, err = http.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("ping"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+		assert.Empty(t, w.Body.String(), "wrong HTTP response body")
+	}
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+)
+
+const maxEgressAllowEntries = 100
+
+// EgressPolicy describes network addresses forwarded requests may connect to. Loopback, private and link-local
+// addresses (e.g. cloud metadata service) are denied unless private addresses are allowed explicitly or the host
+// matches allowlist entry: IP address, CIDR or domain name that also matches its sub-domains. Baskets may bypass
+// outbound proxy of the server only if direct connections are allowed.
+type EgressPolicy struct {
+	AllowPrivate bool
+	AllowDirect  bool
+	Allow        []string
+}
+
+// EgressDeniedError indicates that forwarding to a host is denied by egress policy
+type EgressDeniedError struct {
+	Host string
+	IP   net.IP
+	Kind string
+}
+
+func (err *EgressDeniedError) Error() string {
+	if err.Host == err.IP.String() {
+		return fmt.Sprintf("forwarding to %s address %s is denied by egress policy", err.Kind, err.IP)
+	}
+	return fmt.Sprintf("forwarding to %s (%s address %s) is denied by egress policy", err.Host, err.Kind, err.IP)
+}
+
+// egressPolicy is applied to all connections of forwarded requests, private addresses are denied by default
+var egressPolicy = EgressPolicy{}
+
+// operatorProxies lists addresses of outbound proxies configured by operator of the service (server settings or
+// environment), connections to these proxies are trusted and not restricted by egress policy
+var operatorProxies []string
+
+// restrictedNetworks lists private and special purpose networks that are denied by default along with loopback
+// and link-local addresses
+var restrictedNetworks = parseNetworks(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"172.16.0.0/12",  // private
+	"192.168.0.0/16", // private
+	"fc00::/7")       // unique local
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, _ := net.ParseCIDR(cidr)
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// validateEgressPolicy validates allowlist of egress policy
+func validateEgressPolicy(policy EgressPolicy) error {
+	if len(policy.Allow) > maxEgressAllowEntries {
+		return fmt.Errorf("egress allowlist may not have more than %d entries", maxEgressAllowEntries)
+	}
+	for _, entry := range policy.Allow {
+		if len(entry) == 0 || entry == "*" || (strings.ContainsAny(entry, " \t/") && !isCIDR(entry)) {
+			return fmt.Errorf("invalid egress allowlist entry: %q", entry)
+		}
+	}
+	return nil
+}
+
+// nat64Networks lists prefixes of IPv6 addresses that embed IPv4 address in the last 4 bytes
+var nat64Networks = parseNetworks(
+	"64:ff9b::/96",   // well-known NAT64 prefix
+	"64:ff9b:1::/48") // local-use NAT64 prefix
+
+// restrictedAddressKind returns kind of address if it is restricted by default, empty string otherwise;
+// IPv4-mapped and NAT64 addresses are verified as the IPv4 addresses they embed
+func restrictedAddressKind(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	} else if embedded := embeddedIPv4(ip); embedded != nil {
+		ip = embedded
+	}
+
+	switch {
+	case ip == nil:
+		return "invalid"
+	case ip.IsUnspecified():
+		return "unspecified"
+	case ip.IsLoopback():
+		return "loopback"
+	case ip.IsLinkLocalUnicast(), ip.IsLinkLocalMulticast(), ip.IsInterfaceLocalMulticast():
+		return "link-local"
+	case ip.IsMulticast():
+		return "multicast"
+	case ip.Equal(net.IPv4bcast):
+		return "broadcast"
+	}
+
+	for _, network := range restrictedNetworks {
+		if network.Contains(ip) {
+			return "private"
+		}
+	}
+	return ""
+}
+
+// embeddedIPv4 returns IPv4 address embedded in NAT64 address, nil otherwise
+func embeddedIPv4(ip net.IP) net.IP {
+	if len(ip) != net.IPv6len {
+		return nil
+	}
+	for _, network := range nat64Networks {
+		if network.Contains(ip) {
+			return net.IPv4(ip[12], ip[13], ip[14], ip[15]).To4()
+		}
+	}
+	return nil
+}
+
+// check verifies that connection to IP address resolved for the host is permitted
+func (policy EgressPolicy) check(host string, ip net.IP) error {
+	if policy.AllowPrivate || bypassProxy(ip.String(), policy.Allow) {
+		return nil
+	}
+	if kind := restrictedAddressKind(ip); len(kind) > 0 {
+		return &EgressDeniedError{Host: host, IP: ip, Kind: kind}
+	}
+	return nil
+}
+
+// checkHost resolves the host and verifies all its addresses, hosts that can not be resolved are denied unless they
+// are in allowlist; it is used for targets reached through outbound proxy, which connects to them on its own
+func (policy EgressPolicy) checkHost(ctx context.Context, host string) error {
+	if policy.AllowPrivate || bypassProxy(host, policy.Allow) {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return policy.check(host, ip)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s to verify it against egress policy - %s", host, err)
+	}
+	for _, addr := range addrs {
+		if err := policy.check(host, addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// guard returns dialer that verifies resolved address of the host right before connection is established,
+// so DNS responses can not bypass the policy; hosts in allowlist and outbound proxies of operator are connected
+// without checks
+func (policy EgressPolicy) guard(dialer *net.Dialer, address string) *net.Dialer {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	if policy.AllowPrivate || isOperatorProxy(address) || (net.ParseIP(host) == nil && bypassProxy(host, policy.Allow)) {
+		return dialer
+	}
+
+	guarded := *dialer
+	guarded.Control = func(network string, address string, conn syscall.RawConn) error {
+		ip, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		return policy.check(host, net.ParseIP(ip))
+	}
+	return &guarded
+}
+
+// operatorProxyAddresses returns addresses of outbound proxies configured for the server with the given proxy URL
+// and with environment variables
+func operatorProxyAddresses(proxy string) []string {
+	addresses := make([]string, 0)
+	for _, value := range []string{proxy, os.Getenv("HTTP_PROXY"), os.Getenv("http_proxy"), os.Getenv("HTTPS_PROXY"),
+		os.Getenv("https_proxy")} {
+		if len(value) == 0 || value == ProxyDirect {
+			continue
+		}
+		if !strings.Contains(value, "://") {
+			value = "http://" + value
+		}
+		if proxyURL, err := url.Parse(value); err == nil && len(proxyURL.Hostname()) > 0 {
+			addresses = append(addresses, proxyAddress(proxyURL))
+		}
+	}
+	return addresses
+}
+
+// proxyAddress returns address of proxy with default port of its scheme, like HTTP transport dials it
+func proxyAddress(proxyURL *url.URL) string {
+	port := proxyURL.Port()
+	if len(port) == 0 {
+		switch proxyURL.Scheme {
+		case "https":
+			port = "443"
+		case "socks5", "socks5h":
+			port = "1080"
+		default:
+			port = "80"
+		}
+	}
+	return net.JoinHostPort(strings.ToLower(proxyURL.Hostname()), port)
+}
+
+func isOperatorProxy(address string) bool {
+	for _, proxy := range operatorProxies {
+		if strings.EqualFold(proxy, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardErrorStatus returns HTTP status that is sent to the client if forwarding has failed with the given error
+func forwardErrorStatus(err error, status int) int {
+	var denied *EgressDeniedError
+	if errors.As(err, &denied) {
+		return http.StatusForbidden
+	}
+	return status
+}
+
+// validateForwardEgress rejects forward URLs with IP addresses denied by egress policy, host names are verified
+// when requests are forwarded
+func validateForwardEgress(forwardURL string) error {
+	u, err := url.Parse(forwardURL)
+	if err != nil {
+		return nil
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil {
+		return egressPolicy.check(u.Hostname(), ip)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Supported distributions of response delay
+const (
+	DelayFixed   = "fixed"
+	DelayUniform = "uniform"
+	DelayNormal  = "normal"
+)
+
+// Supported types of injected response faults
+const (
+	FaultStatus   = "status"
+	FaultReset    = "reset"
+	FaultTruncate = "truncate"
+	FaultDrip     = "drip"
+)
+
+const (
+	maxResponseDelay      = 5 * 60 * 1000 // 5 min. in ms
+	defaultFaultStatus    = http.StatusServiceUnavailable
+	defaultDripChunkSize  = 16
+	defaultDripIntervalMs = 500
+)
+
+// validateResponseDelay validates configuration of response delay
+func validateResponseDelay(delay *ResponseDelay) error {
+	if delay.Value < 0 || delay.Min < 0 || delay.Max < 0 || delay.StdDev < 0 {
+		return fmt.Errorf("response delay may not be negative")
+	}
+
+	switch delay.Distribution {
+	case DelayFixed, "":
+		if delay.Value > maxResponseDelay {
+			return fmt.Errorf("response delay may not be greater than %d ms", maxResponseDelay)
+		}
+	case DelayUniform:
+		if delay.Min > delay.Max {
+			return fmt.Errorf("invalid uniform response delay: min %d is greater than max %d", delay.Min, delay.Max)
+		}
+		if delay.Max > maxResponseDelay {
+			return fmt.Errorf("response delay may not be greater than %d ms", maxResponseDelay)
+		}
+	case DelayNormal:
+		if delay.Value > maxResponseDelay {
+			return fmt.Errorf("response delay may not be greater than %d ms", maxResponseDelay)
+		}
+	default:
+		return fmt.Errorf("unknown distribution of response delay: %s", delay.Distribution)
+	}
+
+	return nil
+}
+
+// validateResponseFaults validates configuration of injected response faults
+func validateResponseFaults(faults []ResponseFault) error {
+	total := 0.0
+	for _, fault := range faults {
+		if fault.Probability < 0 || fault.Probability > 1 {
+			return fmt.Errorf("fault probability should be within [0, 1] range, but was %v", fault.Probability)
+		}
+		total += fault.Probability
+
+		switch fault.Type {
+		case FaultStatus:
+			if fault.Status != 0 && (fault.Status < 100 || fault.Status >= 600) {
+				return fmt.Errorf("invalid HTTP status of fault: %d", fault.Status)
+			}
+		case FaultReset, FaultTruncate:
+		case FaultDrip:
+			if fault.ChunkSize < 0 || fault.Interval < 0 {
+				return fmt.Errorf("chunk size and interval of slow drip fault may not be negative")
+			}
+			if fault.Interval > maxResponseDelay {
+				return fmt.Errorf("slow drip interval may not be greater than %d ms", maxResponseDelay)
+			}
+		default:
+			return fmt.Errorf("unknown type of response fault: %s", fault.Type)
+		}
+	}
+
+	if total > 1 {
+		return fmt.Errorf("total probability of response faults may not exceed 1, but was %v", total)
+	}
+
+	return nil
+}
+
+// Duration calculates the next response delay according to configured distribution
+func (delay *ResponseDelay) Duration() time.Duration {
+	var ms float64
+	switch delay.Distribution {
+	case DelayUniform:
+		ms = float64(delay.Min) + rand.Float64()*float64(delay.Max-delay.Min)
+	case DelayNormal:
+		ms = rand.NormFloat64()*float64(delay.StdDev) + float64(delay.Value)
+	default:
+		ms = float64(delay.Value)
+	}
+
+	// keep within allowed limits
+	if ms < 0 {
+		ms = 0
+	} else if ms > maxResponseDelay {
+		ms = maxResponseDelay
+	}
+
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// pickFault randomly selects one of configured faults according to their probability, returns nil if no fault is selected
+func pickFault(faults []ResponseFault) *ResponseFault {
+	if len(faults) == 0 {
+		return nil
+	}
+
+	dice := rand.Float64()
+	for i := range faults {
+		if dice < faults[i].Probability {
+			return &faults[i]
+		}
+		dice -= faults[i].Probability
+	}
+
+	return nil
+}
+
+// sleep pauses the handling of HTTP request, returns false if request is cancelled by client in the meanwhile
+func sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// abortConnection closes the underlying client connection without completing HTTP response,
+// if reset is requested the TCP connection is terminated with RST instead of graceful FIN
+func abortConnection(w http.ResponseWriter, reset bool) {
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			if tcp, ok := conn.(*net.TCPConn); ok && reset {
+				tcp.SetLinger(0)
+			}
+			conn.Close()
+			return
+		}
+	}
+
+	// let HTTP server to abort the response
+	panic(http.ErrAbortHandler)
+}
+
+// writeFaultyBody writes response body applying the selected fault
+func writeFaultyBody(w http.ResponseWriter, r *http.Request, status int, body []byte, fault *ResponseFault) {
+	switch fault.Type {
+	case FaultTruncate:
+		// announce the full body but deliver only the half of it
+		w.Header().Set("Content-Length", fmt.Sprint(len(body)))
+		w.WriteHeader(status)
+		w.Write(body[:len(body)/2])
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		abortConnection(w, false)
+	case FaultDrip:
+		chunkSize := fault.ChunkSize
+		if chunkSize == 0 {
+			chunkSize = defaultDripChunkSize
+		}
+		interval := fault.Interval
+		if interval == 0 {
+			interval = defaultDripIntervalMs
+		}
+
+		w.WriteHeader(status)
+		flusher, _ := w.(http.Flusher)
+		for len(body) > 0 {
+			n := chunkSize
+			if n > len(body) {
+				n = len(body)
+			}
+			w.Write(body[:n])
+			if flusher != nil {
+				flusher.Flush()
+			}
+
+			body = body[n:]
+			if len(body) > 0 && !sleep(r, time.Duration(interval)*time.Millisecond) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateResponseDelay(t *testing.T) {
+	assert.NoError(t, validateResponseDelay(&ResponseDelay{Value: 100}))
+	assert.NoError(t, validateResponseDelay(&ResponseDelay{Distribution: DelayFixed, Value: 100}))
+	assert.NoError(t, validateResponseDelay(&ResponseDelay{Distribution: DelayUniform, Min: 10, Max: 100}))
+	assert.NoError(t, validateResponseDelay(&ResponseDelay{Distribution: DelayNormal, Value: 100, StdDev: 20}))
+
+	assert.Error(t, validateResponseDelay(&ResponseDelay{Value: -1}))
+	assert.Error(t, validateResponseDelay(&ResponseDelay{Value: maxResponseDelay + 1}))
+	assert.Error(t, validateResponseDelay(&ResponseDelay{Distribution: DelayUniform, Min: 100, Max: 10}))
+	assert.Error(t, validateResponseDelay(&ResponseDelay{Distribution: DelayUniform, Max: maxResponseDelay + 1}))
+
+	err := validateResponseDelay(&ResponseDelay{Distribution: "poisson"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown distribution of response delay: poisson")
+	}
+}
+
+func TestValidateResponseFaults(t *testing.T) {
+	assert.NoError(t, validateResponseFaults(nil))
+	assert.NoError(t, validateResponseFaults([]ResponseFault{
+		{Probability: 0.5, Type: FaultStatus, Status: 500},
+		{Probability: 0.2, Type: FaultReset},
+		{Probability: 0.2, Type: FaultTruncate},
+		{Probability: 0.1, Type: FaultDrip, ChunkSize: 4, Interval: 100}}))
+
+	assert.Error(t, validateResponseFaults([]ResponseFault{{Probability: 1.5, Type: FaultReset}}))
+	assert.Error(t, validateResponseFaults([]ResponseFault{{Probability: 0.5, Type: FaultStatus, Status: 99}}))
+	assert.Error(t, validateResponseFaults([]ResponseFault{{Probability: 0.5, Type: FaultDrip, Interval: -1}}))
+
+	err := validateResponseFaults([]ResponseFault{{Probability: 0.6, Type: FaultReset}, {Probability: 0.6, Type: FaultTruncate}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "total probability of response faults may not exceed 1")
+	}
+
+	err = validateResponseFaults([]ResponseFault{{Probability: 0.1, Type: "explode"}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown type of response fault: explode")
+	}
+}
+
+func TestResponseDelay_Duration(t *testing.T) {
+	assert.Equal(t, 250*time.Millisecond, (&ResponseDelay{Value: 250}).Duration())
+
+	uniform := &ResponseDelay{Distribution: DelayUniform, Min: 10, Max: 20}
+	for i := 0; i < 100; i++ {
+		d := uniform.Duration()
+		assert.True(t, d >= 10*time.Millisecond && d <= 20*time.Millisecond, "delay is out of range: %v", d)
+	}
+
+	normal := &ResponseDelay{Distribution: DelayNormal, Value: 10, StdDev: 1000}
+	for i := 0; i < 100; i++ {
+		assert.True(t, normal.Duration() >= 0, "negative delay is not expected")
+	}
+}
+
+func TestPickFault(t *testing.T) {
+	assert.Nil(t, pickFault(nil))
+	assert.Nil(t, pickFault([]ResponseFault{{Probability: 0, Type: FaultReset}}))
+
+	fault := pickFault([]ResponseFault{{Probability: 0, Type: FaultReset}, {Probability: 1, Type: FaultTruncate}})
+	if assert.NotNil(t, fault) {
+		assert.Equal(t, FaultTruncate, fault.Type)
+	}
+}
+
+func TestAcceptBasketRequests_ResponseDelay(t *testing.T) {
+	basket := "fault01"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("GET", ResponseConfig{Status: 200, Body: "late", Delay: &ResponseDelay{Value: 100}})
+
+	r, err := http.NewRequest("GET", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		started := time.Now()
+		AcceptBasketRequests(w, r)
+
+		assert.True(t, time.Since(started) >= 100*time.Millisecond, "response is not delayed")
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "late", w.Body.String(), "wrong HTTP response body")
+	}
+}
+
+func TestAcceptBasketRequests_FaultStatus(t *testing.T) {
+	basket := "fault02"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("POST", ResponseConfig{Status: 201, Body: "created",
+		Faults: []ResponseFault{{Probability: 1, Type: FaultStatus}}})
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("data"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 503, w.Code, "wrong HTTP response code")
+		assert.Equal(t, 1, basketsDb.Get(basket).Size(), "request is expected to be collected")
+	}
+}
+
+func TestAcceptBasketRequests_FaultDrip(t *testing.T) {
+	basket := "fault03"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("GET", ResponseConfig{Status: 200, Body: "0123456789",
+		Faults: []ResponseFault{{Probability: 1, Type: FaultDrip, ChunkSize: 4, Interval: 50}}})
+
+	r, err := http.NewRequest("GET", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		started := time.Now()
+		AcceptBasketRequests(w, r)
+
+		// 3 chunks with 2 pauses in between
+		assert.True(t, time.Since(started) >= 100*time.Millisecond, "response is not dripped")
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "0123456789", w.Body.String(), "wrong HTTP response body")
+		assert.True(t, w.Flushed, "response is expected to be flushed")
+	}
+}
+
+func TestAcceptBasketRequests_FaultTruncate(t *testing.T) {
+	basket := "fault04"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("GET", ResponseConfig{Status: 200, Body: "0123456789",
+		Faults: []ResponseFault{{Probability: 1, Type: FaultTruncate}}})
+
+	ts := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/r/" + basket)
+	if assert.NoError(t, err) {
+		defer resp.Body.Close()
+		assert.Equal(t, 200, resp.StatusCode, "wrong HTTP response code")
+		assert.Equal(t, int64(10), resp.ContentLength, "wrong announced content length")
+
+		body, err := ioutil.ReadAll(resp.Body)
+		assert.Error(t, err, "unexpected EOF is expected")
+		assert.Equal(t, "01234", string(body), "wrong truncated body")
+	}
+}
+
+func TestAcceptBasketRequests_FaultReset(t *testing.T) {
+	basket := "fault05"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("GET", ResponseConfig{Status: 200,
+		Faults: []ResponseFault{{Probability: 1, Type: FaultReset}}})
+
+	ts := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer ts.Close()
+
+	_, err := http.Get(ts.URL + "/r/" + basket)
+	assert.Error(t, err, "connection failure is expected")
+	assert.Equal(t, 1, basketsDb.Get(basket).Size(), "request is expected to be collected")
+
+	// without hijacking support the response is aborted by HTTP server
+	r, err := http.NewRequest("GET", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+			AcceptBasketRequests(httptest.NewRecorder(), r)
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const maxForwardFilters = 20
+
+// ForwardFilter describes condition which incoming request should satisfy to be forwarded to a target. All criteria
+// specified in a single filter must match; value of header or JSON body field (dot notation, e.g. "event.type") is
+// compared with "equals" or "regex" if given, otherwise the header or field is only required to be present.
+type ForwardFilter struct {
+	Method     string `json:"method,omitempty"`
+	PathPrefix string `json:"path_prefix,omitempty"`
+	Header     string `json:"header,omitempty"`
+	Field      string `json:"field,omitempty"`
+	Equals     string `json:"equals,omitempty"`
+	Regex      string `json:"regex,omitempty"`
+	Negate     bool   `json:"negate,omitempty"`
+}
+
+// validateForwardFilters validates filters of forward target
+func validateForwardFilters(filters []ForwardFilter) error {
+	if len(filters) > maxForwardFilters {
+		return fmt.Errorf("forward target may not have more than %d filters", maxForwardFilters)
+	}
+
+	for _, filter := range filters {
+		if len(filter.Method) > 0 {
+			if _, err := validateMethod(filter.Method); err != nil {
+				return err
+			}
+		}
+		if len(filter.Header) > 0 && !isValidHeaderName(filter.Header) {
+			return fmt.Errorf("invalid header name of forward filter: %q", filter.Header)
+		}
+		if len(filter.Field) > 0 && (strings.HasPrefix(filter.Field, ".") || strings.HasSuffix(filter.Field, ".")) {
+			return fmt.Errorf("invalid field of forward filter: %q", filter.Field)
+		}
+		if len(filter.Header) > 0 && len(filter.Field) > 0 {
+			return fmt.Errorf("forward filter may not check both header and body field")
+		}
+
+		if len(filter.Equals) > 0 || len(filter.Regex) > 0 {
+			if len(filter.Header) == 0 && len(filter.Field) == 0 {
+				return fmt.Errorf("header or body field is required to compare value of forward filter")
+			}
+			if len(filter.Equals) > 0 && len(filter.Regex) > 0 {
+				return fmt.Errorf("forward filter may not have both equals and regex")
+			}
+		}
+		if len(filter.Regex) > 0 {
+			if _, err := compiledPatterns.compile(filter.Regex); err != nil {
+				return fmt.Errorf("invalid regex of forward filter: %s", err)
+			}
+		}
+
+		if len(filter.Method) == 0 && len(filter.PathPrefix) == 0 && len(filter.Header) == 0 && len(filter.Field) == 0 {
+			return fmt.Errorf("forward filter should have at least one of method, path prefix, header or field")
+		}
+	}
+
+	return nil
+}
+
+// matchesForwardFilters checks if request satisfies all filters of forward target
+func matchesForwardFilters(filters []ForwardFilter, req *RequestData, basket string) bool {
+	var body map[string]interface{}
+	parsed := false
+
+	for _, filter := range filters {
+		if len(filter.Field) > 0 && !parsed {
+			body = parseFilterBody(req.Body)
+			parsed = true
+		}
+		if filter.matches(req, basket, body) == filter.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// hasBodyFilters checks if any of filters depends on request body
+func hasBodyFilters(filters []ForwardFilter) bool {
+	for _, filter := range filters {
+		if len(filter.Field) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// filterForwardTargets returns forward targets which filters are satisfied by request along with the index of
+// proxy target in the returned list; -1 if proxy target does not accept the request
+func filterForwardTargets(targets []ForwardTarget, proxy int, req *RequestData, basket string) ([]ForwardTarget, int) {
+	accepted := make([]ForwardTarget, 0, len(targets))
+	index := -1
+	for i, target := range targets {
+		if matchesForwardFilters(target.Filters, req, basket) {
+			if i == proxy {
+				index = len(accepted)
+			}
+			accepted = append(accepted, target)
+		}
+	}
+	return accepted, index
+}
+
+// matches checks if request satisfies all criteria of the filter, negation is not applied
+func (filter ForwardFilter) matches(req *RequestData, basket string, body map[string]interface{}) bool {
+	if len(filter.Method) > 0 && !strings.EqualFold(filter.Method, req.Method) {
+		return false
+	}
+	if len(filter.PathPrefix) > 0 && !strings.HasPrefix(basketSubPath(req.Path, basket), filter.PathPrefix) {
+		return false
+	}
+
+	if len(filter.Header) > 0 {
+		values := req.Header.Values(filter.Header)
+		if len(values) == 0 {
+			return false
+		}
+		for _, value := range values {
+			if filter.matchesValue(value) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(filter.Field) > 0 {
+		value, ok := lookupBodyField(body, filter.Field)
+		return ok && filter.matchesValue(value)
+	}
+
+	return true
+}
+
+// matchesValue compares the value of header or body field with expected one
+func (filter ForwardFilter) matchesValue(value string) bool {
+	if len(filter.Equals) > 0 {
+		return value == filter.Equals
+	}
+	if len(filter.Regex) > 0 {
+		// the expression is validated and compiled with basket configuration
+		re, err := compiledPatterns.compile(filter.Regex)
+		return err == nil && re.MatchString(value)
+	}
+	return true
+}
+
+// parseFilterBody parses JSON object from request body, nil is returned for other bodies
+func parseFilterBody(body string) map[string]interface{} {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+// lookupBodyField looks up a field of JSON object with dot notation, string values are returned as is
+// while other values are returned as JSON, e.g. true or 42
+func lookupBodyField(doc map[string]interface{}, field string) (string, bool) {
+	fields := strings.Split(field, ".")
+	for _, name := range fields[:len(fields)-1] {
+		child, ok := doc[name].(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		doc = child
+	}
+
+	value, ok := doc[fields[len(fields)-1]]
+	if !ok {
+		return "", false
+	}
+	if str, ok := value.(string); ok {
+		return str, true
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
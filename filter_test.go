@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateForwardFilters(t *testing.T) {
+	assert.NoError(t, validateForwardFilters(nil))
+	assert.NoError(t, validateForwardFilters([]ForwardFilter{
+		{Method: "post", PathPrefix: "/hooks"},
+		{Header: "X-Event", Regex: "^push$"},
+		{Field: "event.type", Equals: "created", Negate: true}}))
+	compiledPatterns.Lock()
+	assert.Contains(t, compiledPatterns.patterns, "^push$", "compiled regex of filter should be cached")
+	compiledPatterns.Unlock()
+
+	assert.Error(t, validateForwardFilters([]ForwardFilter{{}}), "empty filter")
+	assert.Error(t, validateForwardFilters([]ForwardFilter{{Method: "GET POST"}}))
+	assert.Error(t, validateForwardFilters([]ForwardFilter{{Header: "X Event"}}))
+	assert.Error(t, validateForwardFilters([]ForwardFilter{{Field: "event."}}))
+	assert.Error(t, validateForwardFilters([]ForwardFilter{{Header: "X-Event", Field: "event"}}))
+	assert.Error(t, validateForwardFilters([]ForwardFilter{{Method: "GET", Equals: "abc"}}))
+	assert.Error(t, validateForwardFilters([]ForwardFilter{{Header: "X-Event", Equals: "a", Regex: "a"}}))
+	assert.Error(t, validateForwardFilters([]ForwardFilter{{Header: "X-Event", Regex: "[a"}}))
+	assert.Error(t, validateForwardFilters(make([]ForwardFilter, maxForwardFilters+1)))
+
+	assert.Error(t, validateForwardTargets([]ForwardTarget{{URL: "http://a", Filters: []ForwardFilter{{}}}}))
+	assert.Error(t, validateBasketConfig(&BasketConfig{Capacity: 20, ForwardFilters: []ForwardFilter{{}}}))
+}
+
+func TestMatchesForwardFilters(t *testing.T) {
+	req := &RequestData{
+		Method: "POST",
+		Path:   "/" + serviceRESTPath + "/filter/hooks/github",
+		Header: http.Header{"X-Github-Event": {"push"}},
+		Body:   `{"action":"opened","repo":{"private":true,"stars":42}}`}
+
+	matches := func(filters ...ForwardFilter) bool {
+		return matchesForwardFilters(filters, req, "filter")
+	}
+
+	assert.True(t, matches(), "no filters")
+	assert.True(t, matches(ForwardFilter{Method: "post"}))
+	assert.False(t, matches(ForwardFilter{Method: "PUT"}))
+	assert.True(t, matches(ForwardFilter{PathPrefix: "/hooks/"}))
+	assert.False(t, matches(ForwardFilter{PathPrefix: "/api"}))
+
+	assert.True(t, matches(ForwardFilter{Header: "X-GitHub-Event"}))
+	assert.True(t, matches(ForwardFilter{Header: "X-GitHub-Event", Equals: "push"}))
+	assert.False(t, matches(ForwardFilter{Header: "X-GitHub-Event", Equals: "issues"}))
+	assert.True(t, matches(ForwardFilter{Header: "X-GitHub-Event", Regex: "^(push|pull_request)$"}))
+	assert.False(t, matches(ForwardFilter{Header: "X-Missing"}))
+	assert.True(t, matches(ForwardFilter{Header: "X-Missing", Negate: true}))
+
+	assert.True(t, matches(ForwardFilter{Field: "action", Equals: "opened"}))
+	assert.True(t, matches(ForwardFilter{Field: "repo.private", Equals: "true"}))
+	assert.True(t, matches(ForwardFilter{Field: "repo.stars", Regex: "^4"}))
+	assert.False(t, matches(ForwardFilter{Field: "repo.name"}))
+	assert.False(t, matches(ForwardFilter{Field: "action.name"}))
+	assert.False(t, matches(ForwardFilter{Field: "action", Equals: "opened", Negate: true}))
+
+	// all filters must match
+	assert.True(t, matches(ForwardFilter{Method: "POST"}, ForwardFilter{Field: "action", Equals: "opened"}))
+	assert.False(t, matches(ForwardFilter{Method: "POST"}, ForwardFilter{Field: "action", Equals: "closed"}))
+
+	// body other than JSON object
+	req.Body = "action=opened"
+	assert.False(t, matches(ForwardFilter{Field: "action"}))
+}
+
+func TestFilterForwardTargets(t *testing.T) {
+	req := &RequestData{Method: "GET", Path: "/" + serviceRESTPath + "/filter"}
+	targets := []ForwardTarget{
+		{URL: "http://a", Filters: []ForwardFilter{{Method: "POST"}}},
+		{URL: "http://b"},
+		{URL: "http://c", Filters: []ForwardFilter{{Method: "GET"}}}}
+
+	accepted, proxy := filterForwardTargets(targets, 2, req, "filter")
+	if assert.Len(t, accepted, 2) {
+		assert.Equal(t, "http://b", accepted[0].URL)
+		assert.Equal(t, "http://c", accepted[1].URL)
+	}
+	assert.Equal(t, 1, proxy, "wrong index of proxy target")
+
+	_, proxy = filterForwardTargets(targets, 0, req, "filter")
+	assert.Equal(t, -1, proxy, "proxy target should not accept request")
+}
+
+func TestAcceptBasketRequests_WithForwardFilters(t *testing.T) {
+	basket := "filter01"
+
+	forwarded := make(chan string, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.URL.Path
+		w.Write([]byte("proxied"))
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL + "/legacy",
+		ProxyResponse: true, ForwardFilters: []ForwardFilter{{Field: "type", Equals: "order"}},
+		ForwardTargets: []ForwardTarget{{URL: ts.URL + "/audit", Filters: []ForwardFilter{{Method: "DELETE"}}}}})
+
+	// only the proxy target accepts the request
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader(`{"type":"order"}`))
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+
+	assert.Equal(t, "proxied", w.Body.String(), "response should be proxied")
+	assert.Equal(t, "/legacy", <-forwarded)
+
+	// none of targets accepts the request, basket response is sent
+	r = httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader(`{"type":"user"}`))
+	w = httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	assert.Empty(t, w.Body.String(), "basket response is expected")
+
+	// only the secondary target accepts the request
+	r = httptest.NewRequest("DELETE", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	w = httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+
+	assert.Empty(t, w.Body.String(), "basket response is expected")
+	select {
+	case path := <-forwarded:
+		assert.Equal(t, "/audit", path, "request should be forwarded to matching target")
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "request is not forwarded to matching target")
+	}
+
+	select {
+	case path := <-forwarded:
+		assert.Fail(t, "unexpected forward", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, 3, basketsDb.Get(basket).GetRequests(10, 0).Count, "all requests should be collected")
+}
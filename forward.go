@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+const maxForwardTargets = 10
+
+// ForwardTarget describes single target of request forwarding.
+type ForwardTarget struct {
+	URL             string          `json:"url"`
+	Disabled        bool            `json:"disabled,omitempty"`
+	InsecureTLS     bool            `json:"insecure_tls,omitempty"`
+	ExpandPath      bool            `json:"expand_path,omitempty"`
+	ProxyResponse   bool            `json:"proxy_response,omitempty"`
+	SetHeaders      http.Header     `json:"set_headers,omitempty"`
+	AddHeaders      http.Header     `json:"add_headers,omitempty"`
+	RemoveHeaders   []string        `json:"remove_headers,omitempty"`
+	TemplateHeaders bool            `json:"template_headers,omitempty"`
+	Host            string          `json:"host,omitempty"`
+	RemoveQuery     []string        `json:"remove_query,omitempty"`
+	BodyRewrites    []BodyRewrite   `json:"body_rewrites,omitempty"`
+	Filters         []ForwardFilter `json:"filters,omitempty"`
+}
+
+// validateForwardTargets validates forward targets of basket
+func validateForwardTargets(targets []ForwardTarget) error {
+	if len(targets) > maxForwardTargets {
+		return fmt.Errorf("basket may not have more than %d forward targets", maxForwardTargets)
+	}
+
+	proxies := 0
+	for _, target := range targets {
+		if _, err := url.ParseRequestURI(target.URL); err != nil {
+			return fmt.Errorf("invalid forward URL: %s - %s", target.URL, err)
+		}
+
+		if err := validateForwardRewrites(target); err != nil {
+			return err
+		}
+
+		if err := validateForwardFilters(target.Filters); err != nil {
+			return err
+		}
+
+		if target.ProxyResponse {
+			proxies++
+		}
+	}
+
+	if proxies > 1 {
+		return fmt.Errorf("only one forward target may be a source of proxied response")
+	}
+	return nil
+}
+
+// getForwardTargets lists enabled forward targets of basket, legacy forward URL is the first target if configured;
+// WebSocket targets are only used to proxy WebSocket sessions and are not listed
+func getForwardTargets(config BasketConfig) []ForwardTarget {
+	targets := make([]ForwardTarget, 0, len(config.ForwardTargets)+1)
+	if len(config.ForwardURL) > 0 && !isWebSocketURL(config.ForwardURL) {
+		targets = append(targets, ForwardTarget{
+			URL:         config.ForwardURL,
+			InsecureTLS: config.InsecureTLS,
+			ExpandPath:  config.ExpandPath,
+			Filters:     config.ForwardFilters})
+	}
+
+	for _, target := range config.ForwardTargets {
+		if !target.Disabled && !isWebSocketURL(target.URL) {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// getProxyTarget returns index of forward target which response is proxied back to the client: the target designated
+// with "proxy_response" flag or the first target otherwise; -1 if there are no targets
+func getProxyTarget(targets []ForwardTarget) int {
+	for i, target := range targets {
+		if target.ProxyResponse {
+			return i
+		}
+	}
+
+	if len(targets) > 0 {
+		return 0
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateForwardTargets(t *testing.T) {
+	assert.NoError(t, validateForwardTargets(nil))
+	assert.NoError(t, validateForwardTargets([]ForwardTarget{
+		{URL: "http://localhost:8080/a", ProxyResponse: true},
+		{URL: "https://example.com/b", SetHeaders: http.Header{"X-Env": {"test"}}, RemoveHeaders: []string{"Cookie"}}}))
+
+	assert.Error(t, validateForwardTargets([]ForwardTarget{{URL: "abc"}}))
+	assert.Error(t, validateForwardTargets([]ForwardTarget{{URL: "http://a", SetHeaders: http.Header{"X Env": {"1"}}}}))
+	assert.Error(t, validateForwardTargets([]ForwardTarget{{URL: "http://a", RemoveHeaders: []string{""}}}))
+	assert.Error(t, validateForwardTargets([]ForwardTarget{
+		{URL: "http://a", ProxyResponse: true},
+		{URL: "http://b", ProxyResponse: true}}))
+	assert.Error(t, validateForwardTargets(make([]ForwardTarget, maxForwardTargets+1)))
+}
+
+func TestGetForwardTargets(t *testing.T) {
+	config := BasketConfig{ForwardURL: "http://a", InsecureTLS: true, ForwardTargets: []ForwardTarget{
+		{URL: "http://b", Disabled: true},
+		{URL: "http://c", ProxyResponse: true}}}
+
+	targets := getForwardTargets(config)
+	if assert.Len(t, targets, 2) {
+		assert.Equal(t, "http://a", targets[0].URL)
+		assert.True(t, targets[0].InsecureTLS)
+		assert.Equal(t, "http://c", targets[1].URL)
+	}
+	assert.Equal(t, 1, getProxyTarget(targets))
+	assert.Equal(t, 0, getProxyTarget(targets[:1]))
+	assert.Equal(t, -1, getProxyTarget(nil))
+}
+
+func TestAcceptBasketRequests_WithForwardTargets(t *testing.T) {
+	basket := "fanout01"
+
+	var mutex sync.Mutex
+	received := make(map[string]*RequestData)
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		received[r.URL.Path] = ToRequestData(r)
+		mutex.Unlock()
+		wg.Done()
+
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("proxied from " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ProxyResponse: true, ForwardTargets: []ForwardTarget{
+		{URL: ts.URL + "/primary", SetHeaders: http.Header{"X-Target": {"primary"}}, RemoveHeaders: []string{"X-Secret"}},
+		{URL: ts.URL + "/disabled", Disabled: true},
+		{URL: ts.URL + "/mirror", ProxyResponse: true}}})
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket+"/events", strings.NewReader("{\"id\":1}"))
+	if assert.NoError(t, err) {
+		r.Header.Set("X-Secret", "abc")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		// response is proxied from the designated target
+		assert.Equal(t, 202, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "proxied from /mirror", w.Body.String(), "wrong proxied response")
+
+		if waitTimeout(&wg, 3*time.Second) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			assert.Len(t, received, 2, "wrong number of forwarded requests")
+			if primary := received["/primary"]; assert.NotNil(t, primary, "request is not forwarded to primary target") {
+				assert.Equal(t, "primary", primary.Header.Get("X-Target"), "header is not set")
+				assert.Empty(t, primary.Header.Get("X-Secret"), "header is not removed")
+				assert.Equal(t, "{\"id\":1}", primary.Body, "wrong forwarded body")
+			}
+			if mirror := received["/mirror"]; assert.NotNil(t, mirror, "request is not forwarded to mirror target") {
+				assert.Equal(t, "abc", mirror.Header.Get("X-Secret"), "header is expected to be forwarded")
+			}
+		} else {
+			assert.Fail(t, "requests are not forwarded to all targets")
+		}
+	}
+}
+
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -10,6 +10,8 @@ require (
 	github.com/lib/pq v1.10.7
 	github.com/stretchr/testify v1.7.0
 	go.etcd.io/bbolt v1.3.6
+	go.starlark.net v0.0.0-20220926145019-14b050677505
 	golang.org/x/sys v0.0.0-20220919091848-fb04ddd9f9c8 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
+	gopkg.in/yaml.v3 v3.0.1
+	modernc.org/sqlite v1.23.1
 )
@@ -114,6 +114,59 @@ func validateBasketConfig(config *BasketConfig) error {
 		if _, err := url.ParseRequestURI(config.ForwardURL); err != nil {
 			return err
 		}
+		if err := validateNotSelfURL(config.ForwardURL); err != nil {
+			return err
+		}
+		if err := validateForwardEgress(config.ForwardURL); err != nil {
+			return err
+		}
+	}
+
+	// validate forward targets
+	if err := validateForwardFilters(config.ForwardFilters); err != nil {
+		return err
+	}
+	if err := validateForwardTargets(config.ForwardTargets); err != nil {
+		return err
+	}
+	for _, target := range config.ForwardTargets {
+		if err := validateNotSelfURL(target.URL); err != nil {
+			return err
+		}
+		if err := validateForwardEgress(target.URL); err != nil {
+			return err
+		}
+	}
+
+	// validate request validation
+	if config.Validation != nil {
+		if err := validateBasketValidation(config.Validation); err != nil {
+			return err
+		}
+	}
+
+	// validate recording mode
+	if err := validateRecordingMode(config); err != nil {
+		return err
+	}
+
+	// validate retry policy
+	if config.Retry != nil {
+		if err := validateRetryPolicy(config.Retry); err != nil {
+			return err
+		}
+	}
+
+	// validate HTTP client settings
+	if config.HTTPClient != nil {
+		if err := validateHTTPClientConfig(config.HTTPClient); err != nil {
+			return err
+		}
+	}
+
+	// validate CORS settings
+	if config.CORS != nil {
+		return validateCORSConfig(config.CORS)
 	}
 
 	return nil
@@ -126,19 +179,54 @@ func validateResponseConfig(config *ResponseConfig) error {
 		return fmt.Errorf("invalid HTTP status of response: %d", config.Status)
 	}
 
-	// validate template
-	if config.IsTemplate && len(config.Body) > 0 {
-		if _, err := template.New("body").Parse(config.Body); err != nil {
-			return fmt.Errorf("error in body %s", err)
+	// validate templates
+	if config.IsTemplate {
+		if err := validateResponseTemplates(config); err != nil {
+			return err
+		}
+	}
+
+	// validate delay and faults
+	if config.Delay != nil {
+		if err := validateResponseDelay(config.Delay); err != nil {
+			return err
 		}
 	}
+	if err := validateResponseFaults(config.Faults); err != nil {
+		return err
+	}
+
+	// validate echo mode
+	if err := validateEchoMode(config.Echo); err != nil {
+		return err
+	}
+
+	// validate script
+	if len(config.Script) > 0 {
+		if len(config.Echo) > 0 {
+			return fmt.Errorf("response script cannot be combined with echo mode")
+		}
+		if err := validateResponseScript(config.Script); err != nil {
+			return err
+		}
+	}
+
+	// validate request constraints
+	if config.Validation != nil {
+		return validateRequestValidation(config.Validation)
+	}
 
 	return nil
 }
 
 // getValidMethod retrieves mathod name from HTTP request path and validates it
 func getValidMethod(ps httprouter.Params) (string, error) {
-	method := strings.ToUpper(ps.ByName("method"))
+	return validateMethod(ps.ByName("method"))
+}
+
+// validateMethod validates name of HTTP method, returns the name in upper case
+func validateMethod(name string) (string, error) {
+	method := strings.ToUpper(name)
 
 	// valid HTTP methods
 	switch method {
@@ -194,11 +282,25 @@ func GetVersion(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 // GetBasket handles HTTP request to get basket configuration
 func GetBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		json, err := json.Marshal(basket.Config())
+		config := basket.Config()
+		config.HTTPClient = redactHTTPClientConfig(config.HTTPClient)
+		json, err := json.Marshal(config)
 		writeJSON(w, http.StatusOK, json, err)
 	}
 }
 
+// validateNewBasketName validates name of a new basket, returns HTTP status code along with an error
+func validateNewBasketName(name string) (int, error) {
+	if name == serviceOldAPIPath || name == serviceAPIPath || name == serviceUIPath {
+		return http.StatusForbidden, fmt.Errorf("This basket name conflicts with reserved system path: %s", name)
+	}
+	if !validBasketName.MatchString(name) {
+		return http.StatusBadRequest, fmt.Errorf("invalid basket name; the name does not match pattern: %s", validBasketName.String())
+	}
+
+	return 0, nil
+}
+
 // CreateBasket handles HTTP request to create a new basket
 func CreateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	if !authorizeRequest(w, r, true, serverConfig) {
@@ -206,19 +308,15 @@ func CreateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 	}
 
 	name := ps.ByName("basket")
-	if name == serviceOldAPIPath || name == serviceAPIPath || name == serviceUIPath {
-		http.Error(w, "This basket name conflicts with reserved system path: "+name, http.StatusForbidden)
-		return
-	}
-	if !validBasketName.MatchString(name) {
-		http.Error(w, "invalid basket name; the name does not match pattern: "+validBasketName.String(), http.StatusBadRequest)
+	if status, err := validateNewBasketName(name); err != nil {
+		http.Error(w, err.Error(), status)
 		return
 	}
 
 	log.Printf("[info] creating basket: %s", name)
 
-	// read config (max 2 kB)
-	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+	// read config (max 64 kB)
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
 	r.Body.Close()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -250,18 +348,24 @@ func CreateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 // UpdateBasket handles HTTP request to update basket configuration
 func UpdateBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
-		// read config (max 2 kB)
-		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 2048))
+		// read config (max 64 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
 		r.Body.Close()
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 		} else if len(body) > 0 {
-			// get current config
+			// get current config, secrets of HTTP client are kept if they are not sent back
 			config := basket.Config()
+			current := config.HTTPClient
+			if current != nil {
+				settings := *current
+				config.HTTPClient = &settings
+			}
 			if err = json.Unmarshal(body, &config); err != nil {
 				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
+			restoreHTTPClientSecrets(config.HTTPClient, current)
 			if err = validateBasketConfig(&config); err != nil {
 				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
 				return
@@ -286,19 +390,84 @@ func DeleteBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params)
 	}
 }
 
+// getValidPathPattern retrieves optional path pattern of basket response from HTTP request query and validates it
+func getValidPathPattern(r *http.Request) (string, error) {
+	path := r.URL.Query().Get("path")
+	if len(path) > 0 {
+		return path, validatePathPattern(path)
+	}
+
+	return path, nil
+}
+
 // GetBasketResponse handles HTTP request to get basket response configuration
 func GetBasketResponse(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		method, errm := getValidMethod(ps)
+		if errm != nil {
+			http.Error(w, errm.Error(), http.StatusBadRequest)
+			return
+		}
+		path, errp := getValidPathPattern(r)
+		if errp != nil {
+			http.Error(w, errp.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := getConfiguredResponse(basket, method, path)
+		if response == nil {
+			response = &defaultResponse
+		}
+
+		json, err := json.Marshal(withoutPayload(response))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// getConfiguredResponse retrieves basket response configured for HTTP method and optional path pattern along with
+// its binary payload
+func getConfiguredResponse(basket Basket, method string, path string) *ResponseConfig {
+	if len(path) > 0 {
+		return loadPayload(basket, method, path, basket.GetPathResponses(method)[path])
+	}
+
+	return loadPayload(basket, method, path, basket.GetResponse(method))
+}
+
+// setConfiguredResponse updates basket response configured for HTTP method and optional path pattern
+func setConfiguredResponse(basket Basket, method string, path string, response ResponseConfig) {
+	if len(path) > 0 {
+		basket.SetPathResponse(method, path, response)
+	} else {
+		basket.SetResponse(method, response)
+	}
+}
+
+// withoutPayload returns a copy of response configuration without binary payload data, payload is available
+// via dedicated API only
+func withoutPayload(response *ResponseConfig) *ResponseConfig {
+	if len(response.Payload) == 0 {
+		return response
+	}
+
+	stripped := *response
+	stripped.Payload = nil
+	return &stripped
+}
+
+// GetBasketPathResponses handles HTTP request to get all path specific responses of basket configured for HTTP method
+func GetBasketPathResponses(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
 		method, errm := getValidMethod(ps)
 		if errm != nil {
 			http.Error(w, errm.Error(), http.StatusBadRequest)
 		} else {
-			response := basket.GetResponse(method)
-			if response == nil {
-				response = &defaultResponse
+			responses := map[string]*ResponseConfig{}
+			for path, response := range basket.GetPathResponses(method) {
+				responses[path] = withoutPayload(response)
 			}
 
-			json, err := json.Marshal(response)
+			json, err := json.Marshal(responses)
 			writeJSON(w, http.StatusOK, json, err)
 		}
 	}
@@ -310,30 +479,227 @@ func UpdateBasketResponse(w http.ResponseWriter, r *http.Request, ps httprouter.
 		method, errm := getValidMethod(ps)
 		if errm != nil {
 			http.Error(w, errm.Error(), http.StatusBadRequest)
-		} else {
-			// read response (max 64 kB)
-			body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
-			r.Body.Close()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			} else if len(body) > 0 {
-				// get current config
-				response := ResponseConfig{Status: defaultResponse.Status, IsTemplate: false}
-				if err = json.Unmarshal(body, &response); err != nil {
-					http.Error(w, err.Error(), http.StatusBadRequest)
-					return
-				}
-				if err = validateResponseConfig(&response); err != nil {
-					http.Error(w, err.Error(), http.StatusUnprocessableEntity)
-					return
-				}
+			return
+		}
+		path, errp := getValidPathPattern(r)
+		if errp != nil {
+			http.Error(w, errp.Error(), http.StatusBadRequest)
+			return
+		}
 
-				basket.SetResponse(method, response)
-				w.WriteHeader(http.StatusNoContent)
-			} else {
-				w.WriteHeader(http.StatusNotModified)
+		// read response (max 64 kB)
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, 64*1024))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else if len(body) > 0 {
+			// get current config
+			response := ResponseConfig{Status: defaultResponse.Status, IsTemplate: false}
+			if err = json.Unmarshal(body, &response); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err = validateResponseConfig(&response); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			if err = validatePathResponsesCount(basket, method, path); err != nil {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
 			}
+
+			// binary payload is managed via dedicated API
+			clearPayload(&response)
+			if current := getConfiguredResponse(basket, method, path); current != nil && len(current.Payload) > 0 {
+				response.Payload = current.Payload
+				response.PayloadType = current.PayloadType
+				response.PayloadName = current.PayloadName
+				response.PayloadSize = current.PayloadSize
+			}
+
+			setConfiguredResponse(basket, method, path, response)
+			w.WriteHeader(http.StatusNoContent)
+		} else {
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}
+}
+
+// DeleteBasketResponse handles HTTP request to delete path specific basket response configuration
+func DeleteBasketResponse(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		method, errm := getValidMethod(ps)
+		if errm != nil {
+			http.Error(w, errm.Error(), http.StatusBadRequest)
+			return
+		}
+		path, errp := getValidPathPattern(r)
+		if errp != nil {
+			http.Error(w, errp.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(path) == 0 {
+			http.Error(w, "path of response is required", http.StatusBadRequest)
+			return
+		}
+
+		basket.DeletePathResponse(method, path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetBasketResponsePayload handles HTTP request to get binary payload of basket response
+func GetBasketResponsePayload(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		method, errm := getValidMethod(ps)
+		if errm != nil {
+			http.Error(w, errm.Error(), http.StatusBadRequest)
+			return
+		}
+		path, errp := getValidPathPattern(r)
+		if errp != nil {
+			http.Error(w, errp.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := getConfiguredResponse(basket, method, path)
+		if response == nil || len(response.Payload) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writePayload(w, r, http.StatusOK, response)
+	}
+}
+
+// UpdateBasketResponsePayload handles HTTP request to upload binary payload of basket response
+func UpdateBasketResponsePayload(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		method, errm := getValidMethod(ps)
+		if errm != nil {
+			http.Error(w, errm.Error(), http.StatusBadRequest)
+			return
+		}
+		path, errp := getValidPathPattern(r)
+		if errp != nil {
+			http.Error(w, errp.Error(), http.StatusBadRequest)
+			return
+		}
+
+		payload, err := readResponsePayload(r)
+		if err == errPayloadTooLarge {
+			http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if limit := maxBasketPayloadSize(basket); len(payload.data) > limit {
+			http.Error(w, fmt.Sprintf("response payload may not be greater than %d bytes in this storage", limit),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+		if len(payload.data) == 0 {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if err = validatePathResponsesCount(basket, method, path); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
 		}
+
+		response := defaultResponse
+		if current := getConfiguredResponse(basket, method, path); current != nil {
+			response = *current
+		}
+		payload.apply(&response)
+
+		setConfiguredResponse(basket, method, path, response)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteBasketResponsePayload handles HTTP request to delete binary payload of basket response
+func DeleteBasketResponsePayload(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		method, errm := getValidMethod(ps)
+		if errm != nil {
+			http.Error(w, errm.Error(), http.StatusBadRequest)
+			return
+		}
+		path, errp := getValidPathPattern(r)
+		if errp != nil {
+			http.Error(w, errp.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if response := getConfiguredResponse(basket, method, path); response != nil && len(response.Payload) > 0 {
+			updated := *response
+			clearPayload(&updated)
+			setConfiguredResponse(basket, method, path, updated)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getOpenAPIDocument reads OpenAPI document from HTTP request, responds with error if document is not valid
+func getOpenAPIDocument(w http.ResponseWriter, r *http.Request) *openAPIDocument {
+	doc, err := readOpenAPIDocument(r)
+	if err == errOpenAPITooLarge {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return nil
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return nil
+	}
+
+	return doc
+}
+
+// CreateBasketFromOpenAPI handles HTTP request to create a new basket with responses generated from OpenAPI document
+func CreateBasketFromOpenAPI(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if !authorizeRequest(w, r, true, serverConfig) {
+		return
+	}
+
+	name := ps.ByName("basket")
+	if status, err := validateNewBasketName(name); err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	doc := getOpenAPIDocument(w, r)
+	if doc == nil {
+		return
+	}
+
+	log.Printf("[info] creating basket from OpenAPI document: %s", name)
+	auth, err := basketsDb.Create(name, BasketConfig{ForwardURL: "", Capacity: serverConfig.InitCapacity})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	result := struct {
+		BasketAuth
+		*openAPIImport
+	}{auth, importOpenAPI(basketsDb.Get(name), doc)}
+
+	json, err := json.Marshal(result)
+	writeJSON(w, http.StatusCreated, json, err)
+}
+
+// ImportBasketOpenAPI handles HTTP request to generate responses of existing basket from OpenAPI document
+func ImportBasketOpenAPI(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		doc := getOpenAPIDocument(w, r)
+		if doc == nil {
+			return
+		}
+
+		log.Printf("[info] importing OpenAPI document into basket: %s", name)
+		json, err := json.Marshal(importOpenAPI(basket, doc))
+		writeJSON(w, http.StatusOK, json, err)
 	}
 }
 
@@ -362,6 +728,276 @@ func ClearBasket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	}
 }
 
+// ReplayBasketRequest handles HTTP request to resend collected request to a target and returns the target response
+func ReplayBasketRequest(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxReplayRequestSize+1))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(body) > maxReplayRequestSize {
+			http.Error(w, fmt.Sprintf("replay request may not be larger than %d bytes", maxReplayRequestSize),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		replay := new(ReplayRequest)
+		if len(body) > 0 {
+			if err = json.Unmarshal(body, replay); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		request := findBasketRequest(basket, ps.ByName("id"))
+		if request == nil {
+			http.Error(w, "request is not found", http.StatusNotFound)
+			return
+		}
+
+		target, err := getReplayTarget(replay, basket.Config())
+		if err == nil {
+			err = validateForwardTargets([]ForwardTarget{target})
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		response, err := toReplayedRequest(request, replay).ForwardTo(r.Context(),
+			getHTTPClient(basket.Config().HTTPClient, target.InsecureTLS), target, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			writeForwardResponse(w, response, false, false, name)
+		}
+	}
+}
+
+// GetBasketState handles HTTP request to get key/value state of basket
+func GetBasketState(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		json, err := json.Marshal(basket.GetState())
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// UpdateBasketState handles HTTP request to replace key/value state of basket
+func UpdateBasketState(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxStateRequestSize+1))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(body) > maxStateRequestSize {
+			http.Error(w, fmt.Sprintf("basket state may not be larger than %d bytes", maxStateRequestSize),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		state := make(map[string]string)
+		if err = json.Unmarshal(body, &state); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err = validateState(state); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		basket.ClearState()
+		for key, value := range state {
+			if err = basket.SetStateValue(key, value); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteBasketState handles HTTP request to delete key/value state of basket
+func DeleteBasketState(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		basket.ClearState()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetBasketStateValue handles HTTP request to get single value of basket state
+func GetBasketStateValue(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if value, exists := basket.GetStateValue(ps.ByName("key")); exists {
+			w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(value))
+		} else {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}
+}
+
+// UpdateBasketStateValue handles HTTP request to set single value of basket state
+func UpdateBasketStateValue(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxStateValueSize+1))
+		r.Body.Close()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if len(body) > maxStateValueSize {
+			http.Error(w, fmt.Sprintf("state value may not be larger than %d bytes", maxStateValueSize),
+				http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		if err = basket.SetStateValue(ps.ByName("key"), string(body)); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteBasketStateValue handles HTTP request to delete single value of basket state
+func DeleteBasketStateValue(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		basket.DeleteStateValue(ps.ByName("key"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetBasketRetries handles HTTP request to get failed forwards of basket that are scheduled for retry
+func GetBasketRetries(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		json, err := json.Marshal(basket.GetForwardRetries(false))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// DeleteBasketRetry handles HTTP request to cancel scheduled retry of failed forward
+func DeleteBasketRetry(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		id := ps.ByName("id")
+		if retry := basket.GetForwardRetry(id); retry == nil || retry.Dead {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			forwardRetries.cancel(name, id)
+			basket.DeleteForwardRetry(id)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// GetBasketDeadLetters handles HTTP request to get failed forwards of basket that exhausted all retry attempts
+func GetBasketDeadLetters(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		json, err := json.Marshal(basket.GetForwardRetries(true))
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// RedriveBasketDeadLetters handles HTTP request to re-drive all dead letters of basket
+func RedriveBasketDeadLetters(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		for _, retry := range basket.GetForwardRetries(true) {
+			if err := forwardRetries.redrive(basket, name, retry); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteBasketDeadLetters handles HTTP request to delete all dead letters of basket
+func DeleteBasketDeadLetters(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		for _, retry := range basket.GetForwardRetries(true) {
+			basket.DeleteForwardRetry(retry.ID)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RedriveBasketDeadLetter handles HTTP request to re-drive single dead letter of basket
+func RedriveBasketDeadLetter(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if name, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if retry := basket.GetForwardRetry(ps.ByName("id")); retry == nil || !retry.Dead {
+			w.WriteHeader(http.StatusNotFound)
+		} else if err := forwardRetries.redrive(basket, name, retry); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		} else {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// DeleteBasketDeadLetter handles HTTP request to delete single dead letter of basket
+func DeleteBasketDeadLetter(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if retry := basket.GetForwardRetry(ps.ByName("id")); retry == nil || !retry.Dead {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			basket.DeleteForwardRetry(retry.ID)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
+// GetBasketWebSockets handles HTTP request to get WebSocket sessions of basket, frames are not included
+func GetBasketWebSockets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		sessions := basket.GetWebSocketSessions()
+		for _, session := range sessions {
+			session.Frames = nil
+		}
+		json, err := json.Marshal(sessions)
+		writeJSON(w, http.StatusOK, json, err)
+	}
+}
+
+// DeleteBasketWebSockets handles HTTP request to delete all WebSocket sessions of basket
+func DeleteBasketWebSockets(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		for _, session := range basket.GetWebSocketSessions() {
+			basket.DeleteWebSocketSession(session.ID)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetBasketWebSocket handles HTTP request to get single WebSocket session of basket including its frames
+func GetBasketWebSocket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if session := basket.GetWebSocketSession(ps.ByName("id")); session == nil {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			json, err := json.Marshal(session)
+			writeJSON(w, http.StatusOK, json, err)
+		}
+	}
+}
+
+// DeleteBasketWebSocket handles HTTP request to delete single WebSocket session of basket
+func DeleteBasketWebSocket(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if _, basket := getAuthorizedBasket(w, r, ps, serverConfig); basket != nil {
+		if session := basket.GetWebSocketSession(ps.ByName("id")); session == nil {
+			w.WriteHeader(http.StatusNotFound)
+		} else {
+			basket.DeleteWebSocketSession(session.ID)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
+
 // ForwardToWeb handels HTTP forwarding to /web
 func ForwardToWeb(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 	http.Redirect(w, r, serverConfig.PathPrefix+"/"+serviceUIPath, http.StatusFound)
@@ -403,20 +1039,93 @@ func AcceptBasketRequests(w http.ResponseWriter, r *http.Request) {
 		log.Printf("[error] %s", err)
 		http.Error(w, publicErr, http.StatusBadRequest)
 	} else if basket := basketsDb.Get(name); basket != nil {
-		request := basket.Add(r)
-
-		// forward request if configured and it's a first forwarding
 		config := basket.Config()
-		if len(config.ForwardURL) > 0 && r.Header.Get(DoNotForwardHeader) != "1" {
-			if config.ProxyResponse {
-				forwardAndProxyResponse(w, request, config, name)
+
+		// answer CORS preflight requests
+		if config.CORS != nil {
+			writeCORSHeaders(w, r, config.CORS)
+			if isPreflightRequest(r) {
+				if !config.CORS.SkipPreflight {
+					basket.Add(r)
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+		}
+
+		// accept WebSocket sessions if configured
+		if config.WebSocket && isWebSocketUpgrade(r) {
+			acceptWebSocket(w, r, basket, config, name)
+			return
+		}
+
+		response, params := getBasketResponse(basket, r.Method, getBasketSubPath(r, name), r.URL.RawQuery)
+		violations := collectViolations(config.Validation, response.Validation, r, params)
+		rejected := config.Validation != nil && config.Validation.Reject && len(violations) > 0
+
+		// forward request to all targets if configured and it's a first forwarding, recorded responses are served
+		// instead in playback mode
+		targets := getForwardTargets(config)
+		forward := len(targets) > 0 && config.Recording != RecordingPlayback && r.Header.Get(DoNotForwardHeader) != "1" &&
+			!isForwardLoop(r.Header, name)
+		record := config.Recording == RecordingRecord
+
+		// stream request to the target and its response back without buffering if configured, the target should
+		// accept request without looking into its body
+		if forward && !rejected && config.ProxyResponse && config.StreamProxy && !record {
+			proxy := getProxyTarget(targets)
+			head := &RequestData{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Header: r.Header}
+			if !hasBodyFilters(targets[proxy].Filters) && matchesForwardFilters(targets[proxy].Filters, head, name) {
+				streamAndProxyResponse(w, withViolations(r, violations), basket, targets, proxy, config, name)
+				return
+			}
+		}
+
+		r = withViolations(r, violations)
+
+		// reject invalid request if configured
+		if rejected {
+			basket.Add(r)
+			writeViolations(w, violations)
+			return
+		}
+
+		if forward {
+			proxy := -1
+			if config.ProxyResponse || record {
+				proxy = getProxyTarget(targets)
+			}
+
+			// only targets which filters are satisfied by request are used
+			var request *RequestData
+			r, request = peekRequestData(r)
+			targets, proxy = filterForwardTargets(targets, proxy, request, name)
+			if proxy < 0 {
+				request = basket.Add(r)
+			}
+			for i, target := range targets {
+				if i != proxy {
+					go forwardAndForget(basket, request, target, name)
+				}
+			}
+
+			// proxied request is collected along with the response of target
+			if proxy >= 0 && record {
+				basket.Add(withProxiedResponse(r,
+					forwardAndRecordResponse(w, r, basket, request, targets[proxy], config, name)))
+				return
+			} else if proxy >= 0 {
+				basket.Add(withProxiedResponse(r,
+					forwardAndProxyResponse(w, r, request, targets[proxy], config, name)))
 				return
 			}
 
-			go forwardAndForget(request, config, name)
+			writeBasketResponse(w, r, basket, request, name, response, params)
+			return
 		}
 
-		writeBasketResponse(w, r, name, basket)
+		request := basket.Add(r)
+		writeBasketResponse(w, r, basket, request, name, response, params)
 	} else {
 		w.WriteHeader(http.StatusNotFound)
 	}
@@ -442,70 +1151,151 @@ func getBasketNameOfAcceptedRequest(r *http.Request, prefix string) (string, str
 	return name, "", nil
 }
 
-func forwardAndForget(request *RequestData, config BasketConfig, name string) {
-	// forward request and discard the response
-	response, err := request.Forward(getHTTPClient(config.InsecureTLS), config, name)
+// forwardAndProxyResponse forwards request in a full proxy mode, returns captured response of the target
+func forwardAndProxyResponse(w http.ResponseWriter, r *http.Request, request *RequestData, target ForwardTarget,
+	config BasketConfig, name string) *ResponseData {
+	response, err := request.ForwardTo(r.Context(), getHTTPClient(config.HTTPClient, target.InsecureTLS), target,
+		name)
 	if err != nil {
-		log.Printf("[warn] failed to forward request for basket: %s - %s", name, err)
-	} else {
-		io.Copy(ioutil.Discard, response.Body)
-		response.Body.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
 	}
+
+	// CORS headers are controlled by basket if configured
+	return writeForwardResponse(w, response, config.CORS != nil, false, name)
 }
 
-func forwardAndProxyResponse(w http.ResponseWriter, request *RequestData, config BasketConfig, name string) {
-	// forward request in a full proxy mode
-	response, err := request.Forward(getHTTPClient(config.InsecureTLS), config, name)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-	} else {
-		// headers
-		for k, v := range response.Header {
+// writeForwardResponse passes response of forwarded request back to the client, streamed response is flushed
+// as soon as data is received; returns the response with the beginning of its body captured
+func writeForwardResponse(w http.ResponseWriter, response *http.Response, skipCORS bool, stream bool,
+	name string) *ResponseData {
+	captured := newResponseData(response)
+	capture := &captureBuffer{limit: maxCapturedResponseSize}
+	body := io.TeeReader(response.Body, capture)
+
+	// headers
+	for k, v := range response.Header {
+		if !skipCORS || !isCORSHeader(k) {
 			w.Header()[k] = v
 		}
+	}
 
-		// status
-		w.WriteHeader(response.StatusCode)
+	// status
+	w.WriteHeader(response.StatusCode)
 
-		// body
-		_, err := io.Copy(w, response.Body)
-		if err != nil {
-			log.Printf("[warn] failed to proxy response body for basket: %s - %s", name, err)
+	// body
+	var err error
+	if stream {
+		err = copyAndFlush(w, body)
+	} else {
+		_, err = io.Copy(w, body)
+	}
+	if err != nil {
+		log.Printf("[warn] failed to proxy response body for basket: %s - %s", name, err)
+		// streamed response may never end, so it is not drained
+		if !stream {
 			io.Copy(ioutil.Discard, response.Body)
 		}
-		response.Body.Close()
 	}
+	response.Body.Close()
+
+	captured.Body = string(capture.Bytes())
+	captured.Truncated = capture.Truncated()
+	return captured
+}
+
+// getBasketSubPath returns path of accepted HTTP request relative to the basket, e.g. "/r/basket/users/1" -> "/users/1"
+func getBasketSubPath(r *http.Request, name string) string {
+	return basketSubPath(r.URL.Path, name)
+}
+
+// basketSubPath returns the given path of accepted request relative to the basket
+func basketSubPath(path string, name string) string {
+	path = strings.TrimPrefix(path, serverConfig.PathPrefix+"/"+serviceRESTPath+"/"+name)
+	if len(path) == 0 {
+		return "/"
+	}
+
+	return path
 }
 
-func writeBasketResponse(w http.ResponseWriter, r *http.Request, name string, basket Basket) {
-	response := basket.GetResponse(r.Method)
-	if response == nil {
-		response = &defaultResponse
+func writeBasketResponse(w http.ResponseWriter, r *http.Request, basket Basket, request *RequestData, name string,
+	response *ResponseConfig, params map[string]string) {
+	// simulated latency
+	if response.Delay != nil && !sleep(r, response.Delay.Duration()) {
+		return
+	}
+
+	// injected faults
+	fault := pickFault(response.Faults)
+	if fault != nil {
+		switch fault.Type {
+		case FaultReset:
+			abortConnection(w, true)
+			return
+		case FaultStatus:
+			status := fault.Status
+			if status == 0 {
+				status = defaultFaultStatus
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+	}
+
+	// apply templates
+	rendered, err := renderResponse(response, r, basket, name, params)
+	if err != nil {
+		// invalid template
+		http.Error(w, "Error in "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
 	// headers
-	for k, v := range response.Headers {
+	for k, v := range rendered.headers {
 		w.Header()[k] = v
 	}
 
-	// body
-	if response.IsTemplate && len(response.Body) > 0 {
-		// template
-		t, err := template.New(name + "-" + r.Method).Parse(response.Body)
+	// echoed request or script output replace the body and payload
+	payload := len(response.Payload) > 0
+	if len(response.Echo) > 0 {
+		payload = false
+		contentType, body := echoRequest(response.Echo, request)
+		if len(contentType) > 0 {
+			w.Header().Set("Content-Type", contentType)
+		}
+		rendered.body = body
+	}
+
+	// response script may override status, headers and body
+	if len(response.Script) > 0 {
+		scripted, err := runResponseScript(response.Script, request, name, getBasketSubPath(r, name), params, basket)
 		if err != nil {
-			// invalid template
-			http.Error(w, "Error in "+err.Error(), http.StatusInternalServerError)
-		} else {
-			// status
-			w.WriteHeader(response.Status)
-			// templated body
-			t.Execute(w, r.URL.Query())
+			log.Printf("[warn] failed to run response script for basket: %s - %s", name, err)
+			http.Error(w, "Error in script: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if scripted != nil {
+			payload = false
+			for k, v := range scripted.headers {
+				w.Header()[k] = v
+			}
+			if scripted.status > 0 {
+				rendered.status = scripted.status
+			}
+			rendered.body = scripted.body
 		}
+	}
+
+	if fault != nil {
+		writeFaultyBody(w, r, rendered.status, rendered.body, fault)
+	} else if payload {
+		writePayload(w, r, rendered.status, response)
 	} else {
 		// status
-		w.WriteHeader(response.Status)
-		// plain body
-		w.Write([]byte(response.Body))
+		w.WriteHeader(rendered.status)
+		// body
+		w.Write(rendered.body)
 	}
 }
 
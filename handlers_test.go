@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"testing/iotest"
@@ -228,29 +230,10 @@ func TestCreateBasket_BrokenJson(t *testing.T) {
 func TestCreateBasket_ConfigOutOfLimit(t *testing.T) {
 	basket := "create08"
 
-	// only first 2048 bytes of config are read, bigger amount is truncated; this leads to an invalid JSON
+	// only first 64 kB of config are read, bigger amount is truncated; this leads to an invalid JSON
 	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
-		strings.NewReader("{\"capacity\": 300, \"forward_url\": \"http://localhost:8080/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/"+
-			"1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890/1234567890abcd\"}"))
+		strings.NewReader("{\"capacity\": 300, \"forward_url\": \"http://localhost:8080/"+
+			strings.Repeat("1234567890/", 6000)+"1234567890abcd\"}"))
 
 	if assert.NoError(t, err) {
 		w := httptest.NewRecorder()
@@ -1547,6 +1530,146 @@ func TestUpdateBasketResponse_ReadTimeout(t *testing.T) {
 	}
 }
 
+func TestUpdateBasketResponse_WithPath(t *testing.T) {
+	basket := "response11"
+	method := "GET"
+	path := "/v1/users/:id"
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"?path="+url.QueryEscape(path),
+		strings.NewReader("{\"status\":200,\"body\":\"user {{param \\\"id\\\"}}\",\"is_template\":true}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+			httprouter.Param{Key: "method", Value: method})
+		w := httptest.NewRecorder()
+		UpdateBasketResponse(w, r, ps)
+
+		// validate response: 204 - No Content
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+		// validate database update: default response is not affected
+		assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "default response is not expected")
+		response := basketsDb.Get(basket).GetPathResponses(method)[path]
+		if assert.NotNil(t, response, "path response is expected") {
+			assert.Equal(t, "user {{param \"id\"}}", response.Body, "wrong response body")
+		}
+
+		// get path response
+		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"?path="+url.QueryEscape(path), nil)
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			GetBasketResponse(w, r, ps)
+
+			assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+			config := new(ResponseConfig)
+			if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), config)) {
+				assert.Equal(t, "user {{param \"id\"}}", config.Body, "wrong response body")
+				assert.True(t, config.IsTemplate, "wrong value of IsTemplate flag")
+			}
+		}
+
+		// list path responses
+		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"/paths", nil)
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			GetBasketPathResponses(w, r, ps)
+
+			assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+			responses := make(map[string]*ResponseConfig)
+			if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &responses)) {
+				assert.Len(t, responses, 1, "wrong number of path responses")
+				assert.NotNil(t, responses[path], "path response is expected")
+			}
+		}
+
+		// delete path response
+		r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"?path="+url.QueryEscape(path), nil)
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			DeleteBasketResponse(w, r, ps)
+
+			assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+			assert.Empty(t, basketsDb.Get(basket).GetPathResponses(method), "path responses are not expected")
+		}
+	}
+}
+
+func TestUpdateBasketResponse_InvalidPath(t *testing.T) {
+	basket := "response12"
+	method := "GET"
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"?path=users/:",
+		strings.NewReader("{\"status\":200}"))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+			httprouter.Param{Key: "method", Value: method})
+		w := httptest.NewRecorder()
+		UpdateBasketResponse(w, r, ps)
+
+		// validate response: 400 - Bad Request
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "path pattern should start with '/'", "wrong response message")
+	}
+
+	r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, nil)
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+			httprouter.Param{Key: "method", Value: method})
+		w := httptest.NewRecorder()
+		DeleteBasketResponse(w, r, ps)
+
+		// validate response: 400 - Bad Request
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "path of response is required", "wrong response message")
+	}
+}
+
+func TestUpdateBasketResponse_TooManyPaths(t *testing.T) {
+	basket := "response13"
+	method := "GET"
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	for i := 0; i < maxPathResponses; i++ {
+		basketsDb.Get(basket).SetPathResponse(method, fmt.Sprintf("/items/%d", i), ResponseConfig{Status: 200})
+	}
+
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+		httprouter.Param{Key: "method", Value: method})
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"?path=/extra",
+		strings.NewReader("{\"status\":201}"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		UpdateBasketResponse(w, r, ps)
+
+		// validate response: 422 - Unprocessable Entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "may not have more than 200 path responses", "wrong response message")
+		assert.Len(t, basketsDb.Get(basket).GetPathResponses(method), maxPathResponses, "wrong number of path responses")
+	}
+
+	r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"/payload?path=/extra",
+		strings.NewReader("data"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		UpdateBasketResponsePayload(w, r, ps)
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+	}
+
+	// existing responses can be updated
+	r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"?path=/items/1",
+		strings.NewReader("{\"status\":201}"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		UpdateBasketResponse(w, r, ps)
+
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+		assert.Equal(t, 201, basketsDb.Get(basket).GetPathResponses(method)["/items/1"].Status, "wrong response status")
+	}
+}
+
 func TestAcceptBasketRequests_CustomResponse(t *testing.T) {
 	basket := "accept03"
 	method := "POST"
@@ -1889,6 +2012,39 @@ func TestAcceptBasketRequests_WithProxyResponse_InternalServerError(t *testing.T
 	}
 }
 
+func TestAcceptBasketRequests_WithProxyResponse_ClientGone(t *testing.T) {
+	basket := "accept12"
+
+	started := make(chan bool, 1)
+	cancelled := make(chan bool, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- true
+		select {
+		case <-r.Context().Done():
+			cancelled <- true
+		case <-time.After(3 * time.Second):
+			cancelled <- false
+		}
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL, ProxyResponse: true})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest("GET", "http://localhost:55555/r/"+basket+"/slow", nil).WithContext(ctx)
+	done := make(chan bool)
+	go func() {
+		AcceptBasketRequests(httptest.NewRecorder(), r)
+		close(done)
+	}()
+
+	// client goes away while the target is processing proxied request
+	<-started
+	cancel()
+	assert.True(t, <-cancelled, "proxied request should be cancelled along with incoming request")
+	<-done
+}
+
 func TestGetBasketNameOfAcceptedRequest_NoPrefix_Valid(t *testing.T) {
 	r, err := http.NewRequest("GET", "http://localhost:55555/basket200", strings.NewReader(""))
 	if assert.NoError(t, err) {
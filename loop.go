@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ForwardHopsHeader counts how many times request has been forwarded by baskets
+const ForwardHopsHeader = "X-Forward-Hops"
+
+const defaultMaxForwardHops = 10
+
+// viaPseudonym identifies this service instance in "Via" header of forwarded requests
+var viaPseudonym = serviceName + "-" + GenerateID()
+
+// getForwardHops returns hop count of incoming request, requests without valid hop count have no hops
+func getForwardHops(header http.Header) int {
+	hops, err := strconv.Atoi(header.Get(ForwardHopsHeader))
+	if err != nil || hops < 0 {
+		return 0
+	}
+	return hops
+}
+
+// setForwardHops increments hop count of forwarded request and registers this service in "Via" header
+func setForwardHops(forwardReq *http.Request, header http.Header) {
+	forwardReq.Header.Set(ForwardHopsHeader, strconv.Itoa(getForwardHops(header)+1))
+	forwardReq.Header.Add("Via", "1.1 "+viaPseudonym)
+}
+
+// isForwardLoop checks if incoming request has already passed this service or exceeded max number of hops,
+// such requests are collected but not forwarded
+func isForwardLoop(header http.Header, name string) bool {
+	for _, via := range header.Values("Via") {
+		for _, entry := range strings.Split(via, ",") {
+			if fields := strings.Fields(entry); len(fields) > 1 && fields[1] == viaPseudonym {
+				log.Printf("[warn] forwarding loop is detected in basket: %s", name)
+				return true
+			}
+		}
+	}
+
+	if hops := getForwardHops(header); serverConfig.MaxForwardHops > 0 && hops >= serverConfig.MaxForwardHops {
+		log.Printf("[warn] request to basket: %s has reached max number of forward hops: %d", name, hops)
+		return true
+	}
+	return false
+}
+
+// validateNotSelfURL ensures that forward URL does not point back to baskets of this service
+func validateNotSelfURL(forwardURL string) error {
+	if u, err := url.Parse(forwardURL); err == nil && isSelfURL(u) {
+		return fmt.Errorf("forward URL may not point to baskets of this service: %s", forwardURL)
+	}
+	return nil
+}
+
+// isSelfURL checks if URL addresses baskets path of this service, i.e. its host is a local address of the service
+// listener and its path starts with "/r/"
+func isSelfURL(u *url.URL) bool {
+	basketsPath := serverConfig.PathPrefix + "/" + serviceRESTPath
+	if u.Path != basketsPath && !strings.HasPrefix(u.Path, basketsPath+"/") {
+		return false
+	}
+
+	port := u.Port()
+	if len(port) == 0 {
+		switch u.Scheme {
+		case "https", "wss":
+			port = "443"
+		default:
+			port = "80"
+		}
+	}
+	if port != strconv.Itoa(serverConfig.ServerPort) {
+		return false
+	}
+
+	return isLocalHost(u.Hostname())
+}
+
+// isLocalHost checks if host refers to an address this service listens on
+func isLocalHost(host string) bool {
+	if strings.EqualFold(host, "localhost") || strings.EqualFold(host, serverConfig.ServerAddr) {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	if ip.IsLoopback() || ip.IsUnspecified() {
+		return true
+	}
+
+	// service listening on all interfaces is reachable via any local address
+	if listen := net.ParseIP(serverConfig.ServerAddr); len(serverConfig.ServerAddr) == 0 ||
+		(listen != nil && listen.IsUnspecified()) {
+		addrs, _ := net.InterfaceAddrs()
+		for _, addr := range addrs {
+			if network, ok := addr.(*net.IPNet); ok && network.IP.Equal(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetForwardHops(t *testing.T) {
+	assert.Equal(t, 0, getForwardHops(http.Header{}))
+	assert.Equal(t, 0, getForwardHops(http.Header{ForwardHopsHeader: {"abc"}}))
+	assert.Equal(t, 0, getForwardHops(http.Header{ForwardHopsHeader: {"-2"}}))
+	assert.Equal(t, 3, getForwardHops(http.Header{ForwardHopsHeader: {"3"}}))
+}
+
+func TestIsForwardLoop(t *testing.T) {
+	assert.False(t, isForwardLoop(http.Header{}, "loop"))
+	assert.False(t, isForwardLoop(http.Header{"Via": {"1.1 proxy, 1.1 request-baskets-other"}}, "loop"))
+	assert.True(t, isForwardLoop(http.Header{"Via": {"1.0 proxy, 1.1 " + viaPseudonym}}, "loop"))
+
+	max := strconv.Itoa(serverConfig.MaxForwardHops)
+	prev := strconv.Itoa(serverConfig.MaxForwardHops - 1)
+	assert.False(t, isForwardLoop(http.Header{ForwardHopsHeader: {prev}}, "loop"))
+	assert.True(t, isForwardLoop(http.Header{ForwardHopsHeader: {max}}, "loop"))
+}
+
+func TestIsSelfURL(t *testing.T) {
+	self := func(rawURL string) bool {
+		u, _ := url.Parse(rawURL)
+		return isSelfURL(u)
+	}
+
+	port := strconv.Itoa(serverConfig.ServerPort)
+	assert.True(t, self("http://localhost:"+port+"/r/basket"))
+	assert.True(t, self("http://127.0.0.1:"+port+"/r"))
+	assert.True(t, self("ws://[::1]:"+port+"/r/basket/events"))
+	assert.False(t, self("http://localhost:"+port+"/api/baskets/basket"), "not a basket path")
+	assert.False(t, self("http://localhost:"+port+"/rest"), "not a basket path")
+	assert.False(t, self("http://localhost:1234/r/basket"), "other port")
+	assert.False(t, self("http://localhost/r/basket"), "default port")
+	assert.False(t, self("http://example.com:"+port+"/r/basket"), "other host")
+
+	assert.Error(t, validateBasketConfig(&BasketConfig{Capacity: 20, ForwardURL: "http://localhost:" + port + "/r/abc"}))
+	assert.Error(t, validateBasketConfig(&BasketConfig{Capacity: 20,
+		ForwardTargets: []ForwardTarget{{URL: "http://127.0.0.1:" + port + "/r/abc"}}}))
+	assert.NoError(t, validateBasketConfig(&BasketConfig{Capacity: 20, ForwardURL: "http://localhost:8080/r/abc"}))
+}
+
+func TestAcceptBasketRequests_ForwardHops(t *testing.T) {
+	basket := "loop01"
+
+	forwarded := make(chan http.Header, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded <- r.Header
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL})
+
+	// request of chained basket is forwarded with incremented hop count
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	r.Header.Set(ForwardHopsHeader, "2")
+	r.Header.Set("Via", "1.1 request-baskets-other")
+	AcceptBasketRequests(httptest.NewRecorder(), r)
+
+	select {
+	case header := <-forwarded:
+		assert.Equal(t, "3", header.Get(ForwardHopsHeader), "wrong hop count")
+		assert.Equal(t, []string{"1.1 request-baskets-other", "1.1 " + viaPseudonym}, header.Values("Via"))
+		assert.Empty(t, header.Get(DoNotForwardHeader), "forwarding of chained baskets should not be blocked")
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "request is not forwarded")
+	}
+
+	// looped request is collected but not forwarded
+	r = httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	r.Header.Set("Via", "1.1 "+viaPseudonym)
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+
+	assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+	select {
+	case <-forwarded:
+		assert.Fail(t, "looped request should not be forwarded")
+	case <-time.After(100 * time.Millisecond):
+	}
+	assert.Equal(t, 2, basketsDb.Get(basket).GetRequests(10, 0).Count, "all requests should be collected")
+}
@@ -7,6 +7,13 @@ var serverConfig *ServerConfig
 func main() {
 	// read config
 	serverConfig = CreateConfig()
+	// apply schema migrations only
+	if serverConfig.Migrate {
+		if err := MigrateDatabase(serverConfig); err != nil {
+			log.Fatalf("[error] failed to migrate database schema: %s", err)
+		}
+		return
+	}
 	// create & start server
 	if server := CreateServer(serverConfig); server != nil {
 		if err := server.ListenAndServe(); err != nil {
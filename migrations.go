@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// Advisory lock that serializes schema migrations of service instances sharing PostgreSQL or MySQL database,
+// SQLite database is locked with immediate transaction instead
+const (
+	migrationLockID      = 20240301
+	migrationLockName    = "rb_migrations"
+	migrationLockTimeout = 300
+)
+
+// autoMigrateSchema enables migration of outdated SQL database schema when the service starts, otherwise only
+// missing schema is created and the service refuses to start with an outdated one
+var autoMigrateSchema bool
+
+// sqlMigration describes a versioned change of SQL database schema, the migration upgrades schema from the previous
+// version to its own one
+type sqlMigration struct {
+	version     int
+	description string
+	statements  []string
+}
+
+// Ordered list of migrations of SQL database schema, new migrations are always appended and existing ones
+// must never be changed, since they may be already applied to deployed databases
+var sqlMigrations = []sqlMigration{
+	{1, "create baskets, responses and requests tables", []string{
+		`CREATE TABLE rb_baskets (
+			basket_name varchar(250) PRIMARY KEY,
+			token varchar(100) NOT NULL,
+			capacity integer NOT NULL,
+			forward_url text NOT NULL,
+			proxy_response boolean NOT NULL,
+			insecure_tls boolean NOT NULL,
+			expand_path boolean NOT NULL,
+			requests_count integer NOT NULL DEFAULT 0,
+			created_at timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE rb_responses (
+			basket_name varchar(250) NOT NULL,
+			http_method varchar(20) NOT NULL,
+			response text NOT NULL,
+			PRIMARY KEY (basket_name, http_method),
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`,
+		`CREATE TABLE rb_requests (
+			basket_name varchar(250) NOT NULL,
+			request text NOT NULL,
+			created_at timestamp(3) NOT NULL DEFAULT CURRENT_TIMESTAMP(3),
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX rb_requests_name_time_index ON rb_requests (basket_name, created_at)`,
+		`CREATE TABLE rb_version (
+			version integer NOT NULL
+		)`}},
+	{2, "add path specific responses", []string{
+		`CREATE TABLE rb_path_responses (
+			basket_name varchar(250) NOT NULL,
+			http_method varchar(20) NOT NULL,
+			path_pattern varchar(250) NOT NULL,
+			response text NOT NULL,
+			PRIMARY KEY (basket_name, http_method, path_pattern),
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`}},
+	{3, "add extra configuration of baskets", []string{
+		`ALTER TABLE rb_baskets ADD COLUMN extra_config text`}},
+	{4, "add basket state", []string{
+		`CREATE TABLE rb_state (
+			basket_name varchar(250) NOT NULL,
+			state_key varchar(250) NOT NULL,
+			state_value text NOT NULL,
+			PRIMARY KEY (basket_name, state_key),
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`}},
+	{5, "add retries of failed forwards", []string{
+		`CREATE TABLE rb_retries (
+			basket_name varchar(250) NOT NULL,
+			retry_id varchar(64) NOT NULL,
+			dead boolean NOT NULL,
+			retry text NOT NULL,
+			PRIMARY KEY (basket_name, retry_id),
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`}},
+	{6, "add WebSocket sessions", []string{
+		`CREATE TABLE rb_ws_sessions (
+			basket_name varchar(250) NOT NULL,
+			session_id varchar(64) NOT NULL,
+			session text NOT NULL,
+			PRIMARY KEY (basket_name, session_id),
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`}},
+	{7, "store response payloads apart from response configuration", []string{
+		`CREATE TABLE rb_payloads (
+			basket_name varchar(250) NOT NULL,
+			http_method varchar(20) NOT NULL,
+			path_pattern varchar(250) NOT NULL,
+			payload bytea NOT NULL,
+			PRIMARY KEY (basket_name, http_method, path_pattern),
+			FOREIGN KEY (basket_name) REFERENCES rb_baskets (basket_name) ON DELETE CASCADE
+		)`}}}
+
+// MigrateDatabase applies pending migrations of SQL database schema configured for the server
+func MigrateDatabase(config *ServerConfig) error {
+	if config.DbType != DbTypeSQL {
+		return fmt.Errorf("schema migrations are only applicable to %q database type, configured: %q", DbTypeSQL,
+			config.DbType)
+	}
+
+	connection := config.DbConnection
+	if len(connection) == 0 {
+		connection = config.DbFile
+	}
+
+	db, driver := openSQLDatabase(connection)
+	if db == nil {
+		return fmt.Errorf("failed to connect to SQL database")
+	}
+	defer db.Close()
+
+	return migrateSchema(db, driver, sqlMigrations)
+}
+
+// initSchema creates missing database schema when the service starts, outdated schema is only migrated if automatic
+// migrations are enabled
+func initSchema(db *sql.DB, dbType string, migrations []sqlMigration) error {
+	if version := getSchemaVersion(db); !autoMigrateSchema && version > 0 && version < len(migrations) {
+		return fmt.Errorf("database schema version %v is outdated, latest version: %v - apply migrations with "+
+			"-migrate argument or start the service with -auto-migrate argument", version, len(migrations))
+	}
+	return migrateSchema(db, dbType, migrations)
+}
+
+// migrateSchema upgrades database schema to the latest version by applying pending migrations in order, the schema
+// is locked while migrations are applied, so concurrently started instances of the service do not race each other
+func migrateSchema(db *sql.DB, dbType string, migrations []sqlMigration) error {
+	latest := 0
+	for idx, migration := range migrations {
+		if migration.version != idx+1 {
+			return fmt.Errorf("migration #%v has unexpected version: %v", idx, migration.version)
+		}
+		latest = migration.version
+	}
+
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection for migration - %s", err)
+	}
+	defer conn.Close()
+
+	unlock, err := lockSchema(ctx, conn, dbType)
+	if err != nil {
+		return err
+	}
+	err = applyMigrations(ctx, conn, dbType, migrations, latest)
+	if unlockErr := unlock(); err == nil && unlockErr != nil {
+		err = fmt.Errorf("failed to release lock of database schema - %s", unlockErr)
+	}
+	return err
+}
+
+// lockSchema acquires exclusive lock of database schema for the connection and returns function that releases it
+func lockSchema(ctx context.Context, conn *sql.Conn, dbType string) (func() error, error) {
+	var err error
+	unlock := func() error { return nil }
+
+	switch dbType {
+	case "postgres":
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID)
+		unlock = func() error {
+			_, err := conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", migrationLockID)
+			return err
+		}
+	case "mysql":
+		var locked sql.NullInt64
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLockName, migrationLockTimeout).Scan(&locked)
+		if err == nil && locked.Int64 != 1 {
+			err = fmt.Errorf("timeout of %v seconds is exceeded", migrationLockTimeout)
+		}
+		unlock = func() error {
+			var released sql.NullInt64
+			return conn.QueryRowContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLockName).Scan(&released)
+		}
+	case "sqlite":
+		// migrations are applied within savepoints of this transaction
+		_, err = conn.ExecContext(ctx, "BEGIN IMMEDIATE")
+		unlock = func() error {
+			_, err := conn.ExecContext(ctx, "COMMIT")
+			return err
+		}
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock database schema for migration - %s", err)
+	}
+	return unlock, nil
+}
+
+// applyMigrations applies pending migrations over locked connection
+func applyMigrations(ctx context.Context, db *sql.Conn, dbType string, migrations []sqlMigration, latest int) error {
+	version := getSchemaVersion(db)
+	switch {
+	case version == latest:
+		log.Printf("[info] database schema already exists, version: %v", version)
+		return nil
+	case version > latest:
+		return fmt.Errorf("unknown database schema version: %v, latest known version: %v", version, latest)
+	case version == 0:
+		log.Printf("[info] creating database schema")
+	}
+
+	for _, migration := range migrations[version:] {
+		log.Printf("[info] migrating database schema to version: %v - %s", migration.version, migration.description)
+		if err := applyMigration(ctx, db, dbType, migration); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("[info] database schema is migrated, version: %v", getSchemaVersion(db))
+	return nil
+}
+
+// applyMigration applies statements of migration and updates schema version within a single transaction, so failed
+// migration leaves schema in the previous version. Note: MySQL commits DDL statements implicitly, so statements
+// applied before the failed one are kept there.
+func applyMigration(ctx context.Context, db *sql.Conn, dbType string, migration sqlMigration) error {
+	tx, err := beginMigration(ctx, db, dbType)
+	if err != nil {
+		return fmt.Errorf("failed to start migration to version %v - %s", migration.version, err)
+	}
+	defer tx.Rollback()
+
+	for idx, stmt := range migration.statements {
+		if _, err = tx.ExecContext(ctx, unifyDDL(dbType, stmt)); err != nil {
+			return fmt.Errorf("error in SQL statement #%v of migration to version %v - %s", idx, migration.version, err)
+		}
+	}
+
+	// version table is created by the first migration
+	versionSQL := "UPDATE rb_version SET version = $1"
+	if migration.version == 1 {
+		versionSQL = "INSERT INTO rb_version (version) VALUES ($1)"
+	}
+	if _, err = tx.ExecContext(ctx, unifySQL(dbType, versionSQL), migration.version); err != nil {
+		return fmt.Errorf("failed to update schema version to %v - %s", migration.version, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration to version %v - %s", migration.version, err)
+	}
+	return nil
+}
+
+// migrationTx is a transaction that applies a single migration
+type migrationTx interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	Commit() error
+	Rollback() error
+}
+
+// beginMigration starts transaction of migration, SQLite uses savepoint since the schema is already locked with
+// a transaction of the same connection
+func beginMigration(ctx context.Context, db *sql.Conn, dbType string) (migrationTx, error) {
+	if dbType != "sqlite" {
+		return db.BeginTx(ctx, nil)
+	}
+	if _, err := db.ExecContext(ctx, "SAVEPOINT rb_migration"); err != nil {
+		return nil, err
+	}
+	return &sqliteSavepoint{db, ctx, false}, nil
+}
+
+type sqliteSavepoint struct {
+	*sql.Conn
+	ctx  context.Context
+	done bool
+}
+
+func (sp *sqliteSavepoint) Commit() error {
+	sp.done = true
+	_, err := sp.Conn.ExecContext(sp.ctx, "RELEASE rb_migration")
+	return err
+}
+
+func (sp *sqliteSavepoint) Rollback() error {
+	if sp.done {
+		return sql.ErrTxDone
+	}
+	sp.done = true
+	if _, err := sp.Conn.ExecContext(sp.ctx, "ROLLBACK TO rb_migration"); err != nil {
+		return err
+	}
+	_, err := sp.Conn.ExecContext(sp.ctx, "RELEASE rb_migration")
+	return err
+}
+
+// sqlQuerier is implemented by database and its connections
+type sqlQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func getSchemaVersion(db sqlQuerier) int {
+	var version int
+	if err := db.QueryRowContext(context.Background(), "SELECT version FROM rb_version").Scan(&version); err != nil {
+		return 0
+	}
+
+	return version
+}
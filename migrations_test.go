@@ -0,0 +1,172 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openMigrationsTestDatabase(t *testing.T, file string) *sql.DB {
+	db, driver := openSQLDatabase("sqlite3://" + file)
+	if !assert.NotNil(t, db, "SQLite database is expected") || !assert.Equal(t, "sqlite", driver) {
+		t.FailNow()
+	}
+	return db
+}
+
+func removeMigrationsTestDatabase(file string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(file + suffix)
+	}
+}
+
+func TestMigrations_Versions(t *testing.T) {
+	for idx, migration := range sqlMigrations {
+		assert.Equal(t, idx+1, migration.version, "migrations must be ordered by version without gaps")
+		assert.NotEmpty(t, migration.description, "description of migration is expected")
+		assert.NotEmpty(t, migration.statements, "statements of migration are expected")
+	}
+}
+
+func TestMigrateSchema(t *testing.T) {
+	file := "./migrations_test01.db"
+	defer removeMigrationsTestDatabase(file)
+	db := openMigrationsTestDatabase(t, file)
+	defer db.Close()
+
+	if assert.NoError(t, migrateSchema(db, "sqlite", sqlMigrations)) {
+		assert.Equal(t, len(sqlMigrations), getSchemaVersion(db), "latest schema version is expected")
+	}
+	// nothing to apply
+	assert.NoError(t, migrateSchema(db, "sqlite", sqlMigrations))
+	assert.Equal(t, len(sqlMigrations), getSchemaVersion(db))
+}
+
+func TestMigrateSchema_Upgrade(t *testing.T) {
+	file := "./migrations_test02.db"
+	defer removeMigrationsTestDatabase(file)
+	db := openMigrationsTestDatabase(t, file)
+	defer db.Close()
+
+	// database of an older release
+	if assert.NoError(t, migrateSchema(db, "sqlite", sqlMigrations[:2])) {
+		assert.Equal(t, 2, getSchemaVersion(db))
+	}
+	_, err := db.Exec("INSERT INTO rb_baskets (basket_name, token, capacity, forward_url, proxy_response, insecure_tls, expand_path) VALUES ('old', 'abc', 20, '', false, false, false)")
+	assert.NoError(t, err)
+
+	if assert.NoError(t, migrateSchema(db, "sqlite", sqlMigrations)) {
+		assert.Equal(t, len(sqlMigrations), getSchemaVersion(db), "latest schema version is expected")
+	}
+
+	// existing baskets are kept
+	sdb := &sqlDatabase{db, "sqlite"}
+	if basket := sdb.Get("old"); assert.NotNil(t, basket, "basket created before migration is expected") {
+		assert.Equal(t, 20, basket.Config().Capacity)
+		assert.NoError(t, basket.SetStateValue("key", "value"), "table of later migration is expected")
+	}
+}
+
+func TestMigrateSchema_FailedMigration(t *testing.T) {
+	file := "./migrations_test03.db"
+	defer removeMigrationsTestDatabase(file)
+	db := openMigrationsTestDatabase(t, file)
+	defer db.Close()
+
+	migrations := append(sqlMigrations[:1:1], sqlMigration{2, "broken migration", []string{
+		`CREATE TABLE rb_broken (id integer NOT NULL)`,
+		`ALTER TABLE rb_missing ADD COLUMN name text`}})
+
+	err := migrateSchema(db, "sqlite", migrations)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "statement #1 of migration to version 2")
+	}
+
+	// failed migration is rolled back, previous ones are kept
+	assert.Equal(t, 1, getSchemaVersion(db), "schema version before failed migration is expected")
+	_, err = db.Exec("SELECT id FROM rb_broken")
+	assert.Error(t, err, "changes of failed migration are not expected")
+}
+
+func TestMigrateSchema_UnknownVersion(t *testing.T) {
+	file := "./migrations_test04.db"
+	defer removeMigrationsTestDatabase(file)
+	db := openMigrationsTestDatabase(t, file)
+	defer db.Close()
+
+	assert.NoError(t, migrateSchema(db, "sqlite", sqlMigrations))
+	err := migrateSchema(db, "sqlite", sqlMigrations[:3])
+	if assert.Error(t, err, "schema of a newer release may not be used") {
+		assert.Contains(t, err.Error(), "unknown database schema version")
+	}
+
+	invalid := []sqlMigration{{1, "first", []string{"SELECT 1"}}, {3, "gap", []string{"SELECT 1"}}}
+	assert.Error(t, migrateSchema(db, "sqlite", invalid), "gap in migration versions is not expected")
+}
+
+func TestMigrateDatabase(t *testing.T) {
+	file := "./migrations_test05.db"
+	defer removeMigrationsTestDatabase(file)
+
+	assert.Error(t, MigrateDatabase(&ServerConfig{DbType: DbTypeBolt, DbFile: file}), "SQL database is expected")
+	assert.Error(t, MigrateDatabase(&ServerConfig{DbType: DbTypeSQL, DbFile: "invalid"}), "connection error is expected")
+
+	if assert.NoError(t, MigrateDatabase(&ServerConfig{DbType: DbTypeSQL, DbConnection: "sqlite3://" + file})) {
+		db := openMigrationsTestDatabase(t, file)
+		defer db.Close()
+		assert.Equal(t, len(sqlMigrations), getSchemaVersion(db), "latest schema version is expected")
+	}
+}
+
+func TestInitSchema(t *testing.T) {
+	file := "./migrations_test06.db"
+	defer removeMigrationsTestDatabase(file)
+	db := openMigrationsTestDatabase(t, file)
+	defer db.Close()
+
+	// missing schema is created
+	if assert.NoError(t, initSchema(db, "sqlite", sqlMigrations[:2])) {
+		assert.Equal(t, 2, getSchemaVersion(db))
+	}
+
+	// outdated schema is only migrated automatically if enabled
+	err := initSchema(db, "sqlite", sqlMigrations)
+	if assert.Error(t, err, "outdated schema is not expected") {
+		assert.Contains(t, err.Error(), "database schema version 2 is outdated")
+	}
+	assert.Equal(t, 2, getSchemaVersion(db), "schema should not be migrated")
+
+	autoMigrateSchema = true
+	defer func() { autoMigrateSchema = false }()
+	if assert.NoError(t, initSchema(db, "sqlite", sqlMigrations)) {
+		assert.Equal(t, len(sqlMigrations), getSchemaVersion(db), "latest schema version is expected")
+	}
+}
+
+func TestMigrateSchema_Concurrent(t *testing.T) {
+	file := "./migrations_test07.db"
+	defer removeMigrationsTestDatabase(file)
+
+	errs := make(chan error, 4)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			db, _ := openSQLDatabase("sqlite3://" + file)
+			if db == nil {
+				errs <- fmt.Errorf("failed to open database")
+				return
+			}
+			defer db.Close()
+			errs <- migrateSchema(db, "sqlite", sqlMigrations)
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		assert.NoError(t, <-errs, "concurrent migrations should wait for each other")
+	}
+
+	db := openMigrationsTestDatabase(t, file)
+	defer db.Close()
+	assert.Equal(t, len(sqlMigrations), getSchemaVersion(db), "latest schema version is expected")
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	maxOpenAPISize     = 2 * 1024 * 1024 // 2 MB
+	maxOpenAPINodes    = 1000000
+	defaultOpenAPIType = "application/json"
+)
+
+var errOpenAPITooLarge = fmt.Errorf("OpenAPI document may not be greater than %d bytes", maxOpenAPISize)
+var openAPIPathParam = regexp.MustCompile(`^\{([^{}]+)\}$`)
+var invalidParamChars = regexp.MustCompile(`\W`)
+
+// openAPIMethods lists HTTP methods of OpenAPI path item in the order of import
+var openAPIMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS", "TRACE"}
+
+type openAPIDocument struct {
+	OpenAPI string                      `json:"openapi"`
+	Servers []openAPIServer             `json:"servers"`
+	Paths   map[string]*openAPIPathItem `json:"paths"`
+}
+
+type openAPIServer struct {
+	URL string `json:"url"`
+}
+
+type openAPIPathItem struct {
+	Parameters []*openAPIParameter `json:"parameters"`
+	Get        *openAPIOperation   `json:"get"`
+	Head       *openAPIOperation   `json:"head"`
+	Post       *openAPIOperation   `json:"post"`
+	Put        *openAPIOperation   `json:"put"`
+	Patch      *openAPIOperation   `json:"patch"`
+	Delete     *openAPIOperation   `json:"delete"`
+	Options    *openAPIOperation   `json:"options"`
+	Trace      *openAPIOperation   `json:"trace"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Parameters  []*openAPIParameter         `json:"parameters"`
+	RequestBody *openAPIRequestBody         `json:"requestBody"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name     string      `json:"name"`
+	In       string      `json:"in"`
+	Required bool        `json:"required"`
+	Schema   *JSONSchema `json:"schema"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                         `json:"required"`
+	Content  map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Headers map[string]*openAPIHeader    `json:"headers"`
+	Content map[string]*openAPIMediaType `json:"content"`
+}
+
+type openAPIHeader struct {
+	Schema  *JSONSchema `json:"schema"`
+	Example interface{} `json:"example"`
+}
+
+type openAPIMediaType struct {
+	Schema   *JSONSchema                `json:"schema"`
+	Example  interface{}                `json:"example"`
+	Examples map[string]*openAPIExample `json:"examples"`
+}
+
+type openAPIExample struct {
+	Value interface{} `json:"value"`
+}
+
+// openAPIImport describes result of OpenAPI document import
+type openAPIImport struct {
+	Responses int      `json:"responses"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// readOpenAPIDocument reads and parses OpenAPI 3 document (JSON or YAML) from HTTP request body
+func readOpenAPIDocument(r *http.Request) (*openAPIDocument, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r.Body, maxOpenAPISize+1))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxOpenAPISize {
+		return nil, errOpenAPITooLarge
+	}
+
+	return parseOpenAPI(data)
+}
+
+// parseOpenAPI parses OpenAPI 3 document, all local references ("$ref") are resolved
+func parseOpenAPI(data []byte) (*openAPIDocument, error) {
+	// YAML is a superset of JSON
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %s", err)
+	}
+
+	root, ok := normalizeYAML(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid OpenAPI document: object is expected")
+	}
+
+	resolver := &refResolver{root: root}
+	resolved := resolver.resolve(root, nil)
+	if resolver.err != nil {
+		return nil, resolver.err
+	}
+
+	tree, err := json.Marshal(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %s", err)
+	}
+
+	doc := new(openAPIDocument)
+	if err = json.Unmarshal(tree, doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %s", err)
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("only OpenAPI 3.x documents are supported, but version was: %q", doc.OpenAPI)
+	}
+	if len(doc.Paths) == 0 {
+		return nil, fmt.Errorf("OpenAPI document has no paths")
+	}
+
+	return doc, nil
+}
+
+// normalizeYAML converts YAML maps with non-string keys (e.g. HTTP status codes) into JSON compatible maps
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalizeYAML(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[fmt.Sprint(key)] = normalizeYAML(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalizeYAML(item)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// refResolver replaces local JSON references with referenced content, recursive references are replaced
+// with empty objects
+type refResolver struct {
+	root  map[string]interface{}
+	nodes int
+	err   error
+}
+
+func (resolver *refResolver) resolve(value interface{}, stack []string) interface{} {
+	if resolver.err != nil {
+		return nil
+	}
+	if resolver.nodes++; resolver.nodes > maxOpenAPINodes {
+		resolver.err = fmt.Errorf("OpenAPI document is too complex, max. %d nodes are supported after resolving references", maxOpenAPINodes)
+		return nil
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok {
+			for _, visited := range stack {
+				if visited == ref {
+					return map[string]interface{}{}
+				}
+			}
+
+			target, err := lookupJSONPointer(resolver.root, ref)
+			if err != nil {
+				resolver.err = err
+				return nil
+			}
+			return resolver.resolve(target, append(stack, ref))
+		}
+
+		m := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			m[key] = resolver.resolve(item, stack)
+		}
+		return m
+	case []interface{}:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = resolver.resolve(item, stack)
+		}
+		return items
+	default:
+		return value
+	}
+}
+
+// lookupJSONPointer finds value within document by local JSON reference like "#/components/schemas/User"
+func lookupJSONPointer(root interface{}, ref string) (interface{}, error) {
+	if !strings.HasPrefix(ref, "#/") {
+		return nil, fmt.Errorf("only local references are supported in OpenAPI document: %s", ref)
+	}
+
+	node := root
+	for _, token := range strings.Split(ref[2:], "/") {
+		if unescaped, err := url.PathUnescape(token); err == nil {
+			token = unescaped
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+
+		switch v := node.(type) {
+		case map[string]interface{}:
+			item, ok := v[token]
+			if !ok {
+				return nil, fmt.Errorf("unresolved reference in OpenAPI document: %s", ref)
+			}
+			node = item
+		case []interface{}:
+			index, err := strconv.Atoi(token)
+			if err != nil || index < 0 || index >= len(v) {
+				return nil, fmt.Errorf("unresolved reference in OpenAPI document: %s", ref)
+			}
+			node = v[index]
+		default:
+			return nil, fmt.Errorf("unresolved reference in OpenAPI document: %s", ref)
+		}
+	}
+
+	return node, nil
+}
+
+// basePath returns path of the first server URL, e.g. "/v1" for "https://api.example.com/v1"
+func (doc *openAPIDocument) basePath() string {
+	if len(doc.Servers) == 0 || strings.Contains(doc.Servers[0].URL, "{") {
+		return ""
+	}
+
+	server, err := url.Parse(doc.Servers[0].URL)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(server.Path, "/")
+}
+
+// operations returns operations of path item by HTTP method
+func (item *openAPIPathItem) operations() map[string]*openAPIOperation {
+	return map[string]*openAPIOperation{
+		"GET":     item.Get,
+		"HEAD":    item.Head,
+		"POST":    item.Post,
+		"PUT":     item.Put,
+		"PATCH":   item.Patch,
+		"DELETE":  item.Delete,
+		"OPTIONS": item.Options,
+		"TRACE":   item.Trace}
+}
+
+// importOpenAPI generates path responses of basket for every operation defined in OpenAPI document
+func importOpenAPI(basket Basket, doc *openAPIDocument) *openAPIImport {
+	result := new(openAPIImport)
+	base := doc.basePath()
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		item := doc.Paths[path]
+		if item == nil {
+			continue
+		}
+
+		pattern, names, err := toPathPattern(base + path)
+		if err != nil {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("path %s is skipped: %s", path, err))
+			continue
+		}
+
+		operations := item.operations()
+		for _, method := range openAPIMethods {
+			op := operations[method]
+			if op == nil {
+				continue
+			}
+
+			response := generateOpenAPIResponse(op)
+			response.Validation = generateOpenAPIValidation(item, op, names)
+			err = validateResponseConfig(&response)
+			if err == nil {
+				err = validatePathResponsesCount(basket, method, pattern)
+			}
+			if err != nil {
+				result.Warnings = append(result.Warnings, fmt.Sprintf("operation %s %s is skipped: %s", method, path, err))
+				continue
+			}
+
+			basket.SetPathResponse(method, pattern, response)
+			result.Responses++
+		}
+	}
+
+	return result
+}
+
+// toPathPattern converts OpenAPI path template like "/users/{user-id}" into path pattern of basket response like
+// "/users/:user_id", returns pattern and names of path parameters mapped to names used in the pattern
+func toPathPattern(path string) (string, map[string]string, error) {
+	names := make(map[string]string)
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if !strings.ContainsAny(segment, "{}") {
+			continue
+		}
+
+		match := openAPIPathParam.FindStringSubmatch(segment)
+		if match == nil {
+			return "", nil, fmt.Errorf("path templates within a segment are not supported: %s", segment)
+		}
+
+		name := invalidParamChars.ReplaceAllString(match[1], "_")
+		names[match[1]] = name
+		segments[i] = ":" + name
+	}
+
+	pattern := strings.Join(segments, "/")
+	if len(pattern) > 1 {
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	if len(pattern) == 0 {
+		pattern = "/"
+	}
+
+	return pattern, names, validatePathPattern(pattern)
+}
+
+// generateOpenAPIResponse generates basket response using examples or schemas of successful operation response
+func generateOpenAPIResponse(op *openAPIOperation) ResponseConfig {
+	status, spec := selectOpenAPIResponse(op.Responses)
+	response := ResponseConfig{Status: status, Headers: http.Header{}}
+	if spec == nil {
+		return response
+	}
+
+	// headers
+	for name, header := range spec.Headers {
+		if header == nil || strings.EqualFold(name, "Content-Type") {
+			continue
+		}
+
+		value := header.Example
+		if value == nil && header.Schema != nil {
+			value = header.Schema.Sample()
+		}
+		if value != nil {
+			response.Headers.Set(name, fmt.Sprint(value))
+		}
+	}
+
+	// body
+	if mediaType, content := selectMediaType(spec.Content); content != nil {
+		if !strings.Contains(mediaType, "*") {
+			response.Headers.Set("Content-Type", mediaType)
+		}
+
+		value := content.sample()
+		if text, ok := value.(string); ok && !isJSONMediaType(mediaType) {
+			response.Body = text
+		} else if value != nil {
+			if body, err := json.MarshalIndent(value, "", "  "); err == nil {
+				response.Body = string(body)
+			}
+		}
+	}
+
+	return response
+}
+
+// selectOpenAPIResponse selects the first successful response of operation, falls back to default one
+func selectOpenAPIResponse(responses map[string]*openAPIResponse) (int, *openAPIResponse) {
+	codes := make([]string, 0, len(responses))
+	for code := range responses {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	for _, code := range codes {
+		if strings.HasPrefix(code, "2") {
+			if status, err := strconv.Atoi(code); err == nil {
+				return status, responses[code]
+			}
+			return http.StatusOK, responses[code]
+		}
+	}
+
+	if response, ok := responses["default"]; ok {
+		return http.StatusOK, response
+	}
+	for _, code := range codes {
+		if status, err := strconv.Atoi(code); err == nil && status >= 100 && status < 600 {
+			return status, responses[code]
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// selectMediaType prefers JSON content if multiple media types are defined
+func selectMediaType(content map[string]*openAPIMediaType) (string, *openAPIMediaType) {
+	if media, ok := content[defaultOpenAPIType]; ok {
+		return defaultOpenAPIType, media
+	}
+
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+
+	for _, mediaType := range types {
+		if isJSONMediaType(mediaType) {
+			return mediaType, content[mediaType]
+		}
+	}
+	if len(types) > 0 {
+		return types[0], content[types[0]]
+	}
+
+	return "", nil
+}
+
+// sample returns example of media type content, generates one from schema if no example is provided
+func (media *openAPIMediaType) sample() interface{} {
+	if media.Example != nil {
+		return media.Example
+	}
+
+	names := make([]string, 0, len(media.Examples))
+	for name := range media.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if example := media.Examples[name]; example != nil && example.Value != nil {
+			return example.Value
+		}
+	}
+
+	return media.Schema.Sample()
+}
+
+// generateOpenAPIValidation generates constraints of HTTP requests accepted by operation
+func generateOpenAPIValidation(item *openAPIPathItem, op *openAPIOperation, names map[string]string) *RequestValidation {
+	validation := &RequestValidation{Operation: op.OperationID}
+
+	// operation parameters override parameters of path item
+	params := make(map[string]*openAPIParameter)
+	keys := make([]string, 0)
+	for _, param := range append(append([]*openAPIParameter{}, item.Parameters...), op.Parameters...) {
+		if param == nil || len(param.Name) == 0 {
+			continue
+		}
+
+		key := param.In + ":" + param.Name
+		if _, ok := params[key]; !ok {
+			keys = append(keys, key)
+		}
+		params[key] = param
+	}
+
+	for _, key := range keys {
+		param := params[key]
+		name := param.Name
+		if param.In == ParamInPath {
+			if _, ok := names[name]; !ok {
+				// parameter is not part of the path
+				continue
+			}
+			name = names[name]
+		}
+
+		validation.Parameters = append(validation.Parameters, RequestParameter{
+			Name:     name,
+			In:       param.In,
+			Required: param.Required || param.In == ParamInPath,
+			Schema:   param.Schema})
+	}
+
+	// request body
+	if op.RequestBody != nil {
+		validation.BodyRequired = op.RequestBody.Required
+		for mediaType := range op.RequestBody.Content {
+			validation.ContentTypes = append(validation.ContentTypes, mediaType)
+		}
+		sort.Strings(validation.ContentTypes)
+
+		if mediaType, content := selectMediaType(op.RequestBody.Content); content != nil && isJSONMediaType(mediaType) {
+			validation.Body = content.Schema
+		}
+	}
+
+	return validation
+}
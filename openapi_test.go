@@ -0,0 +1,307 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+const petstoreSpec = `
+openapi: 3.0.3
+info:
+  title: Petstore
+  version: 1.0.0
+servers:
+  - url: https://petstore.example.com/v1
+paths:
+  /pets:
+    get:
+      operationId: listPets
+      parameters:
+        - name: limit
+          in: query
+          schema:
+            type: integer
+            maximum: 100
+      responses:
+        200:
+          description: List of pets
+          headers:
+            X-Total-Count:
+              schema:
+                type: integer
+                example: 2
+          content:
+            application/json:
+              schema:
+                type: array
+                items:
+                  $ref: '#/components/schemas/Pet'
+    post:
+      operationId: createPet
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/NewPet'
+      responses:
+        '201':
+          description: Created
+          content:
+            application/json:
+              example: {"id": 10, "name": "Rex"}
+        default:
+          description: Error
+  /pets/{pet-id}:
+    parameters:
+      - name: pet-id
+        in: path
+        required: true
+        schema:
+          type: integer
+    get:
+      operationId: getPet
+      responses:
+        '200':
+          description: Pet
+          content:
+            application/json:
+              examples:
+                dog:
+                  value: {"id": 1, "name": "Rex", "tag": "dog"}
+        '404':
+          description: Not found
+    delete:
+      responses:
+        '204':
+          description: Deleted
+  /files/{name}.json:
+    get:
+      responses:
+        '200':
+          description: File
+components:
+  schemas:
+    NewPet:
+      type: object
+      required: [name]
+      properties:
+        name:
+          type: string
+        tag:
+          type: string
+    Pet:
+      allOf:
+        - $ref: '#/components/schemas/NewPet'
+        - type: object
+          required: [id]
+          properties:
+            id:
+              type: integer
+              format: int64
+              example: 1
+            parent:
+              $ref: '#/components/schemas/Pet'
+`
+
+func TestParseOpenAPI(t *testing.T) {
+	doc, err := parseOpenAPI([]byte(petstoreSpec))
+	if assert.NoError(t, err) {
+		assert.Equal(t, "3.0.3", doc.OpenAPI)
+		assert.Equal(t, "/v1", doc.basePath())
+		assert.Len(t, doc.Paths, 3)
+
+		pets := doc.Paths["/pets"]
+		if assert.NotNil(t, pets) && assert.NotNil(t, pets.Post) && assert.NotNil(t, pets.Post.RequestBody) {
+			schema := pets.Post.RequestBody.Content["application/json"].Schema
+			assert.Equal(t, []string{"name"}, schema.Required, "reference is expected to be resolved")
+		}
+	}
+
+	// JSON is accepted as well
+	_, err = parseOpenAPI([]byte(`{"openapi": "3.1.0", "paths": {"/": {"get": {"responses": {"200": {}}}}}}`))
+	assert.NoError(t, err)
+
+	_, err = parseOpenAPI([]byte(`{"swagger": "2.0", "paths": {}}`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "only OpenAPI 3.x documents are supported")
+	}
+
+	_, err = parseOpenAPI([]byte(`{"openapi": "3.0.0", "paths": {}}`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "OpenAPI document has no paths")
+	}
+
+	_, err = parseOpenAPI([]byte(`{"openapi": "3.0.0", "paths": {"/": {"$ref": "#/components/missing"}}}`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unresolved reference in OpenAPI document: #/components/missing")
+	}
+
+	_, err = parseOpenAPI([]byte(`[1, 2`))
+	assert.Error(t, err)
+}
+
+func TestToPathPattern(t *testing.T) {
+	pattern, names, err := toPathPattern("/v1/users/{user-id}/orders/{id}")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/v1/users/:user_id/orders/:id", pattern)
+		assert.Equal(t, map[string]string{"user-id": "user_id", "id": "id"}, names)
+	}
+
+	pattern, _, err = toPathPattern("/v1/")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/v1", pattern)
+	}
+
+	pattern, _, err = toPathPattern("")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "/", pattern)
+	}
+
+	_, _, err = toPathPattern("/files/{name}.json")
+	assert.Error(t, err)
+}
+
+func TestImportOpenAPI(t *testing.T) {
+	basket := "openapi01"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	b := basketsDb.Get(basket)
+
+	doc, err := parseOpenAPI([]byte(petstoreSpec))
+	if assert.NoError(t, err) {
+		result := importOpenAPI(b, doc)
+		assert.Equal(t, 4, result.Responses, "wrong number of generated responses")
+		if assert.Len(t, result.Warnings, 1) {
+			assert.Contains(t, result.Warnings[0], "path /files/{name}.json is skipped")
+		}
+
+		// list of pets
+		list := b.GetPathResponses("GET")["/v1/pets"]
+		if assert.NotNil(t, list) {
+			assert.Equal(t, 200, list.Status)
+			assert.Equal(t, "application/json", list.Headers.Get("Content-Type"))
+			assert.Equal(t, "2", list.Headers.Get("X-Total-Count"))
+			assert.JSONEq(t, `[{"id": 1, "name": "string", "tag": "string", "parent": null}]`, list.Body)
+			if assert.NotNil(t, list.Validation) {
+				assert.Equal(t, "listPets", list.Validation.Operation)
+				assert.Len(t, list.Validation.Parameters, 1)
+			}
+		}
+
+		// created pet
+		created := b.GetPathResponses("POST")["/v1/pets"]
+		if assert.NotNil(t, created) {
+			assert.Equal(t, 201, created.Status)
+			assert.JSONEq(t, `{"id": 10, "name": "Rex"}`, created.Body)
+			assert.True(t, created.Validation.BodyRequired)
+			assert.Equal(t, []string{"application/json"}, created.Validation.ContentTypes)
+		}
+
+		// single pet
+		pet := b.GetPathResponses("GET")["/v1/pets/:pet_id"]
+		if assert.NotNil(t, pet) {
+			assert.JSONEq(t, `{"id": 1, "name": "Rex", "tag": "dog"}`, pet.Body)
+			assert.Equal(t, []RequestParameter{{Name: "pet_id", In: ParamInPath, Required: true,
+				Schema: &JSONSchema{Type: schemaTypes{"integer"}}}}, pet.Validation.Parameters)
+		}
+
+		deleted := b.GetPathResponses("DELETE")["/v1/pets/:pet_id"]
+		if assert.NotNil(t, deleted) {
+			assert.Equal(t, 204, deleted.Status)
+			assert.Empty(t, deleted.Body)
+		}
+	}
+}
+
+func TestCreateBasketFromOpenAPI(t *testing.T) {
+	basket := "openapi02"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/openapi", strings.NewReader(petstoreSpec))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasketFromOpenAPI(w, r, ps)
+
+		// validate response: 201 - created
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		result := make(map[string]interface{})
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result)) {
+			assert.NotEmpty(t, result["token"], "basket token is expected")
+			assert.Equal(t, float64(4), result["responses"], "wrong number of generated responses")
+		}
+		assert.NotNil(t, basketsDb.Get(basket), "basket is expected to be created")
+
+		// basket already exists
+		r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/openapi", strings.NewReader(petstoreSpec))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			CreateBasketFromOpenAPI(w, r, ps)
+			assert.Equal(t, 409, w.Code, "wrong HTTP result code")
+		}
+
+		// re-import into existing basket
+		r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/openapi", strings.NewReader(petstoreSpec))
+		if assert.NoError(t, err) {
+			r.Header.Add("Authorization", result["token"].(string))
+			w = httptest.NewRecorder()
+			ImportBasketOpenAPI(w, r, ps)
+			assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+			assert.Contains(t, w.Body.String(), "\"responses\":4")
+		}
+	}
+}
+
+func TestCreateBasketFromOpenAPI_Invalid(t *testing.T) {
+	basket := "openapi03"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/openapi", strings.NewReader("openapi: 2.0"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasketFromOpenAPI(w, r, ps)
+
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Nil(t, basketsDb.Get(basket), "basket is not expected to be created")
+	}
+}
+
+func TestAcceptBasketRequests_OpenAPIViolations(t *testing.T) {
+	basket := "openapi04"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+
+	doc, err := parseOpenAPI([]byte(petstoreSpec))
+	if assert.NoError(t, err) {
+		importOpenAPI(basketsDb.Get(basket), doc)
+	}
+
+	// valid request
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket+"/v1/pets", strings.NewReader("{\"name\":\"Rex\"}"))
+	if assert.NoError(t, err) {
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 201, w.Code, "wrong HTTP response code")
+		assert.JSONEq(t, `{"id": 10, "name": "Rex"}`, w.Body.String())
+		request := basketsDb.Get(basket).GetRequests(1, 0).Requests[0]
+		assert.Empty(t, request.Violations, "no violations are expected")
+		assert.Equal(t, "{\"name\":\"Rex\"}", request.Body, "wrong collected body")
+	}
+
+	// invalid request is still answered, but flagged
+	r, err = http.NewRequest("GET", "http://localhost:55555/r/"+basket+"/v1/pets?limit=1000", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+		request := basketsDb.Get(basket).GetRequests(1, 0).Requests[0]
+		assert.Equal(t, []string{"query parameter limit: value 1000 is greater than maximum 100"}, request.Violations)
+	}
+}
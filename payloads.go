@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+const (
+	maxResponsePayloadSize = 10 * 1024 * 1024 // 10 MB
+	maxPayloadNameLength   = 250
+	payloadFormField       = "file"
+)
+
+var errPayloadTooLarge = fmt.Errorf("response payload may not be greater than %d bytes", maxResponsePayloadSize)
+
+// payloadLimiter is implemented by baskets of storages that can not keep payloads of max size
+type payloadLimiter interface {
+	maxPayloadSize() int
+}
+
+// maxBasketPayloadSize returns max size of response payload that can be stored in basket
+func maxBasketPayloadSize(basket Basket) int {
+	if limiter, ok := basket.(payloadLimiter); ok {
+		return limiter.maxPayloadSize()
+	}
+	return maxResponsePayloadSize
+}
+
+// payloadKey identifies payload of basket response configured for HTTP method and optional path pattern
+func payloadKey(method string, path string) string {
+	return method + " " + path
+}
+
+// detachPayload detaches binary payload from response configuration, so it is stored apart from configuration;
+// payload size, type and name are kept in configuration
+func detachPayload(response *ResponseConfig) []byte {
+	payload := response.Payload
+	response.Payload = nil
+	if len(payload) > 0 {
+		response.PayloadSize = len(payload)
+	}
+	return payload
+}
+
+// loadPayload returns a copy of response configuration with binary payload loaded from basket, so payloads are
+// only read for responses that are served; responses saved before payloads were stored apart keep them inline
+func loadPayload(basket Basket, method string, path string, response *ResponseConfig) *ResponseConfig {
+	if response == nil || response.PayloadSize == 0 || len(response.Payload) > 0 {
+		return response
+	}
+
+	loaded := *response
+	loaded.Payload = basket.GetResponsePayload(method, path)
+	return &loaded
+}
+
+// responsePayload holds binary payload uploaded for basket response
+type responsePayload struct {
+	data        []byte
+	contentType string
+	name        string
+}
+
+// readResponsePayload reads binary payload of basket response from HTTP request, the payload may be sent either as
+// raw request body or as a file of "multipart/form-data" request
+func readResponsePayload(r *http.Request) (*responsePayload, error) {
+	defer r.Body.Close()
+
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		return readMultipartPayload(multipart.NewReader(r.Body, params["boundary"]))
+	}
+
+	data, err := readLimited(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	return &responsePayload{data: data, contentType: r.Header.Get("Content-Type"), name: r.URL.Query().Get("name")}, nil
+}
+
+// readMultipartPayload reads the first file part of multipart request
+func readMultipartPayload(reader *multipart.Reader) (*responsePayload, error) {
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			return nil, fmt.Errorf("form field %q with file is missing", payloadFormField)
+		} else if err != nil {
+			return nil, err
+		}
+
+		if part.FormName() == payloadFormField {
+			data, err := readLimited(part)
+			if err != nil {
+				return nil, err
+			}
+			return &responsePayload{data: data, contentType: part.Header.Get("Content-Type"), name: part.FileName()}, nil
+		}
+	}
+}
+
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, maxResponsePayloadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxResponsePayloadSize {
+		return nil, errPayloadTooLarge
+	}
+
+	return data, nil
+}
+
+// apply attaches the payload to response configuration
+func (payload *responsePayload) apply(response *ResponseConfig) {
+	name := filepath.Base(payload.name)
+	if name == "." || name == "/" || len(name) > maxPayloadNameLength {
+		name = ""
+	}
+
+	response.Payload = payload.data
+	response.PayloadType = payload.contentType
+	response.PayloadName = name
+	response.PayloadSize = len(payload.data)
+}
+
+// clearPayload detaches binary payload from response configuration
+func clearPayload(response *ResponseConfig) {
+	response.Payload = nil
+	response.PayloadType = ""
+	response.PayloadName = ""
+	response.PayloadSize = 0
+}
+
+// payloadContentType detects content type of payload using configured type, file name or content sniffing
+func payloadContentType(response *ResponseConfig) string {
+	if len(response.PayloadType) > 0 {
+		return response.PayloadType
+	}
+	if ctype := mime.TypeByExtension(filepath.Ext(response.PayloadName)); len(ctype) > 0 {
+		return ctype
+	}
+
+	return http.DetectContentType(response.Payload)
+}
+
+// writePayload writes binary payload of basket response, requests for partial content are supported for
+// successful (200 - OK) responses
+func writePayload(w http.ResponseWriter, r *http.Request, status int, response *ResponseConfig) {
+	if len(w.Header().Get("Content-Type")) == 0 {
+		w.Header().Set("Content-Type", payloadContentType(response))
+	}
+
+	if status == http.StatusOK {
+		http.ServeContent(w, r, response.PayloadName, time.Time{}, bytes.NewReader(response.Payload))
+	} else {
+		w.Header().Set("Content-Length", fmt.Sprint(len(response.Payload)))
+		w.WriteHeader(status)
+		if r.Method != http.MethodHead {
+			w.Write(response.Payload)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateBasketResponsePayload_Raw(t *testing.T) {
+	basket := "payload01"
+	method := "GET"
+	payload := []byte{0x89, 'P', 'N', 'G', 0x0d, 0x0a, 0x1a, 0x0a, 0x00, 0x01, 0x02}
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"/payload?name=logo.png",
+		bytes.NewReader(payload))
+	if assert.NoError(t, err) {
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+			httprouter.Param{Key: "method", Value: method})
+		w := httptest.NewRecorder()
+		UpdateBasketResponsePayload(w, r, ps)
+
+		// validate response: 204 - No Content
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+		// validate database update
+		response := basketsDb.Get(basket).GetResponse(method)
+		if assert.NotNil(t, response, "response is expected") {
+			assert.Equal(t, 200, response.Status, "wrong response status")
+			assert.Equal(t, payload, basketsDb.Get(basket).GetResponsePayload(method, ""), "wrong response payload")
+			assert.Equal(t, "logo.png", response.PayloadName, "wrong payload name")
+			assert.Equal(t, len(payload), response.PayloadSize, "wrong payload size")
+		}
+
+		// payload is not exposed via response configuration
+		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method, nil)
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			GetBasketResponse(w, r, ps)
+
+			assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+			assert.NotContains(t, w.Body.String(), "\"payload\"", "payload data is not expected")
+			assert.Contains(t, w.Body.String(), "\"payload_name\":\"logo.png\"", "payload name is expected")
+		}
+
+		// payload is kept on update of response configuration
+		r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method,
+			strings.NewReader("{\"status\":200,\"headers\":{\"Cache-Control\":[\"no-cache\"]}}"))
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			UpdateBasketResponse(w, r, ps)
+
+			assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+			response = basketsDb.Get(basket).GetResponse(method)
+			assert.Equal(t, payload, basketsDb.Get(basket).GetResponsePayload(method, ""), "payload is expected to be kept")
+			assert.Equal(t, "no-cache", response.Headers.Get("Cache-Control"), "wrong response header")
+		}
+
+		// get payload
+		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"/payload", nil)
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			GetBasketResponsePayload(w, r, ps)
+
+			assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+			assert.Equal(t, "image/png", w.Header().Get("Content-Type"), "wrong Content-Type header")
+			assert.Equal(t, payload, w.Body.Bytes(), "wrong payload")
+		}
+
+		// delete payload
+		r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"/payload", nil)
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			DeleteBasketResponsePayload(w, r, ps)
+
+			assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+			response = basketsDb.Get(basket).GetResponse(method)
+			assert.Empty(t, basketsDb.Get(basket).GetResponsePayload(method, ""), "payload is not expected")
+			assert.Empty(t, response.PayloadName, "payload name is not expected")
+			assert.Equal(t, "no-cache", response.Headers.Get("Cache-Control"), "response is expected to be kept")
+		}
+	}
+}
+
+func TestUpdateBasketResponsePayload_Multipart(t *testing.T) {
+	basket := "payload02"
+	method := "GET"
+	path := "/files/report.pdf"
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	writer.WriteField("comment", "monthly report")
+	part, _ := writer.CreateFormFile("file", "report.pdf")
+	part.Write([]byte("%PDF-1.4 fake report"))
+	writer.Close()
+
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"/payload?path="+url.QueryEscape(path), body)
+	if assert.NoError(t, err) {
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+			httprouter.Param{Key: "method", Value: method})
+		w := httptest.NewRecorder()
+		UpdateBasketResponsePayload(w, r, ps)
+
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+		response := basketsDb.Get(basket).GetPathResponses(method)[path]
+		if assert.NotNil(t, response, "path response is expected") {
+			assert.Equal(t, "%PDF-1.4 fake report", string(basketsDb.Get(basket).GetResponsePayload(method, path)),
+				"wrong response payload")
+			assert.Equal(t, "report.pdf", response.PayloadName, "wrong payload name")
+			assert.Equal(t, "application/octet-stream", response.PayloadType, "wrong payload type")
+		}
+
+		// list of path responses has no payload data
+		r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"/paths", nil)
+		if assert.NoError(t, err) {
+			w = httptest.NewRecorder()
+			GetBasketPathResponses(w, r, ps)
+
+			responses := make(map[string]*ResponseConfig)
+			if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &responses)) && assert.NotNil(t, responses[path]) {
+				assert.Empty(t, responses[path].Payload, "payload data is not expected")
+				assert.Equal(t, 20, responses[path].PayloadSize, "wrong payload size")
+			}
+		}
+	}
+}
+
+func TestUpdateBasketResponsePayload_Invalid(t *testing.T) {
+	basket := "payload03"
+	method := "POST"
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+		httprouter.Param{Key: "method", Value: method})
+
+	// too large
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"/payload",
+		bytes.NewReader(make([]byte, maxResponsePayloadSize+1)))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		UpdateBasketResponsePayload(w, r, ps)
+
+		assert.Equal(t, 413, w.Code, "wrong HTTP result code")
+		assert.Nil(t, basketsDb.Get(basket).GetResponse(method), "response is not expected")
+	}
+
+	// multipart without file
+	body := new(bytes.Buffer)
+	writer := multipart.NewWriter(body)
+	writer.WriteField("comment", "no file")
+	writer.Close()
+
+	r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/responses/"+method+"/payload", body)
+	if assert.NoError(t, err) {
+		r.Header.Set("Content-Type", writer.FormDataContentType())
+		w := httptest.NewRecorder()
+		UpdateBasketResponsePayload(w, r, ps)
+
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "form field \"file\" with file is missing")
+	}
+}
+
+func TestAcceptBasketRequests_Payload(t *testing.T) {
+	basket := "payload04"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("GET", ResponseConfig{Status: 200,
+		Payload: []byte("0123456789"), PayloadName: "digits.txt", PayloadSize: 10})
+
+	// full content
+	r, err := http.NewRequest("GET", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"), "wrong Content-Type header")
+		assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"), "wrong Accept-Ranges header")
+		assert.Equal(t, "0123456789", w.Body.String(), "wrong HTTP response body")
+	}
+
+	// partial content
+	r, err = http.NewRequest("GET", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Set("Range", "bytes=2-5")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 206, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "bytes 2-5/10", w.Header().Get("Content-Range"), "wrong Content-Range header")
+		assert.Equal(t, "2345", w.Body.String(), "wrong HTTP response body")
+	}
+}
+
+func TestAcceptBasketRequests_PayloadWithStatus(t *testing.T) {
+	basket := "payload05"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("GET", ResponseConfig{Status: 404,
+		Headers: http.Header{"Content-Type": []string{"application/json"}}, Payload: []byte("{\"error\":\"not found\"}")})
+
+	r, err := http.NewRequest("GET", "http://localhost:55555/r/"+basket, strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Set("Range", "bytes=0-1")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 404, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "application/json", w.Header().Get("Content-Type"), "wrong Content-Type header")
+		assert.Equal(t, "{\"error\":\"not found\"}", w.Body.String(), "wrong HTTP response body")
+	}
+}
+
+type limitedBasket struct {
+	Basket
+}
+
+func (basket limitedBasket) maxPayloadSize() int {
+	return 16
+}
+
+func TestLoadPayload(t *testing.T) {
+	basket := "payload06"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	b := basketsDb.Get(basket)
+	b.SetPathResponse("GET", "/files/:id", ResponseConfig{Status: 200, Payload: []byte("stored apart")})
+
+	stored := b.GetPathResponses("GET")["/files/:id"]
+	if assert.NotNil(t, stored, "path response is expected") {
+		loaded := loadPayload(b, "GET", "/files/:id", stored)
+		assert.Equal(t, "stored apart", string(loaded.Payload), "payload is expected to be loaded")
+		assert.Empty(t, stored.Payload, "stored configuration should not be changed")
+	}
+
+	// responses saved before payloads were stored apart keep them inline
+	legacy := &ResponseConfig{Status: 200, Payload: []byte("inline"), PayloadSize: 6}
+	assert.Equal(t, legacy, loadPayload(b, "GET", "/legacy", legacy), "inline payload is expected to be kept")
+	assert.Nil(t, loadPayload(b, "GET", "", nil))
+
+	assert.Equal(t, maxResponsePayloadSize, maxBasketPayloadSize(b), "default payload limit is expected")
+	assert.Equal(t, 16, maxBasketPayloadSize(limitedBasket{b}), "payload limit of storage is expected")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Recording modes of basket: in record mode responses of proxy target are kept as basket responses, in playback
+// mode requests are not forwarded and the recorded responses are served instead
+const (
+	RecordingRecord   = "record"
+	RecordingPlayback = "playback"
+)
+
+const (
+	maxRecordedResponses = 200
+	maxRecordedSize      = 20 * 1024 * 1024 // 20 MB
+	maxRecordedBodySize  = 16 * 1024        // 16 KB
+)
+
+// recordedMethods lists HTTP methods which responses are recorded, so the total size of recorded responses is known
+var recordedMethods = openAPIMethods
+
+// recordingSkippedHeaders lists headers of target response that are not kept in recorded response
+var recordingSkippedHeaders = []string{"Connection", "Content-Length", "Date", "Keep-Alive", "Transfer-Encoding"}
+
+// validateRecordingMode validates recording mode of basket
+func validateRecordingMode(config *BasketConfig) error {
+	switch config.Recording {
+	case "", RecordingPlayback:
+		return nil
+	case RecordingRecord:
+		if len(getForwardTargets(*config)) == 0 {
+			return fmt.Errorf("record mode requires forward URL or forward targets")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown recording mode: %q, expected %s or %s", config.Recording, RecordingRecord,
+			RecordingPlayback)
+	}
+}
+
+// forwardAndRecordResponse forwards request to the target and passes response back to the client, the response is
+// kept as basket response for HTTP method and path of request, so it can be served in playback mode; returns
+// captured response of the target
+func forwardAndRecordResponse(w http.ResponseWriter, r *http.Request, basket Basket, request *RequestData,
+	target ForwardTarget, config BasketConfig, name string) *ResponseData {
+	response, err := request.ForwardTo(r.Context(), getHTTPClient(config.HTTPClient, target.InsecureTLS), target,
+		name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil
+	}
+
+	// responses of failed forwards are produced by service itself and never recorded
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxResponsePayloadSize+1))
+	if err == nil && response.Request != nil {
+		if len(body) > maxResponsePayloadSize {
+			log.Printf("[warn] response of basket: %s is not recorded - body is larger than %d bytes", name,
+				maxResponsePayloadSize)
+		} else {
+			recordResponse(basket, request.Method, basketSubPath(request.Path, name), request.Query, response, body,
+				config.CORS != nil, name)
+		}
+	}
+
+	// the rest of response body is passed to the client as is
+	response.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), response.Body), response.Body}
+
+	// CORS headers are controlled by basket if configured
+	return writeForwardResponse(w, response, config.CORS != nil, false, name)
+}
+
+// recordResponse keeps response of target as path specific basket response, requests with query string are recorded
+// under the path pattern with query; short textual bodies are stored as is while large, binary or encoded bodies
+// are stored as response payload
+func recordResponse(basket Basket, method string, path string, query string, response *http.Response, body []byte,
+	skipCORS bool, name string) {
+	if !isRecordablePath(path) {
+		log.Printf("[warn] response of basket: %s is not recorded - path may not be used as pattern: %s", name,
+			sanitizeForLog(path))
+		return
+	}
+	if !isRecordedMethod(method) {
+		log.Printf("[warn] response of basket: %s is not recorded - HTTP method is not recorded: %s", name,
+			sanitizeForLog(method))
+		return
+	}
+	if pattern := queryPattern(path, query); len(pattern) > 0 {
+		if len(pattern) > maxPathPatternLength {
+			log.Printf("[warn] response of basket: %s is not recorded - path with query is longer than %d characters",
+				name, maxPathPatternLength)
+			return
+		}
+		path = pattern
+	}
+	if limit := maxBasketPayloadSize(basket); len(body) > limit {
+		log.Printf("[warn] response of basket: %s is not recorded - body is larger than %d bytes", name, limit)
+		return
+	}
+
+	responses := basket.GetPathResponses(method)
+	existing, exists := responses[path]
+	if !exists && len(responses) >= maxRecordedResponses {
+		log.Printf("[warn] response of basket: %s is not recorded - basket may not have more than %d responses for %s",
+			name, maxRecordedResponses, method)
+		return
+	}
+	if size := recordedSize(basket) - responseSize(existing) + len(body); size > maxRecordedSize {
+		log.Printf("[warn] response of basket: %s is not recorded - total size of responses may not exceed %d bytes",
+			name, maxRecordedSize)
+		return
+	}
+
+	recorded := ResponseConfig{Status: response.StatusCode, Headers: http.Header{}}
+	for header, values := range response.Header {
+		if !(skipCORS && isCORSHeader(header)) && !isRecordingSkippedHeader(header) {
+			recorded.Headers[header] = values
+		}
+	}
+
+	if len(body) <= maxRecordedBodySize && utf8.Valid(body) && len(response.Header.Get("Content-Encoding")) == 0 {
+		recorded.Body = string(body)
+	} else {
+		recorded.Payload = body
+		recorded.PayloadType = response.Header.Get("Content-Type")
+		recorded.PayloadSize = len(body)
+	}
+
+	basket.SetPathResponse(method, path, recorded)
+}
+
+// isRecordablePath checks if path of request may be used as a path pattern of basket response as is, i.e. it has
+// no segments that would be treated as path parameters
+func isRecordablePath(path string) bool {
+	if validatePathPattern(path) != nil {
+		return false
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			return false
+		}
+	}
+	return true
+}
+
+func isRecordedMethod(method string) bool {
+	for _, recorded := range recordedMethods {
+		if method == recorded {
+			return true
+		}
+	}
+	return false
+}
+
+// recordedSize returns total size of bodies and payloads of path specific responses of basket
+func recordedSize(basket Basket) int {
+	size := 0
+	for _, method := range recordedMethods {
+		for _, response := range basket.GetPathResponses(method) {
+			size += responseSize(response)
+		}
+	}
+	return size
+}
+
+func responseSize(response *ResponseConfig) int {
+	if response == nil {
+		return 0
+	}
+	return len(response.Body) + response.PayloadSize
+}
+
+func isRecordingSkippedHeader(header string) bool {
+	for _, skipped := range recordingSkippedHeaders {
+		if strings.EqualFold(header, skipped) {
+			return true
+		}
+	}
+	return false
+}
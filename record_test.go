@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRecordingMode(t *testing.T) {
+	assert.NoError(t, validateRecordingMode(&BasketConfig{}))
+	assert.NoError(t, validateRecordingMode(&BasketConfig{Recording: RecordingPlayback}))
+	assert.NoError(t, validateRecordingMode(&BasketConfig{Recording: RecordingRecord, ForwardURL: "http://a"}))
+
+	assert.Error(t, validateRecordingMode(&BasketConfig{Recording: RecordingRecord}), "forward URL is required")
+	assert.Error(t, validateRecordingMode(&BasketConfig{Recording: "replay"}))
+	assert.Error(t, validateBasketConfig(&BasketConfig{Capacity: 20, Recording: "replay"}))
+}
+
+func TestIsRecordablePath(t *testing.T) {
+	assert.True(t, isRecordablePath("/"))
+	assert.True(t, isRecordablePath("/v1/users/42"))
+	assert.False(t, isRecordablePath("/v1/users/:id"))
+	assert.False(t, isRecordablePath("/static/*file"))
+	assert.False(t, isRecordablePath("/"+strings.Repeat("a", maxPathPatternLength)))
+}
+
+func TestAcceptBasketRequests_RecordAndPlayback(t *testing.T) {
+	basket := "record01"
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Rate-Limit", "100")
+		if r.Method == "GET" {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0xff, 0x00})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"method":"` + r.Method + `"}`))
+	}))
+	defer ts.Close()
+
+	config := BasketConfig{Capacity: 20, ForwardURL: ts.URL, Recording: RecordingRecord}
+	basketsDb.Create(basket, config)
+
+	// record mode: responses are proxied and recorded
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket+"/users", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+	assert.Equal(t, 201, w.Code, "response should be proxied in record mode")
+	assert.Equal(t, `{"method":"POST"}`, w.Body.String(), "wrong proxied response")
+
+	r = httptest.NewRequest("GET", "http://localhost:55555/r/"+basket+"/image", strings.NewReader(""))
+	AcceptBasketRequests(httptest.NewRecorder(), r)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "requests should be forwarded in record mode")
+
+	recorded := basketsDb.Get(basket).GetPathResponses("POST")["/users"]
+	if assert.NotNil(t, recorded, "response should be recorded") {
+		assert.Equal(t, 201, recorded.Status, "wrong recorded status")
+		assert.Equal(t, `{"method":"POST"}`, recorded.Body, "wrong recorded body")
+		assert.Equal(t, "100", recorded.Headers.Get("X-Rate-Limit"), "wrong recorded header")
+		assert.Empty(t, recorded.Headers.Get("Content-Length"), "content length should not be recorded")
+	}
+	image := basketsDb.Get(basket).GetPathResponses("GET")["/image"]
+	if assert.NotNil(t, image, "binary response should be recorded") {
+		assert.Equal(t, []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}, basketsDb.Get(basket).GetResponsePayload("GET", "/image"),
+			"wrong recorded payload")
+		assert.Equal(t, "image/png", image.PayloadType, "wrong recorded payload type")
+	}
+
+	// playback mode: recorded responses are served without forwarding
+	config.Recording = RecordingPlayback
+	basketsDb.Get(basket).Update(config)
+
+	r = httptest.NewRequest("POST", "http://localhost:55555/r/"+basket+"/users", strings.NewReader("{}"))
+	w = httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+	assert.Equal(t, 201, w.Code, "wrong played back status")
+	assert.Equal(t, `{"method":"POST"}`, w.Body.String(), "wrong played back body")
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"), "wrong played back header")
+
+	r = httptest.NewRequest("GET", "http://localhost:55555/r/"+basket+"/image", strings.NewReader(""))
+	w = httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+	assert.Equal(t, 200, w.Code, "wrong played back status")
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G', 0xff, 0x00}, w.Body.Bytes(), "wrong played back payload")
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "requests should not be forwarded in playback mode")
+	assert.Equal(t, 4, basketsDb.Get(basket).GetRequests(10, 0).Count, "all requests should be collected")
+}
+
+func TestAcceptBasketRequests_RecordQuery(t *testing.T) {
+	basket := "record02"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("page " + r.URL.Query().Get("page") + " of " + r.URL.Query().Get("size")))
+	}))
+	defer ts.Close()
+
+	config := BasketConfig{Capacity: 20, ForwardURL: ts.URL, Recording: RecordingRecord}
+	basketsDb.Create(basket, config)
+
+	for _, query := range []string{"", "?page=1&size=10", "?size=10&page=2"} {
+		r := httptest.NewRequest("GET", "http://localhost:55555/r/"+basket+"/users"+query, strings.NewReader(""))
+		AcceptBasketRequests(httptest.NewRecorder(), r)
+	}
+
+	responses := basketsDb.Get(basket).GetPathResponses("GET")
+	assert.Len(t, responses, 3, "responses should be recorded per query string")
+	if assert.NotNil(t, responses["/users?page=2&size=10"], "query parameters should be sorted") {
+		assert.Equal(t, "page 2 of 10", responses["/users?page=2&size=10"].Body, "wrong recorded body")
+	}
+
+	// playback mode: response recorded for the same query is served regardless of parameters order
+	config.Recording = RecordingPlayback
+	basketsDb.Get(basket).Update(config)
+
+	expected := map[string]string{"?page=1&size=10": "page 1 of 10", "?size=10&page=1": "page 1 of 10",
+		"?page=2&size=10": "page 2 of 10", "?page=3": "page  of ", "": "page  of "}
+	for query, body := range expected {
+		r := httptest.NewRequest("GET", "http://localhost:55555/r/"+basket+"/users"+query, strings.NewReader(""))
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+		assert.Equal(t, body, w.Body.String(), "wrong played back body for query: %s", query)
+	}
+}
+
+func TestRecordResponse_Limits(t *testing.T) {
+	basket := "record03"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	b := basketsDb.Get(basket)
+	response := &http.Response{StatusCode: 200, Header: http.Header{"Content-Type": []string{"text/plain"}}}
+
+	// large textual body is stored as payload
+	large := []byte(strings.Repeat("a", maxRecordedBodySize+1))
+	recordResponse(b, "GET", "/large", "", response, large, false, basket)
+	if recorded := b.GetPathResponses("GET")["/large"]; assert.NotNil(t, recorded, "response should be recorded") {
+		assert.Empty(t, recorded.Body, "large body is not expected in response configuration")
+		assert.Equal(t, "text/plain", recorded.PayloadType, "wrong recorded payload type")
+		assert.Equal(t, large, b.GetResponsePayload("GET", "/large"), "wrong recorded payload")
+	}
+
+	// responses of unknown methods are not recorded
+	recordResponse(b, "PURGE", "/cache", "", response, []byte("ok"), false, basket)
+	assert.Empty(t, b.GetPathResponses("PURGE"), "response of unknown method is not expected")
+
+	// total size of recorded responses is limited
+	b.SetPathResponse("POST", "/upload", ResponseConfig{Status: 200, PayloadSize: maxRecordedSize - len(large)})
+	recordResponse(b, "GET", "/small", "", response, []byte("ok"), false, basket)
+	assert.Nil(t, b.GetPathResponses("GET")["/small"], "response over total size limit is not expected")
+
+	// existing response may be replaced within the limit
+	recordResponse(b, "GET", "/large", "", response, []byte("ok"), false, basket)
+	assert.Equal(t, "ok", b.GetPathResponses("GET")["/large"].Body, "response should be replaced")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const (
+	maxReplayRequestSize = 1024 * 1024
+	replayPageSize       = 100
+)
+
+// ReplayRequest describes overrides of collected request that is replayed to a target.
+type ReplayRequest struct {
+	URL         string      `json:"url,omitempty"`
+	InsecureTLS bool        `json:"insecure_tls,omitempty"`
+	ExpandPath  bool        `json:"expand_path,omitempty"`
+	Headers     http.Header `json:"headers,omitempty"`
+	Body        *string     `json:"body,omitempty"`
+}
+
+// findBasketRequest looks up collected request by its ID; requests collected before IDs were introduced are
+// addressed by their position, e.g. "1" is the most recent request
+func findBasketRequest(basket Basket, id string) *RequestData {
+	for skip := 0; ; skip += replayPageSize {
+		page := basket.GetRequests(replayPageSize, skip)
+		for _, request := range page.Requests {
+			if request.ID == id {
+				return request
+			}
+		}
+		if !page.HasMore {
+			break
+		}
+	}
+
+	if pos, err := strconv.Atoi(id); err == nil && pos > 0 {
+		if page := basket.GetRequests(1, pos-1); len(page.Requests) > 0 {
+			return page.Requests[0]
+		}
+	}
+
+	return nil
+}
+
+// getReplayTarget resolves target of replayed request: override URL if specified, otherwise the forward target of
+// basket that is a source of proxied response
+func getReplayTarget(replay *ReplayRequest, config BasketConfig) (ForwardTarget, error) {
+	if len(replay.URL) > 0 {
+		u, err := url.ParseRequestURI(replay.URL)
+		if err != nil {
+			return ForwardTarget{}, fmt.Errorf("invalid replay URL: %s - %s", replay.URL, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return ForwardTarget{}, fmt.Errorf("invalid replay URL: %s - only http and https are supported", replay.URL)
+		}
+		return ForwardTarget{URL: replay.URL, InsecureTLS: replay.InsecureTLS, ExpandPath: replay.ExpandPath,
+			SetHeaders: replay.Headers}, nil
+	}
+
+	targets := getForwardTargets(config)
+	if len(targets) == 0 {
+		return ForwardTarget{}, fmt.Errorf("replay URL is not specified and basket has no forward targets")
+	}
+
+	target := targets[getProxyTarget(targets)]
+	if len(replay.Headers) > 0 {
+		headers := make(http.Header)
+		for k, v := range target.SetHeaders {
+			headers[k] = v
+		}
+		for k, v := range replay.Headers {
+			headers[k] = v
+		}
+		target.SetHeaders = headers
+	}
+	return target, nil
+}
+
+// toReplayedRequest applies body override to a copy of collected request
+func toReplayedRequest(request *RequestData, replay *ReplayRequest) *RequestData {
+	replayed := *request
+	if replay.Body != nil {
+		replayed.Body = *replay.Body
+		replayed.ContentLength = int64(len(replayed.Body))
+	}
+	return &replayed
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplayBasketRequest(t *testing.T) {
+	basket := "replay01"
+
+	var replayed *RequestData
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed = ToRequestData(r)
+		w.Header().Set("X-Upstream", "dev")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("replayed " + r.URL.Path))
+	}))
+	defer ts.Close()
+
+	auth, _ := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	b := basketsDb.Get(basket)
+
+	r, _ := http.NewRequest("POST", "http://localhost:55555/"+basket+"/orders?id=3", strings.NewReader("{\"item\":\"book\"}"))
+	r.Header.Set("X-Env", "prod")
+	request := b.Add(r)
+	assert.NotEmpty(t, request.ID, "request ID is expected")
+	b.Add(httptest.NewRequest("GET", "http://localhost:55555/"+basket+"/latest", strings.NewReader("")))
+
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket},
+		httprouter.Param{Key: "id", Value: request.ID})
+
+	// no target
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/"+request.ID+"/replay",
+		strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		ReplayBasketRequest(w, r, ps)
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+	}
+
+	// replay with overrides
+	r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/"+request.ID+"/replay",
+		strings.NewReader("{\"url\":\""+ts.URL+"/dev\",\"headers\":{\"X-Env\":[\"dev\"]},\"body\":\"{}\"}"))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		ReplayBasketRequest(w, r, ps)
+
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "dev", w.Header().Get("X-Upstream"), "wrong upstream header")
+		assert.Equal(t, "replayed /dev", w.Body.String(), "wrong upstream body")
+		if assert.NotNil(t, replayed, "request is not replayed") {
+			assert.Equal(t, "POST", replayed.Method, "wrong method")
+			assert.Equal(t, "id=3", replayed.Query, "wrong query")
+			assert.Equal(t, "dev", replayed.Header.Get("X-Env"), "header is not overridden")
+			assert.Equal(t, "{}", replayed.Body, "body is not overridden")
+		}
+	}
+
+	// replay to basket forward URL, request is addressed by its position
+	b.Update(BasketConfig{Capacity: 20, ForwardURL: ts.URL + "/forward"})
+	ps[1].Value = "2"
+	r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/2/replay", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		ReplayBasketRequest(w, r, ps)
+
+		assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "replayed /forward", w.Body.String(), "wrong upstream body")
+		assert.Equal(t, "prod", replayed.Header.Get("X-Env"), "wrong header")
+		assert.Equal(t, "{\"item\":\"book\"}", replayed.Body, "wrong body")
+	}
+
+	// unknown request
+	ps[1].Value = "abc"
+	r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/requests/abc/replay", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		ReplayBasketRequest(w, r, ps)
+		assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+	}
+}
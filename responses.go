@@ -0,0 +1,379 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const (
+	maxPathPatternLength = 250
+	maxPathResponses     = 200
+	maxCachedTemplates   = 1000
+)
+
+var validPathParam = regexp.MustCompile(`^[\w]+$`)
+
+// validatePathPattern validates path pattern of basket response, e.g. "/v1/users/:id" or "/static/*file"
+func validatePathPattern(pattern string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("path pattern should start with '/': %s", pattern)
+	}
+	if len(pattern) > maxPathPatternLength {
+		return fmt.Errorf("path pattern may not be longer than %d characters", maxPathPatternLength)
+	}
+
+	segments := strings.Split(pattern[1:], "/")
+	for i, segment := range segments {
+		if len(segment) > 0 && (segment[0] == ':' || segment[0] == '*') {
+			if !validPathParam.MatchString(segment[1:]) {
+				return fmt.Errorf("invalid name of path parameter: %s", segment)
+			}
+			if segment[0] == '*' && i < len(segments)-1 {
+				return fmt.Errorf("catch-all parameter is only allowed at the end of path pattern: %s", segment)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePathResponsesCount checks that basket may have one more path specific response for HTTP method, existing
+// responses can always be updated
+func validatePathResponsesCount(basket Basket, method string, path string) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	responses := basket.GetPathResponses(method)
+	if _, exists := responses[path]; !exists && len(responses) >= maxPathResponses {
+		return fmt.Errorf("basket may not have more than %d path responses for HTTP %s method", maxPathResponses, method)
+	}
+	return nil
+}
+
+// matchPathPattern matches path against path pattern, returns captured path parameters in case of match
+func matchPathPattern(pattern string, path string) (map[string]string, bool) {
+	patternSegments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	params := make(map[string]string)
+
+	for i, segment := range patternSegments {
+		if len(segment) > 0 && segment[0] == '*' {
+			params[segment[1:]] = strings.Join(pathSegments[i:], "/")
+			return params, true
+		}
+		if i >= len(pathSegments) {
+			return nil, false
+		}
+		if len(segment) > 0 && segment[0] == ':' {
+			if len(pathSegments[i]) == 0 {
+				return nil, false
+			}
+			params[segment[1:]] = pathSegments[i]
+		} else if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+
+	if len(patternSegments) != len(pathSegments) {
+		return nil, false
+	}
+
+	return params, true
+}
+
+// patternWeight returns weights of path pattern segments: static segment is heavier than parameter and catch-all
+func patternWeight(pattern string) []int {
+	segments := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	weight := make([]int, len(segments))
+	for i, segment := range segments {
+		switch {
+		case len(segment) > 0 && segment[0] == '*':
+			weight[i] = 0
+		case len(segment) > 0 && segment[0] == ':':
+			weight[i] = 1
+		default:
+			weight[i] = 2
+		}
+	}
+
+	return weight
+}
+
+// morePrecise checks if the first path pattern is more precise than the second one
+func morePrecise(pattern1 string, pattern2 string) bool {
+	w1 := patternWeight(pattern1)
+	w2 := patternWeight(pattern2)
+	for i := 0; i < len(w1) && i < len(w2); i++ {
+		if w1[i] != w2[i] {
+			return w1[i] > w2[i]
+		}
+	}
+	if len(w1) != len(w2) {
+		return len(w1) > len(w2)
+	}
+
+	// stable choice
+	return pattern1 < pattern2
+}
+
+// findPathResponse finds the most precise path response that matches the path, returns captured path parameters as well
+func findPathResponse(responses map[string]*ResponseConfig, path string) (*ResponseConfig, map[string]string) {
+	pattern, params := findPathResponsePattern(responses, path)
+	return responses[pattern], params
+}
+
+// findPathResponsePattern finds pattern of the most precise path response that matches the path, returns captured
+// path parameters as well
+func findPathResponsePattern(responses map[string]*ResponseConfig, path string) (string, map[string]string) {
+	found := false
+	var foundPattern string
+	var foundParams map[string]string
+
+	for pattern := range responses {
+		if params, ok := matchPathPattern(pattern, path); ok {
+			if !found || morePrecise(pattern, foundPattern) {
+				found = true
+				foundPattern = pattern
+				foundParams = params
+			}
+		}
+	}
+
+	return foundPattern, foundParams
+}
+
+// queryPattern returns path pattern of response specific to query string of request, e.g. "/users?page=2";
+// parameters are sorted, so their order in request does not matter. Empty pattern is returned if there is no query.
+func queryPattern(path string, query string) string {
+	if len(query) == 0 {
+		return ""
+	}
+	if values, err := url.ParseQuery(query); err == nil {
+		query = values.Encode()
+	}
+	return path + "?" + query
+}
+
+// getBasketResponse selects basket response for HTTP request: response specific to query string has preference over
+// path specific response, which has preference over default one
+func getBasketResponse(basket Basket, method string, path string, query string) (*ResponseConfig, map[string]string) {
+	if responses := basket.GetPathResponses(method); len(responses) > 0 {
+		if pattern := queryPattern(path, query); len(pattern) > 0 && responses[pattern] != nil {
+			return loadPayload(basket, method, pattern, responses[pattern]), map[string]string{}
+		}
+		if pattern, params := findPathResponsePattern(responses, path); responses[pattern] != nil {
+			return loadPayload(basket, method, pattern, responses[pattern]), params
+		}
+	}
+
+	if response := basket.GetResponse(method); response != nil {
+		return loadPayload(basket, method, "", response), nil
+	}
+
+	return &defaultResponse, nil
+}
+
+// templateFuncs returns functions that are available in response templates to access HTTP request details
+// and basket state
+func templateFuncs(r *http.Request, basket Basket, name string, params map[string]string) template.FuncMap {
+	return template.FuncMap{
+		"basket": func() string { return name },
+		"method": func() string { return r.Method },
+		"path":   func() string { return r.URL.Path },
+		"header": func(key string) string { return r.Header.Get(key) },
+		"query":  func(key string) string { return r.URL.Query().Get(key) },
+		"param":  func(key string) string { return params[key] },
+		"state": func(key string) string {
+			value, _ := basket.GetStateValue(key)
+			return value
+		},
+		"setState": func(key string, value string) (string, error) {
+			return "", basket.SetStateValue(key, value)
+		},
+		"deleteState": func(key string) string {
+			basket.DeleteStateValue(key)
+			return ""
+		},
+	}
+}
+
+// templatesCache keeps parsed templates of basket responses, templates are parsed when response is configured and
+// reused by incoming requests; functions bound to the request are set on a copy of parsed template
+type templatesCache struct {
+	sync.Mutex
+	text map[string]*template.Template
+	html map[string]*htmltemplate.Template
+}
+
+var parsedTemplates = &templatesCache{text: make(map[string]*template.Template),
+	html: make(map[string]*htmltemplate.Template)}
+
+// parseText returns parsed text template with the given name
+func (cache *templatesCache) parseText(name string, text string) (*template.Template, error) {
+	key := name + "\x00" + text
+	cache.Lock()
+	t, exists := cache.text[key]
+	cache.Unlock()
+	if exists {
+		return t, nil
+	}
+
+	t, err := template.New(name).Funcs(templateFuncs(nil, nil, "", nil)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+	if len(cache.text) >= maxCachedTemplates {
+		cache.text = make(map[string]*template.Template)
+	}
+	cache.text[key] = t
+
+	return t, nil
+}
+
+// parseHTML returns parsed HTML template with the given name
+func (cache *templatesCache) parseHTML(name string, text string) (*htmltemplate.Template, error) {
+	key := name + "\x00" + text
+	cache.Lock()
+	t, exists := cache.html[key]
+	cache.Unlock()
+	if exists {
+		return t, nil
+	}
+
+	t, err := htmltemplate.New(name).Funcs(htmltemplate.FuncMap(templateFuncs(nil, nil, "", nil))).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+	if len(cache.html) >= maxCachedTemplates {
+		cache.html = make(map[string]*htmltemplate.Template)
+	}
+	cache.html[key] = t
+
+	return t, nil
+}
+
+// validateResponseTemplates validates templates of response body, headers and status
+func validateResponseTemplates(config *ResponseConfig) error {
+	if len(config.Body) > 0 {
+		if _, err := parsedTemplates.parseHTML("body", config.Body); err != nil {
+			return fmt.Errorf("error in body %s", err)
+		}
+	}
+
+	for key, vals := range config.Headers {
+		for _, val := range vals {
+			if _, err := parsedTemplates.parseText(key, val); err != nil {
+				return fmt.Errorf("error in header %s", err)
+			}
+		}
+	}
+
+	if len(config.StatusTemplate) > 0 {
+		if _, err := parsedTemplates.parseText("status", config.StatusTemplate); err != nil {
+			return fmt.Errorf("error in status %s", err)
+		}
+	}
+
+	return nil
+}
+
+// renderedResponse holds response details after all templates are applied
+type renderedResponse struct {
+	status  int
+	headers http.Header
+	body    []byte
+}
+
+// renderResponse applies response templates (if enabled) to the incoming HTTP request
+func renderResponse(response *ResponseConfig, r *http.Request, basket Basket, name string, params map[string]string) (*renderedResponse, error) {
+	result := &renderedResponse{status: response.Status, headers: response.Headers, body: []byte(response.Body)}
+	if len(response.Payload) > 0 {
+		// binary payload replaces the body and is never treated as template
+		result.body = response.Payload
+	}
+	if !response.IsTemplate {
+		return result, nil
+	}
+
+	funcs := templateFuncs(r, basket, name, params)
+	data := r.URL.Query()
+
+	// body
+	if len(response.Body) > 0 && len(response.Payload) == 0 {
+		parsed, err := parsedTemplates.parseHTML("body", response.Body)
+		if err != nil {
+			return nil, err
+		}
+		t, err := parsed.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		buf := new(bytes.Buffer)
+		if err = t.Funcs(htmltemplate.FuncMap(funcs)).Execute(buf, data); err != nil {
+			return nil, err
+		}
+		result.body = buf.Bytes()
+	}
+
+	// headers
+	result.headers = make(http.Header, len(response.Headers))
+	for key, vals := range response.Headers {
+		for _, val := range vals {
+			rendered, err := renderText(key, val, funcs, data)
+			if err != nil {
+				return nil, err
+			}
+			result.headers[key] = append(result.headers[key], rendered)
+		}
+	}
+
+	// status
+	if len(response.StatusTemplate) > 0 {
+		rendered, err := renderText("status", response.StatusTemplate, funcs, data)
+		if err != nil {
+			return nil, err
+		}
+
+		status, err := strconv.Atoi(strings.TrimSpace(rendered))
+		if err != nil || status < 100 || status >= 600 {
+			return nil, fmt.Errorf("status template: invalid HTTP status of response: %q", rendered)
+		}
+		result.status = status
+	}
+
+	return result, nil
+}
+
+func renderText(name string, text string, funcs template.FuncMap, data interface{}) (string, error) {
+	parsed, err := parsedTemplates.parseText(name, text)
+	if err != nil {
+		return "", err
+	}
+	t, err := parsed.Clone()
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(strings.Builder)
+	if err = t.Funcs(funcs).Execute(buf, data); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
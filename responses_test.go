@@ -0,0 +1,255 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateResponseTemplates(t *testing.T) {
+	assert.NoError(t, validateResponseTemplates(&ResponseConfig{
+		Body:           "{{header \"X-Request-Id\"}}",
+		StatusTemplate: "{{if query \"id\"}}200{{else}}404{{end}}",
+		Headers:        http.Header{"Location": []string{"{{path}}/1"}}}))
+
+	err := validateResponseTemplates(&ResponseConfig{Headers: http.Header{"X-Id": []string{"{{id}}"}}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "error in header template: X-Id:1: function \"id\" not defined")
+	}
+
+	err = validateResponseTemplates(&ResponseConfig{StatusTemplate: "{{if}}"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "error in status template: status:1: missing value for if")
+	}
+}
+
+func TestRenderResponse(t *testing.T) {
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/demo/orders?id=12", strings.NewReader(""))
+	r.Header.Set("X-Request-Id", "abc-123")
+
+	rendered, err := renderResponse(&ResponseConfig{
+		Status:         200,
+		StatusTemplate: "{{if query \"id\"}}201{{else}}400{{end}}",
+		Headers: http.Header{
+			"X-Request-Id": []string{"{{header \"X-Request-Id\"}}"},
+			"Location":     []string{"{{path}}/{{index .id 0}}"}},
+		Body:       "{{method}} in {{basket}}",
+		IsTemplate: true}, r, nil, "demo", nil)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, 201, rendered.status, "wrong status")
+		assert.Equal(t, "abc-123", rendered.headers.Get("X-Request-Id"), "wrong X-Request-Id header")
+		assert.Equal(t, "/r/demo/orders/12", rendered.headers.Get("Location"), "wrong Location header")
+		assert.Equal(t, "POST in demo", string(rendered.body), "wrong body")
+	}
+}
+
+func TestRenderResponse_State(t *testing.T) {
+	basket := "render01"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	b := basketsDb.Get(basket)
+	b.SetStateValue("visits", "41")
+
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket+"/orders?id=12", strings.NewReader(""))
+	rendered, err := renderResponse(&ResponseConfig{
+		Status:     200,
+		Headers:    http.Header{"X-Visits": []string{"{{state \"visits\"}}"}},
+		Body:       "{{setState \"order\" (query \"id\")}}{{deleteState \"visits\"}}order {{state \"order\"}}",
+		IsTemplate: true}, r, b, basket, nil)
+
+	if assert.NoError(t, err) {
+		assert.Equal(t, "order 12", string(rendered.body), "wrong body")
+		assert.Equal(t, "", rendered.headers.Get("X-Visits"), "headers are rendered after body")
+		assert.Equal(t, map[string]string{"order": "12"}, b.GetState(), "wrong basket state")
+	}
+
+	_, err = renderResponse(&ResponseConfig{Status: 200, Body: "{{setState \"\" \"x\"}}", IsTemplate: true}, r, b, basket, nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "state key should have length")
+	}
+}
+
+func TestRenderResponse_NoTemplate(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://localhost:55555/r/demo", strings.NewReader(""))
+	response := &ResponseConfig{Status: 202, StatusTemplate: "500", Headers: http.Header{"X-Test": []string{"{{path}}"}}, Body: "{{path}}"}
+
+	rendered, err := renderResponse(response, r, nil, "demo", nil)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 202, rendered.status, "status template is not expected to apply")
+		assert.Equal(t, "{{path}}", rendered.headers.Get("X-Test"), "header template is not expected to apply")
+		assert.Equal(t, "{{path}}", string(rendered.body), "body template is not expected to apply")
+	}
+}
+
+func TestRenderResponse_InvalidStatus(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://localhost:55555/r/demo?code=abc", strings.NewReader(""))
+
+	_, err := renderResponse(&ResponseConfig{Status: 200, StatusTemplate: "{{query \"code\"}}", IsTemplate: true}, r, nil, "demo", nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid HTTP status of response: \"abc\"")
+	}
+}
+
+func TestAcceptBasketRequests_TemplateHeadersAndStatus(t *testing.T) {
+	basket := "template01"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	basketsDb.Get(basket).SetResponse("PUT", ResponseConfig{
+		Status:         200,
+		StatusTemplate: "{{if header \"If-Match\"}}204{{else}}428{{end}}",
+		Headers:        http.Header{"X-Request-Id": []string{"{{header \"X-Request-Id\"}}"}},
+		IsTemplate:     true})
+
+	r, err := http.NewRequest("PUT", "http://localhost:55555/r/"+basket+"/items/1", strings.NewReader("{}"))
+	if assert.NoError(t, err) {
+		r.Header.Set("X-Request-Id", "req-42")
+		r.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 204, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "req-42", w.Header().Get("X-Request-Id"), "wrong HTTP header")
+	}
+
+	r, err = http.NewRequest("PUT", "http://localhost:55555/r/"+basket+"/items/1", strings.NewReader("{}"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 428, w.Code, "wrong HTTP response code")
+	}
+}
+
+func TestValidatePathPattern(t *testing.T) {
+	assert.NoError(t, validatePathPattern("/"))
+	assert.NoError(t, validatePathPattern("/v1/orders"))
+	assert.NoError(t, validatePathPattern("/v1/users/:id/orders/:order_id"))
+	assert.NoError(t, validatePathPattern("/static/*file"))
+
+	assert.Error(t, validatePathPattern("v1/orders"))
+	assert.Error(t, validatePathPattern("/v1/users/:"))
+	assert.Error(t, validatePathPattern("/v1/users/:user-id"))
+	assert.Error(t, validatePathPattern("/static/*file/info"))
+	assert.Error(t, validatePathPattern("/"+strings.Repeat("a", maxPathPatternLength)))
+}
+
+func TestMatchPathPattern(t *testing.T) {
+	params, ok := matchPathPattern("/v1/users/:id", "/v1/users/42")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"id": "42"}, params)
+
+	params, ok = matchPathPattern("/static/*file", "/static/img/logo.png")
+	assert.True(t, ok)
+	assert.Equal(t, map[string]string{"file": "img/logo.png"}, params)
+
+	_, ok = matchPathPattern("/v1/orders", "/v1/orders")
+	assert.True(t, ok)
+	_, ok = matchPathPattern("/", "/")
+	assert.True(t, ok)
+
+	_, ok = matchPathPattern("/v1/users/:id", "/v1/users")
+	assert.False(t, ok)
+	_, ok = matchPathPattern("/v1/users/:id", "/v1/users/")
+	assert.False(t, ok)
+	_, ok = matchPathPattern("/v1/users/:id", "/v1/users/42/orders")
+	assert.False(t, ok)
+	_, ok = matchPathPattern("/v1/orders", "/v2/orders")
+	assert.False(t, ok)
+}
+
+func TestFindPathResponse(t *testing.T) {
+	responses := map[string]*ResponseConfig{
+		"/v1/*any":       {Body: "any"},
+		"/v1/users/:id":  {Body: "user"},
+		"/v1/users/me":   {Body: "me"},
+		"/v1/:kind/list": {Body: "list"}}
+
+	response, params := findPathResponse(responses, "/v1/users/42")
+	if assert.NotNil(t, response) {
+		assert.Equal(t, "user", response.Body)
+		assert.Equal(t, "42", params["id"])
+	}
+
+	response, _ = findPathResponse(responses, "/v1/users/me")
+	if assert.NotNil(t, response) {
+		assert.Equal(t, "me", response.Body)
+	}
+
+	response, params = findPathResponse(responses, "/v1/orders/list")
+	if assert.NotNil(t, response) {
+		assert.Equal(t, "list", response.Body)
+		assert.Equal(t, "orders", params["kind"])
+	}
+
+	response, _ = findPathResponse(responses, "/v1/orders/1/items")
+	if assert.NotNil(t, response) {
+		assert.Equal(t, "any", response.Body)
+	}
+
+	response, _ = findPathResponse(responses, "/v2/orders")
+	assert.Nil(t, response)
+}
+
+func TestQueryPattern(t *testing.T) {
+	assert.Empty(t, queryPattern("/users", ""))
+	assert.Equal(t, "/users?page=2&size=10", queryPattern("/users", "size=10&page=2"))
+	assert.Equal(t, "/users?q=a+b", queryPattern("/users", "q=a%20b"))
+	assert.Equal(t, "/users?%zz", queryPattern("/users", "%zz"), "invalid query is kept as is")
+}
+
+func TestAcceptBasketRequests_PathResponse(t *testing.T) {
+	basket := "paths01"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	b := basketsDb.Get(basket)
+	b.SetResponse("GET", ResponseConfig{Status: 200, Body: "default"})
+	b.SetPathResponse("GET", "/v1/users/:id", ResponseConfig{Status: 200, Body: "user {{param \"id\"}}", IsTemplate: true})
+	b.SetPathResponse("GET", "/v1/orders", ResponseConfig{Status: 200, Body: "orders"})
+
+	for path, expected := range map[string]string{
+		"/v1/users/42": "user 42",
+		"/v1/orders":   "orders",
+		"/v1/items":    "default",
+		"":             "default"} {
+		r, err := http.NewRequest("GET", "http://localhost:55555/r/"+basket+path, strings.NewReader(""))
+		if assert.NoError(t, err) {
+			w := httptest.NewRecorder()
+			AcceptBasketRequests(w, r)
+
+			assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+			assert.Equal(t, expected, w.Body.String(), "wrong HTTP response body for path: %s", path)
+		}
+	}
+}
+
+func TestRenderResponse_CachedTemplates(t *testing.T) {
+	response := &ResponseConfig{Status: 200, Body: "{{query \"name\"}} at {{path}}",
+		Headers: http.Header{"X-Name": []string{"{{header \"X-Name\"}}"}}, IsTemplate: true}
+	assert.NoError(t, validateResponseTemplates(response))
+
+	parsed, err := parsedTemplates.parseHTML("body", response.Body)
+	if assert.NoError(t, err) {
+		again, _ := parsedTemplates.parseHTML("body", response.Body)
+		assert.Same(t, parsed, again, "parsed template should be reused")
+	}
+
+	// templates are bound to each request, even if rendered concurrently
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			r := httptest.NewRequest("GET", "http://localhost:55555/r/demo/"+name+"?name="+name, nil)
+			r.Header.Set("X-Name", name)
+			rendered, err := renderResponse(response, r, nil, "demo", nil)
+			if assert.NoError(t, err) {
+				assert.Equal(t, name+" at /r/demo/"+name, string(rendered.body), "wrong rendered body")
+				assert.Equal(t, name, rendered.headers.Get("X-Name"), "wrong rendered header")
+			}
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Limits of forward retries
+const (
+	maxRetryAttempts   = 20
+	maxRetryBackoff    = 24 * 60 * 60 * 1000 // 1 day in ms.
+	maxForwardRetries  = 1000
+	maxRetryErrorSize  = 1024
+	defaultBackoff     = 1000 // ms.
+	defaultMaxBackoff  = 60 * 60 * 1000
+	retryNamesPageSize = 100
+)
+
+// Default HTTP statuses of forwarding response that trigger retry
+var defaultRetryStatuses = []int{429, 502, 503, 504}
+
+// RetryPolicy describes retries (with exponential backoff in milliseconds) of failed request forwarding.
+type RetryPolicy struct {
+	MaxAttempts    int   `json:"max_attempts"`
+	InitialBackoff int   `json:"initial_backoff,omitempty"`
+	MaxBackoff     int   `json:"max_backoff,omitempty"`
+	RetryOn        []int `json:"retry_on,omitempty"`
+}
+
+// ForwardRetry describes failed forwarding of collected request that is scheduled for retry or kept in dead-letter
+// list after all attempts are exhausted.
+type ForwardRetry struct {
+	ID          string        `json:"id"`
+	Target      ForwardTarget `json:"target"`
+	Request     *RequestData  `json:"request"`
+	Attempts    int           `json:"attempts"`
+	NextAttempt int64         `json:"next_attempt,omitempty"`
+	LastStatus  int           `json:"last_status,omitempty"`
+	LastError   string        `json:"last_error,omitempty"`
+	Dead        bool          `json:"dead"`
+}
+
+// validateRetryPolicy validates retry policy of basket
+func validateRetryPolicy(policy *RetryPolicy) error {
+	if policy.MaxAttempts < 1 || policy.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("max attempts of retry policy should be within [1, %d] range, but was %d",
+			maxRetryAttempts, policy.MaxAttempts)
+	}
+	if policy.InitialBackoff < 0 || policy.InitialBackoff > maxRetryBackoff {
+		return fmt.Errorf("initial backoff of retry policy should be within [0, %d] ms range, but was %d",
+			maxRetryBackoff, policy.InitialBackoff)
+	}
+	if policy.MaxBackoff < 0 || policy.MaxBackoff > maxRetryBackoff {
+		return fmt.Errorf("max backoff of retry policy should be within [0, %d] ms range, but was %d",
+			maxRetryBackoff, policy.MaxBackoff)
+	}
+	for _, status := range policy.RetryOn {
+		if status < 100 || status >= 600 {
+			return fmt.Errorf("invalid HTTP status to retry on: %d", status)
+		}
+	}
+	return nil
+}
+
+// backoff calculates delay in milliseconds before the next attempt after the given number of attempts
+func (policy *RetryPolicy) backoff(attempts int) int64 {
+	delay := int64(policy.InitialBackoff)
+	if delay == 0 {
+		delay = defaultBackoff
+	}
+	max := int64(policy.MaxBackoff)
+	if max == 0 {
+		max = defaultMaxBackoff
+	}
+
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		return max
+	}
+	return delay
+}
+
+// retryable checks if forwarding that is answered with the given HTTP status should be retried
+func (policy *RetryPolicy) retryable(status int) bool {
+	statuses := policy.RetryOn
+	if len(statuses) == 0 {
+		statuses = defaultRetryStatuses
+	}
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRetryLimit verifies that a new forward retry can be added to basket with the given number of retries
+func checkRetryLimit(size int, exists bool) error {
+	if !exists && size >= maxForwardRetries {
+		return fmt.Errorf("basket may not have more than %d failed forwards", maxForwardRetries)
+	}
+	return nil
+}
+
+// sortForwardRetries orders forward retries by date of collected requests
+func sortForwardRetries(retries []*ForwardRetry) []*ForwardRetry {
+	sort.Slice(retries, func(i, j int) bool {
+		if retries[i].Request.Date != retries[j].Request.Date {
+			return retries[i].Request.Date < retries[j].Request.Date
+		}
+		return retries[i].ID < retries[j].ID
+	})
+	return retries
+}
+
+// forwardAndForget forwards request and discards the response, failed forwarding is retried according to
+// retry policy of basket
+func forwardAndForget(basket Basket, request *RequestData, target ForwardTarget, name string) {
+	config := basket.Config()
+	status, errText := attemptForward(getHTTPClient(config.HTTPClient, target.InsecureTLS), request, target, name)
+	policy := config.Retry
+	if policy == nil || status == 0 || !policy.retryable(status) {
+		return
+	}
+
+	retry := &ForwardRetry{ID: GenerateID(), Target: target, Request: request, Attempts: 1}
+	handleForwardFailure(basket, name, retry, policy, status, errText)
+}
+
+// attemptForward forwards request once, returns HTTP status of forwarding response and its body if not successful;
+// the request is not bound to the incoming one, since it is sent in background
+func attemptForward(client *http.Client, request *RequestData, target ForwardTarget, name string) (int, string) {
+	response, err := request.ForwardTo(context.Background(), client, target, name)
+	if err != nil {
+		log.Printf("[warn] failed to forward request for basket: %s - %s", name, err)
+		return 0, err.Error()
+	}
+	defer response.Body.Close()
+
+	var errText string
+	if response.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, maxRetryErrorSize))
+		errText = string(body)
+	}
+	io.Copy(ioutil.Discard, response.Body)
+
+	return response.StatusCode, errText
+}
+
+// handleForwardFailure schedules next attempt of failed forwarding or moves it into dead-letter list
+func handleForwardFailure(basket Basket, name string, retry *ForwardRetry, policy *RetryPolicy, status int,
+	errText string) {
+	retry.LastStatus = status
+	retry.LastError = errText
+	if policy == nil || retry.Attempts >= policy.MaxAttempts {
+		retry.Dead = true
+		retry.NextAttempt = 0
+	} else {
+		retry.NextAttempt = time.Now().UnixNano()/toMs + policy.backoff(retry.Attempts)
+	}
+
+	if err := basket.SaveForwardRetry(retry); err != nil {
+		log.Printf("[warn] failed to keep failed forward of basket: %s - %s", name, err)
+		return
+	}
+
+	if retry.Dead {
+		log.Printf("[warn] forward of basket: %s to %s failed after %d attempts", name, retry.Target.URL, retry.Attempts)
+	} else {
+		forwardRetries.schedule(name, retry)
+	}
+}
+
+// retryScheduler runs scheduled attempts of failed forwards, the queue itself is kept in baskets database
+type retryScheduler struct {
+	sync.Mutex
+	timers map[string]*time.Timer
+}
+
+var forwardRetries = &retryScheduler{timers: make(map[string]*time.Timer)}
+
+func (scheduler *retryScheduler) schedule(name string, retry *ForwardRetry) {
+	key := name + "/" + retry.ID
+	delay := time.Duration(retry.NextAttempt-time.Now().UnixNano()/toMs) * time.Millisecond
+	if delay < 0 {
+		delay = 0
+	}
+
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	if timer, exists := scheduler.timers[key]; exists {
+		timer.Stop()
+	}
+	id := retry.ID
+	scheduler.timers[key] = time.AfterFunc(delay, func() { scheduler.run(name, id) })
+}
+
+func (scheduler *retryScheduler) cancel(name string, id string) {
+	key := name + "/" + id
+
+	scheduler.Lock()
+	defer scheduler.Unlock()
+
+	if timer, exists := scheduler.timers[key]; exists {
+		timer.Stop()
+		delete(scheduler.timers, key)
+	}
+}
+
+func (scheduler *retryScheduler) run(name string, id string) {
+	scheduler.Lock()
+	delete(scheduler.timers, name+"/"+id)
+	scheduler.Unlock()
+
+	// basket or retry might be deleted in the meantime
+	basket := basketsDb.Get(name)
+	if basket == nil {
+		return
+	}
+	retry := basket.GetForwardRetry(id)
+	if retry == nil || retry.Dead {
+		return
+	}
+
+	retry.Attempts++
+	config := basket.Config()
+	status, errText := attemptForward(getHTTPClient(config.HTTPClient, retry.Target.InsecureTLS), retry.Request,
+		retry.Target, name)
+	policy := config.Retry
+	if status != 0 && (policy == nil || !policy.retryable(status)) {
+		// forwarded
+		basket.DeleteForwardRetry(id)
+		return
+	}
+
+	handleForwardFailure(basket, name, retry, policy, status, errText)
+}
+
+// redrive moves forward retry from dead-letter list back to the queue for immediate attempt
+func (scheduler *retryScheduler) redrive(basket Basket, name string, retry *ForwardRetry) error {
+	retry.Dead = false
+	retry.Attempts = 0
+	retry.NextAttempt = time.Now().UnixNano() / toMs
+	if err := basket.SaveForwardRetry(retry); err != nil {
+		return err
+	}
+
+	scheduler.schedule(name, retry)
+	return nil
+}
+
+// resumeForwardRetries schedules pending forward retries of all baskets kept in database, e.g. after restart
+func resumeForwardRetries(db BasketsDatabase) {
+	count := 0
+	for skip := 0; ; skip += retryNamesPageSize {
+		page := db.GetNames(retryNamesPageSize, skip)
+		for _, name := range page.Names {
+			if basket := db.Get(name); basket != nil {
+				for _, retry := range basket.GetForwardRetries(false) {
+					forwardRetries.schedule(name, retry)
+					count++
+				}
+			}
+		}
+		if !page.HasMore {
+			break
+		}
+	}
+
+	if count > 0 {
+		log.Printf("[info] resumed %d pending forward retries", count)
+	}
+}
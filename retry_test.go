@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRetryPolicy(t *testing.T) {
+	assert.NoError(t, validateRetryPolicy(&RetryPolicy{MaxAttempts: 3}))
+	assert.NoError(t, validateRetryPolicy(&RetryPolicy{MaxAttempts: 5, InitialBackoff: 500, MaxBackoff: 10000,
+		RetryOn: []int{500, 503}}))
+
+	assert.Error(t, validateRetryPolicy(&RetryPolicy{MaxAttempts: 0}))
+	assert.Error(t, validateRetryPolicy(&RetryPolicy{MaxAttempts: maxRetryAttempts + 1}))
+	assert.Error(t, validateRetryPolicy(&RetryPolicy{MaxAttempts: 3, InitialBackoff: -1}))
+	assert.Error(t, validateRetryPolicy(&RetryPolicy{MaxAttempts: 3, MaxBackoff: maxRetryBackoff + 1}))
+	assert.Error(t, validateRetryPolicy(&RetryPolicy{MaxAttempts: 3, RetryOn: []int{99}}))
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	policy := &RetryPolicy{MaxAttempts: 10, InitialBackoff: 100, MaxBackoff: 1000}
+	assert.Equal(t, int64(100), policy.backoff(1))
+	assert.Equal(t, int64(200), policy.backoff(2))
+	assert.Equal(t, int64(800), policy.backoff(4))
+	assert.Equal(t, int64(1000), policy.backoff(5))
+	assert.Equal(t, int64(1000), policy.backoff(50))
+
+	policy = &RetryPolicy{MaxAttempts: 3}
+	assert.Equal(t, int64(defaultBackoff), policy.backoff(1))
+
+	assert.True(t, policy.retryable(503))
+	assert.False(t, policy.retryable(500))
+	policy.RetryOn = []int{500}
+	assert.True(t, policy.retryable(500))
+	assert.False(t, policy.retryable(503))
+}
+
+func TestAcceptBasketRequests_WithForwardRetry(t *testing.T) {
+	basket := "retry01"
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL,
+		Retry: &RetryPolicy{MaxAttempts: 3, InitialBackoff: 10}})
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("{\"id\":1}"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+		// request is forwarded on third attempt
+		assert.Eventually(t, func() bool { return atomic.LoadInt32(&calls) == 3 }, 3*time.Second, 10*time.Millisecond)
+		assert.Eventually(t, func() bool { return len(basketsDb.Get(basket).GetForwardRetries(false)) == 0 },
+			3*time.Second, 10*time.Millisecond)
+		assert.Empty(t, basketsDb.Get(basket).GetForwardRetries(true), "dead letters are not expected")
+	}
+}
+
+func TestAcceptBasketRequests_ProxyWithForwardRetry(t *testing.T) {
+	basket := "retry03"
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try later", http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ProxyResponse: true,
+		ForwardTargets: []ForwardTarget{{URL: proxy.URL, ProxyResponse: true}, {URL: failing.URL}},
+		Retry:          &RetryPolicy{MaxAttempts: 3, InitialBackoff: 60000}})
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("{\"id\":1}"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+		b := basketsDb.Get(basket)
+		assert.Eventually(t, func() bool { return len(b.GetForwardRetries(false)) == 1 }, 3*time.Second,
+			10*time.Millisecond, "scheduled retry is expected")
+		page := b.GetRequests(10, 0)
+		if retries := b.GetForwardRetries(false); assert.Len(t, page.Requests, 1) && assert.Len(t, retries, 1) {
+			assert.Equal(t, page.Requests[0].ID, retries[0].Request.ID, "retry should refer to collected request")
+		}
+		for _, retry := range b.GetForwardRetries(false) {
+			b.DeleteForwardRetry(retry.ID)
+		}
+	}
+}
+
+func TestBasketDeadLetters_API(t *testing.T) {
+	basket := "retry02"
+
+	var available int32
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if atomic.LoadInt32(&available) == 0 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+		} else {
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+	defer ts.Close()
+
+	auth, _ := basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL,
+		Retry: &RetryPolicy{MaxAttempts: 2, InitialBackoff: 10}})
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("{\"id\":1}"))
+	if assert.NoError(t, err) {
+		AcceptBasketRequests(httptest.NewRecorder(), r)
+	}
+
+	// all attempts are exhausted
+	b := basketsDb.Get(basket)
+	assert.Eventually(t, func() bool { return len(b.GetForwardRetries(true)) == 1 }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls), "wrong number of attempts")
+
+	r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/dead_letters", nil)
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		GetBasketDeadLetters(w, r, ps)
+
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		dead := make([]*ForwardRetry, 0)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &dead)) && assert.Len(t, dead, 1) {
+			assert.Equal(t, 2, dead[0].Attempts, "wrong number of attempts")
+			assert.Equal(t, 503, dead[0].LastStatus, "wrong last status")
+			assert.Contains(t, dead[0].LastError, "try later", "wrong last error")
+			assert.Equal(t, "{\"id\":1}", dead[0].Request.Body, "wrong request")
+		}
+	}
+
+	// unknown dead letter
+	r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/dead_letters/abc", nil)
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		RedriveBasketDeadLetter(w, r, append(ps, httprouter.Param{Key: "id", Value: "abc"}))
+		assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+	}
+
+	// re-drive when target is available again
+	atomic.StoreInt32(&available, 1)
+	id := b.GetForwardRetries(true)[0].ID
+	r, err = http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket+"/dead_letters/"+id, nil)
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		RedriveBasketDeadLetter(w, r, append(ps, httprouter.Param{Key: "id", Value: id}))
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+		assert.Eventually(t, func() bool { return b.GetForwardRetry(id) == nil }, 3*time.Second, 10*time.Millisecond)
+		assert.Equal(t, int32(3), atomic.LoadInt32(&calls), "wrong number of attempts")
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"text/template"
+)
+
+// Actions of body rewrite rules
+const (
+	BodyRewriteSet    = "set"
+	BodyRewriteRemove = "remove"
+)
+
+// BodyRewrite describes transformation of a field of JSON body in forwarded request, nested fields are addressed
+// with dot notation, e.g. "user.password".
+type BodyRewrite struct {
+	Field  string          `json:"field"`
+	Action string          `json:"action"`
+	Value  json.RawMessage `json:"value,omitempty"`
+}
+
+// validateForwardRewrites validates rewrite rules of forward target
+func validateForwardRewrites(target ForwardTarget) error {
+	for _, headers := range []http.Header{target.SetHeaders, target.AddHeaders} {
+		for header, vals := range headers {
+			if !isValidHeaderName(header) {
+				return fmt.Errorf("invalid forward header name: %q", header)
+			}
+			if target.TemplateHeaders {
+				for _, val := range vals {
+					if _, err := template.New(header).Funcs(forwardTemplateFuncs(nil, "")).Parse(val); err != nil {
+						return fmt.Errorf("error in forward header %s", err)
+					}
+				}
+			}
+		}
+	}
+	for _, header := range target.RemoveHeaders {
+		if !isValidHeaderName(header) {
+			return fmt.Errorf("invalid forward header name: %q", header)
+		}
+	}
+
+	if len(target.Host) > 0 {
+		if u, err := url.Parse("http://" + target.Host); err != nil || u.Host != target.Host {
+			return fmt.Errorf("invalid forward host: %q", target.Host)
+		}
+	}
+
+	for _, param := range target.RemoveQuery {
+		if len(param) == 0 {
+			return fmt.Errorf("query parameter to remove may not be empty")
+		}
+	}
+
+	for _, rewrite := range target.BodyRewrites {
+		if len(rewrite.Field) == 0 || strings.HasPrefix(rewrite.Field, ".") || strings.HasSuffix(rewrite.Field, ".") {
+			return fmt.Errorf("invalid field of body rewrite: %q", rewrite.Field)
+		}
+		switch rewrite.Action {
+		case BodyRewriteSet:
+			if !json.Valid(rewrite.Value) {
+				return fmt.Errorf("value of body rewrite for field %s should be a valid JSON", rewrite.Field)
+			}
+		case BodyRewriteRemove:
+		default:
+			return fmt.Errorf("unknown action of body rewrite: %q, expected %s or %s", rewrite.Action,
+				BodyRewriteSet, BodyRewriteRemove)
+		}
+	}
+
+	return nil
+}
+
+func isValidHeaderName(header string) bool {
+	return len(header) > 0 && !strings.ContainsAny(header, " \t\r\n,:")
+}
+
+// forwardTemplateFuncs defines functions available in templated header values of forwarded request
+func forwardTemplateFuncs(req *RequestData, name string) template.FuncMap {
+	return template.FuncMap{
+		"basket": func() string { return name },
+		"method": func() string { return req.Method },
+		"path":   func() string { return req.Path },
+		"header": func(key string) string { return req.Header.Get(key) },
+		"query": func(key string) string {
+			values, _ := url.ParseQuery(req.Query)
+			return values.Get(key)
+		},
+	}
+}
+
+// rewriteForwardQuery removes configured parameters from query of forwarded request
+func rewriteForwardQuery(query string, target ForwardTarget) string {
+	if len(target.RemoveQuery) == 0 || len(query) == 0 {
+		return query
+	}
+
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return query
+	}
+	for _, param := range target.RemoveQuery {
+		values.Del(param)
+	}
+	return values.Encode()
+}
+
+// rewriteForwardBody applies body rewrite rules to JSON object in body of forwarded request, other bodies are
+// forwarded unchanged
+func rewriteForwardBody(body string, target ForwardTarget) string {
+	if len(target.BodyRewrites) == 0 {
+		return body
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+	var doc map[string]interface{}
+	if err := decoder.Decode(&doc); err != nil || doc == nil {
+		return body
+	}
+
+	for _, rewrite := range target.BodyRewrites {
+		fields := strings.Split(rewrite.Field, ".")
+		parent := doc
+		for _, field := range fields[:len(fields)-1] {
+			child, ok := parent[field].(map[string]interface{})
+			if !ok {
+				if rewrite.Action == BodyRewriteRemove {
+					parent = nil
+					break
+				}
+				child = make(map[string]interface{})
+				parent[field] = child
+			}
+			parent = child
+		}
+		if parent == nil {
+			continue
+		}
+
+		field := fields[len(fields)-1]
+		switch rewrite.Action {
+		case BodyRewriteSet:
+			parent[field] = rewrite.Value
+		case BodyRewriteRemove:
+			delete(parent, field)
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return string(data)
+}
+
+// rewriteForwardHeaders applies header rewrites of forward target to forwarded request
+func rewriteForwardHeaders(forwardReq *http.Request, req *RequestData, target ForwardTarget, name string) {
+	for _, header := range target.RemoveHeaders {
+		forwardReq.Header.Del(header)
+	}
+
+	for header, vals := range target.SetHeaders {
+		header = textproto.CanonicalMIMEHeaderKey(header)
+		forwardReq.Header.Del(header)
+		for _, val := range vals {
+			forwardReq.Header.Add(header, renderForwardHeader(header, val, req, target, name))
+		}
+	}
+
+	for header, vals := range target.AddHeaders {
+		for _, val := range vals {
+			forwardReq.Header.Add(header, renderForwardHeader(header, val, req, target, name))
+		}
+	}
+
+	if len(target.Host) > 0 {
+		forwardReq.Host = target.Host
+	}
+}
+
+func renderForwardHeader(header string, value string, req *RequestData, target ForwardTarget, name string) string {
+	if !target.TemplateHeaders {
+		return value
+	}
+
+	tmpl, err := template.New(header).Funcs(forwardTemplateFuncs(req, name)).Parse(value)
+	if err == nil {
+		var buf bytes.Buffer
+		if err = tmpl.Execute(&buf, nil); err == nil {
+			return buf.String()
+		}
+	}
+
+	log.Printf("[warn] failed to render forward header %s for basket: %s - %s", header, name, err)
+	return value
+}
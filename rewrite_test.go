@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateForwardRewrites(t *testing.T) {
+	assert.NoError(t, validateForwardRewrites(ForwardTarget{
+		SetHeaders:      http.Header{"X-Basket": {"{{basket}}"}},
+		AddHeaders:      http.Header{"X-Trace": {"{{header \"X-Request-Id\"}}"}},
+		RemoveHeaders:   []string{"Cookie"},
+		TemplateHeaders: true,
+		Host:            "api.example.com:8443",
+		RemoveQuery:     []string{"token"},
+		BodyRewrites: []BodyRewrite{
+			{Field: "user.password", Action: BodyRewriteRemove},
+			{Field: "env", Action: BodyRewriteSet, Value: json.RawMessage(`"dev"`)}}}))
+
+	assert.Error(t, validateForwardRewrites(ForwardTarget{AddHeaders: http.Header{"X Trace": {"1"}}}))
+	assert.Error(t, validateForwardRewrites(ForwardTarget{SetHeaders: http.Header{"X-A": {"{{unknown}}"}},
+		TemplateHeaders: true}))
+	assert.NoError(t, validateForwardRewrites(ForwardTarget{SetHeaders: http.Header{"X-A": {"{{unknown}}"}}}))
+	assert.Error(t, validateForwardRewrites(ForwardTarget{Host: "bad host/path"}))
+	assert.Error(t, validateForwardRewrites(ForwardTarget{RemoveQuery: []string{""}}))
+	assert.Error(t, validateForwardRewrites(ForwardTarget{BodyRewrites: []BodyRewrite{{Field: "a.", Action: "remove"}}}))
+	assert.Error(t, validateForwardRewrites(ForwardTarget{BodyRewrites: []BodyRewrite{{Field: "a", Action: "rename"}}}))
+	assert.Error(t, validateForwardRewrites(ForwardTarget{BodyRewrites: []BodyRewrite{{Field: "a", Action: "set",
+		Value: json.RawMessage(`{invalid`)}}}))
+}
+
+func TestRewriteForwardBody(t *testing.T) {
+	target := ForwardTarget{BodyRewrites: []BodyRewrite{
+		{Field: "user.password", Action: BodyRewriteRemove},
+		{Field: "missing.field", Action: BodyRewriteRemove},
+		{Field: "meta.env", Action: BodyRewriteSet, Value: json.RawMessage(`"dev"`)},
+		{Field: "id", Action: BodyRewriteSet, Value: json.RawMessage(`{"v": 2}`)}}}
+
+	assert.JSONEq(t, `{"id": {"v": 2}, "amount": 12345678901234567890, "user": {"name": "joe"}, "meta": {"env": "dev"}}`,
+		rewriteForwardBody(`{"id": 1, "amount": 12345678901234567890, "user": {"name": "joe", "password": "secret"}}`,
+			target))
+
+	// non JSON objects are forwarded unchanged
+	assert.Equal(t, "user=joe", rewriteForwardBody("user=joe", target))
+	assert.Equal(t, "[1, 2]", rewriteForwardBody("[1, 2]", target))
+	assert.Equal(t, "{\"a\":1}", rewriteForwardBody("{\"a\":1}", ForwardTarget{}))
+}
+
+func TestRewriteForwardQuery(t *testing.T) {
+	target := ForwardTarget{RemoveQuery: []string{"token", "debug"}}
+	assert.Equal(t, "id=15", rewriteForwardQuery("id=15&token=abc&debug", target))
+	assert.Equal(t, "", rewriteForwardQuery("token=abc", target))
+	assert.Equal(t, "id=15&token=abc", rewriteForwardQuery("id=15&token=abc", ForwardTarget{}))
+}
+
+func TestRequestData_ForwardTo_Rewrites(t *testing.T) {
+	basket := "rewrite"
+
+	data := &RequestData{Method: "POST", Path: "/" + basket + "/users", Query: "id=15&token=abc",
+		Header: http.Header{"Content-Type": {"application/json"}, "Cookie": {"session=1"}, "X-Request-Id": {"r-42"}},
+		Body:   `{"name": "joe", "password": "secret"}`}
+
+	var forwarded *RequestData
+	var host string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = ToRequestData(r)
+		host = r.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	target := ForwardTarget{URL: ts.URL,
+		SetHeaders:      http.Header{"x-basket": {"{{basket}}-{{method}}"}, "Content-Type": {"application/json; v=2"}},
+		AddHeaders:      http.Header{"X-Request-Id": {"{{header \"X-Request-Id\"}}-{{query \"id\"}}"}},
+		RemoveHeaders:   []string{"Cookie"},
+		TemplateHeaders: true,
+		Host:            "api.example.com",
+		RemoveQuery:     []string{"token"},
+		BodyRewrites:    []BodyRewrite{{Field: "password", Action: BodyRewriteRemove}}}
+
+	response, err := data.ForwardTo(context.Background(), new(http.Client), target, basket)
+	if assert.NoError(t, err) && assert.NotNil(t, forwarded, "request is not forwarded") {
+		response.Body.Close()
+
+		assert.Equal(t, "id=15", forwarded.Query, "wrong query")
+		assert.JSONEq(t, `{"name": "joe"}`, forwarded.Body, "wrong body")
+		assert.Equal(t, int64(len(`{"name":"joe"}`)), forwarded.ContentLength, "wrong content length")
+		assert.Equal(t, "api.example.com", host, "wrong host")
+		assert.Empty(t, forwarded.Header.Get("Cookie"), "header is not removed")
+		assert.Equal(t, "rewrite-POST", forwarded.Header.Get("X-Basket"), "wrong templated header")
+		assert.Equal(t, "application/json; v=2", forwarded.Header.Get("Content-Type"), "header is not replaced")
+		assert.Equal(t, []string{"r-42", "r-42-15"}, forwarded.Header["X-Request-Id"], "header is not added")
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	maxSchemaDepth    = 32
+	maxCachedPatterns = 1000
+)
+
+// JSONSchema describes a subset of JSON Schema (as used by OpenAPI 3 documents) that is supported to validate
+// HTTP requests and to generate sample responses.
+type JSONSchema struct {
+	Type                 schemaTypes            `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Nullable             bool                   `json:"nullable,omitempty"`
+	Enum                 []interface{}          `json:"enum,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	AdditionalProperties *JSONSchema            `json:"additionalProperties,omitempty"`
+	Items                *JSONSchema            `json:"items,omitempty"`
+	MinItems             *int                   `json:"minItems,omitempty"`
+	MaxItems             *int                   `json:"maxItems,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	MaxLength            *int                   `json:"maxLength,omitempty"`
+	Pattern              string                 `json:"pattern,omitempty"`
+	AllOf                []*JSONSchema          `json:"allOf,omitempty"`
+	AnyOf                []*JSONSchema          `json:"anyOf,omitempty"`
+	OneOf                []*JSONSchema          `json:"oneOf,omitempty"`
+	Not                  *JSONSchema            `json:"not,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	Example              interface{}            `json:"example,omitempty"`
+}
+
+// schemaTypes holds list of allowed JSON types, a single type is expressed as a string
+type schemaTypes []string
+
+// UnmarshalJSON accepts JSON type either as a string or as an array of strings
+func (types *schemaTypes) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*types = schemaTypes{single}
+		return nil
+	}
+
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return fmt.Errorf("schema type should be a string or an array of strings")
+	}
+	*types = schemaTypes(multiple)
+	return nil
+}
+
+// MarshalJSON writes a single JSON type as a string
+func (types schemaTypes) MarshalJSON() ([]byte, error) {
+	if len(types) == 1 {
+		return json.Marshal(types[0])
+	}
+	return json.Marshal([]string(types))
+}
+
+// UnmarshalJSON parses JSON schema, boolean schemas "true" and "false" are supported
+func (schema *JSONSchema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*schema = JSONSchema{}
+		return nil
+	case "false":
+		*schema = JSONSchema{Not: &JSONSchema{}}
+		return nil
+	}
+
+	type plain JSONSchema
+	return json.Unmarshal(data, (*plain)(schema))
+}
+
+// patternsCache keeps compiled regular expressions of basket configuration, patterns are compiled when configuration
+// is validated and reused by incoming requests
+type patternsCache struct {
+	sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+var compiledPatterns = &patternsCache{patterns: make(map[string]*regexp.Regexp)}
+
+// compile returns compiled regular expression of the pattern
+func (cache *patternsCache) compile(pattern string) (*regexp.Regexp, error) {
+	cache.Lock()
+	re, exists := cache.patterns[pattern]
+	cache.Unlock()
+	if exists {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+	if len(cache.patterns) >= maxCachedPatterns {
+		cache.patterns = make(map[string]*regexp.Regexp)
+	}
+	cache.patterns[pattern] = re
+
+	return re, nil
+}
+
+// validateSchema verifies that JSON schema can be used for validation
+func validateSchema(schema *JSONSchema) error {
+	return walkSchema(schema, 0, func(s *JSONSchema) error {
+		for _, t := range s.Type {
+			switch t {
+			case "object", "array", "string", "integer", "number", "boolean", "null":
+			default:
+				return fmt.Errorf("unknown type in JSON schema: %s", t)
+			}
+		}
+		if len(s.Pattern) > 0 {
+			if _, err := compiledPatterns.compile(s.Pattern); err != nil {
+				return fmt.Errorf("invalid pattern in JSON schema: %s", err)
+			}
+		}
+		return nil
+	})
+}
+
+func walkSchema(schema *JSONSchema, depth int, visit func(*JSONSchema) error) error {
+	if schema == nil {
+		return nil
+	}
+	if depth > maxSchemaDepth {
+		return fmt.Errorf("JSON schema is nested too deep, max. depth is %d", maxSchemaDepth)
+	}
+	if err := visit(schema); err != nil {
+		return err
+	}
+
+	children := []*JSONSchema{schema.AdditionalProperties, schema.Items, schema.Not}
+	for _, prop := range schema.Properties {
+		children = append(children, prop)
+	}
+	children = append(children, schema.AllOf...)
+	children = append(children, schema.AnyOf...)
+	children = append(children, schema.OneOf...)
+
+	for _, child := range children {
+		if err := walkSchema(child, depth+1, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate validates JSON value (as decoded by "encoding/json" package) against the schema,
+// returns list of found violations
+func (schema *JSONSchema) Validate(value interface{}) []string {
+	return schema.validate("$", value, 0)
+}
+
+func (schema *JSONSchema) validate(path string, value interface{}, depth int) []string {
+	if schema == nil || depth > maxSchemaDepth {
+		return nil
+	}
+
+	if value == nil && schema.Nullable {
+		return nil
+	}
+
+	var errs []string
+	fail := func(format string, args ...interface{}) {
+		errs = append(errs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	// type
+	if len(schema.Type) > 0 && !schema.Type.matches(value) {
+		fail("expected %s, but was %s", strings.Join(schema.Type, " or "), jsonType(value))
+		return errs
+	}
+
+	// enum
+	if len(schema.Enum) > 0 && !containsValue(schema.Enum, value) {
+		fail("value is not one of allowed values")
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property: %s", name)
+			}
+		}
+		for _, name := range sortedKeys(v) {
+			if prop, ok := schema.Properties[name]; ok {
+				errs = append(errs, prop.validate(path+"."+name, v[name], depth+1)...)
+			} else if schema.AdditionalProperties != nil {
+				if schema.AdditionalProperties.isFalse() {
+					fail("property is not allowed: %s", name)
+				} else {
+					errs = append(errs, schema.AdditionalProperties.validate(path+"."+name, v[name], depth+1)...)
+				}
+			}
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			fail("expected at least %d items, but was %d", *schema.MinItems, len(v))
+		}
+		if schema.MaxItems != nil && len(v) > *schema.MaxItems {
+			fail("expected at most %d items, but was %d", *schema.MaxItems, len(v))
+		}
+		if schema.Items != nil {
+			for i, item := range v {
+				errs = append(errs, schema.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, depth+1)...)
+			}
+		}
+	case string:
+		length := len([]rune(v))
+		if schema.MinLength != nil && length < *schema.MinLength {
+			fail("expected at least %d characters, but was %d", *schema.MinLength, length)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			fail("expected at most %d characters, but was %d", *schema.MaxLength, length)
+		}
+		if len(schema.Pattern) > 0 {
+			if re, err := compiledPatterns.compile(schema.Pattern); err == nil && !re.MatchString(v) {
+				fail("value does not match pattern: %s", schema.Pattern)
+			}
+		}
+	case float64:
+		if schema.Minimum != nil && v < *schema.Minimum {
+			fail("value %v is less than minimum %v", v, *schema.Minimum)
+		}
+		if schema.Maximum != nil && v > *schema.Maximum {
+			fail("value %v is greater than maximum %v", v, *schema.Maximum)
+		}
+	}
+
+	// composition
+	for _, sub := range schema.AllOf {
+		errs = append(errs, sub.validate(path, value, depth+1)...)
+	}
+	if len(schema.AnyOf) > 0 && countMatching(schema.AnyOf, path, value, depth) == 0 {
+		fail("value does not match any of allowed schemas")
+	}
+	if len(schema.OneOf) > 0 {
+		if n := countMatching(schema.OneOf, path, value, depth); n != 1 {
+			fail("value should match exactly one schema, but matched %d", n)
+		}
+	}
+	if schema.Not != nil && len(schema.Not.validate(path, value, depth+1)) == 0 {
+		fail("value is not allowed")
+	}
+
+	return errs
+}
+
+func (schema *JSONSchema) isFalse() bool {
+	return schema.Not != nil && reflect.DeepEqual(*schema.Not, JSONSchema{})
+}
+
+func countMatching(schemas []*JSONSchema, path string, value interface{}, depth int) int {
+	count := 0
+	for _, sub := range schemas {
+		if len(sub.validate(path, value, depth+1)) == 0 {
+			count++
+		}
+	}
+	return count
+}
+
+func (types schemaTypes) matches(value interface{}) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType returns JSON type of value, numbers without fraction are reported as "integer"
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(normalizeJSON(v), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeJSON converts value to the form produced by "encoding/json" decoding
+func normalizeJSON(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var normalized interface{}
+	json.Unmarshal(data, &normalized)
+	return normalized
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// coerceParameter converts string values of HTTP request parameter (query, header, path) to JSON value
+// according to expected schema type
+func coerceParameter(schema *JSONSchema, values []string) interface{} {
+	if schema == nil || len(values) == 0 {
+		return nil
+	}
+
+	if schema.Type.includes("array") {
+		if len(values) == 1 {
+			values = strings.Split(values[0], ",")
+		}
+		items := make([]interface{}, len(values))
+		for i, v := range values {
+			if schema.Items != nil {
+				items[i] = coerceParameter(schema.Items, []string{v})
+			} else {
+				items[i] = v
+			}
+		}
+		return items
+	}
+
+	value := values[0]
+	switch {
+	case schema.Type.includes("integer"), schema.Type.includes("number"):
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case schema.Type.includes("boolean"):
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return value
+}
+
+func (types schemaTypes) includes(t string) bool {
+	for _, v := range types {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Sample generates sample value that conforms to the schema, examples and defaults are preferred
+func (schema *JSONSchema) Sample() interface{} {
+	return schema.sample(0)
+}
+
+func (schema *JSONSchema) sample(depth int) interface{} {
+	if schema == nil || depth > maxSchemaDepth {
+		return nil
+	}
+
+	switch {
+	case schema.Example != nil:
+		return schema.Example
+	case schema.Default != nil:
+		return schema.Default
+	case len(schema.Enum) > 0:
+		return schema.Enum[0]
+	case len(schema.AllOf) > 0:
+		// merge properties of all schemas
+		merged := map[string]interface{}{}
+		for _, sub := range schema.AllOf {
+			value := sub.sample(depth + 1)
+			if props, ok := value.(map[string]interface{}); ok {
+				for k, v := range props {
+					merged[k] = v
+				}
+			} else if value != nil {
+				return value
+			}
+		}
+		for k, v := range schema.sampleProperties(depth) {
+			merged[k] = v
+		}
+		return merged
+	case len(schema.OneOf) > 0:
+		return schema.OneOf[0].sample(depth + 1)
+	case len(schema.AnyOf) > 0:
+		return schema.AnyOf[0].sample(depth + 1)
+	}
+
+	t := ""
+	if len(schema.Type) > 0 {
+		t = schema.Type[0]
+	} else if len(schema.Properties) > 0 {
+		t = "object"
+	} else if schema.Items != nil {
+		t = "array"
+	}
+
+	switch t {
+	case "object":
+		return schema.sampleProperties(depth)
+	case "array":
+		if schema.Items == nil {
+			return []interface{}{}
+		}
+		return []interface{}{schema.Items.sample(depth + 1)}
+	case "string":
+		return sampleString(schema.Format)
+	case "integer":
+		if schema.Minimum != nil {
+			return int64(math.Ceil(*schema.Minimum))
+		}
+		return 0
+	case "number":
+		if schema.Minimum != nil {
+			return *schema.Minimum
+		}
+		return 0.0
+	case "boolean":
+		return true
+	default:
+		return nil
+	}
+}
+
+func (schema *JSONSchema) sampleProperties(depth int) map[string]interface{} {
+	props := make(map[string]interface{}, len(schema.Properties))
+	for name, prop := range schema.Properties {
+		props[name] = prop.sample(depth + 1)
+	}
+	return props
+}
+
+func sampleString(format string) string {
+	switch format {
+	case "date-time":
+		return "2020-01-01T00:00:00Z"
+	case "date":
+		return "2020-01-01"
+	case "time":
+		return "00:00:00Z"
+	case "email":
+		return "user@example.com"
+	case "uuid":
+		return "00000000-0000-0000-0000-000000000000"
+	case "uri", "url":
+		return "https://example.com"
+	case "hostname":
+		return "example.com"
+	case "ipv4":
+		return "127.0.0.1"
+	case "ipv6":
+		return "::1"
+	case "byte":
+		return "c3RyaW5n"
+	default:
+		return "string"
+	}
+}
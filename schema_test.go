@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const userSchema = `{
+	"type": "object",
+	"required": ["id", "name"],
+	"additionalProperties": false,
+	"properties": {
+		"id": {"type": "integer", "minimum": 1},
+		"name": {"type": "string", "minLength": 2, "maxLength": 10, "pattern": "^[A-Z]"},
+		"email": {"type": "string", "format": "email"},
+		"role": {"type": "string", "enum": ["admin", "user"], "default": "user"},
+		"tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2},
+		"manager": {"type": "integer", "nullable": true}
+	}
+}`
+
+func parseSchema(t *testing.T, data string) *JSONSchema {
+	schema := new(JSONSchema)
+	if !assert.NoError(t, json.Unmarshal([]byte(data), schema)) {
+		t.FailNow()
+	}
+	return schema
+}
+
+func parseJSON(t *testing.T, data string) interface{} {
+	var value interface{}
+	if !assert.NoError(t, json.Unmarshal([]byte(data), &value)) {
+		t.FailNow()
+	}
+	return value
+}
+
+func TestJSONSchema_Validate(t *testing.T) {
+	schema := parseSchema(t, userSchema)
+
+	assert.Empty(t, schema.Validate(parseJSON(t, `{"id": 1, "name": "Joe", "role": "admin", "tags": ["a"], "manager": null}`)))
+
+	errs := schema.Validate(parseJSON(t, `{"id": 0, "name": "j", "role": "guest", "tags": ["a", "b", 3], "extra": true}`))
+	assert.Equal(t, []string{
+		"$: property is not allowed: extra",
+		"$.id: value 0 is less than minimum 1",
+		"$.name: expected at least 2 characters, but was 1",
+		"$.name: value does not match pattern: ^[A-Z]",
+		"$.role: value is not one of allowed values",
+		"$.tags: expected at most 2 items, but was 3",
+		"$.tags[2]: expected string, but was integer"}, errs)
+
+	errs = schema.Validate(parseJSON(t, `{"id": 1.5}`))
+	assert.Equal(t, []string{
+		"$: missing required property: name",
+		"$.id: expected integer, but was number"}, errs)
+
+	errs = schema.Validate(parseJSON(t, `[]`))
+	assert.Equal(t, []string{"$: expected object, but was array"}, errs)
+}
+
+func TestJSONSchema_ValidateComposition(t *testing.T) {
+	schema := parseSchema(t, `{"oneOf": [{"type": "string"}, {"type": "integer"}], "not": {"enum": [0]}}`)
+
+	assert.Empty(t, schema.Validate("text"))
+	assert.Empty(t, schema.Validate(float64(5)))
+	assert.Equal(t, []string{"$: value should match exactly one schema, but matched 0"}, schema.Validate(true))
+	assert.Equal(t, []string{"$: value is not allowed"}, schema.Validate(float64(0)))
+
+	schema = parseSchema(t, `{"anyOf": [{"type": "string"}, {"type": "null"}], "allOf": [{"maxLength": 3}]}`)
+	assert.Empty(t, schema.Validate(nil))
+	assert.Equal(t, []string{"$: expected at most 3 characters, but was 4"}, schema.Validate("abcd"))
+	assert.Equal(t, []string{"$: value does not match any of allowed schemas"}, schema.Validate(float64(1)))
+}
+
+func TestJSONSchema_Type(t *testing.T) {
+	schema := parseSchema(t, `{"type": ["string", "null"]}`)
+	assert.Equal(t, schemaTypes{"string", "null"}, schema.Type)
+	assert.Empty(t, schema.Validate(nil))
+	assert.NotEmpty(t, schema.Validate(true))
+
+	data, err := json.Marshal(parseSchema(t, `{"type": "string"}`))
+	if assert.NoError(t, err) {
+		assert.Equal(t, `{"type":"string"}`, string(data))
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	assert.NoError(t, validateSchema(nil))
+	assert.NoError(t, validateSchema(parseSchema(t, userSchema)))
+
+	err := validateSchema(parseSchema(t, `{"properties": {"id": {"type": "int"}}}`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown type in JSON schema: int")
+	}
+
+	err = validateSchema(parseSchema(t, `{"items": {"pattern": "[a-"}}`))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid pattern in JSON schema")
+	}
+}
+
+func TestPatternsCache(t *testing.T) {
+	cache := &patternsCache{patterns: make(map[string]*regexp.Regexp)}
+
+	re, err := cache.compile("^[a-z]+$")
+	if assert.NoError(t, err) {
+		assert.True(t, re.MatchString("abc"))
+		cached, _ := cache.compile("^[a-z]+$")
+		assert.Same(t, re, cached, "compiled pattern should be reused")
+	}
+
+	_, err = cache.compile("[a-")
+	assert.Error(t, err, "invalid pattern is not expected")
+	assert.Len(t, cache.patterns, 1, "invalid pattern should not be cached")
+
+	for i := 0; i < maxCachedPatterns; i++ {
+		cache.compile(fmt.Sprintf("^%d$", i))
+	}
+	assert.LessOrEqual(t, len(cache.patterns), maxCachedPatterns, "cache should be bounded")
+}
+
+func TestCoerceParameter(t *testing.T) {
+	assert.Equal(t, float64(42), coerceParameter(parseSchema(t, `{"type": "integer"}`), []string{"42"}))
+	assert.Equal(t, "abc", coerceParameter(parseSchema(t, `{"type": "integer"}`), []string{"abc"}))
+	assert.Equal(t, true, coerceParameter(parseSchema(t, `{"type": "boolean"}`), []string{"true"}))
+	assert.Equal(t, []interface{}{float64(1), float64(2)},
+		coerceParameter(parseSchema(t, `{"type": "array", "items": {"type": "number"}}`), []string{"1,2"}))
+	assert.Equal(t, []interface{}{"a", "b"}, coerceParameter(parseSchema(t, `{"type": "array"}`), []string{"a", "b"}))
+	assert.Nil(t, coerceParameter(nil, []string{"a"}))
+}
+
+func TestJSONSchema_Sample(t *testing.T) {
+	sample := parseSchema(t, userSchema).Sample()
+	assert.Equal(t, map[string]interface{}{
+		"id":      int64(1),
+		"name":    "string",
+		"email":   "user@example.com",
+		"role":    "user",
+		"tags":    []interface{}{"string"},
+		"manager": 0}, sample)
+
+	schema := parseSchema(t, `{"allOf": [{"properties": {"id": {"type": "integer", "example": 7}}}, {"properties": {"ok": {"type": "boolean"}}}]}`)
+	assert.Equal(t, map[string]interface{}{"id": float64(7), "ok": true}, schema.Sample())
+
+	assert.Equal(t, "2020-01-01", parseSchema(t, `{"oneOf": [{"type": "string", "format": "date"}]}`).Sample())
+	assert.Nil(t, (*JSONSchema)(nil).Sample())
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// Limits of response scripts
+const (
+	maxScriptSize  = 32 * 1024
+	maxScriptSteps = 1000000
+	scriptTimeout  = time.Second
+)
+
+const (
+	scriptFileName = "response.star"
+	scriptHandler  = "handle"
+)
+
+// scriptResponse describes response generated by response script
+type scriptResponse struct {
+	status  int
+	headers http.Header
+	body    []byte
+}
+
+var scriptBuiltins = map[string]bool{"json": true, "state": true}
+
+// validateResponseScript compiles response script without running it and checks that handler function is defined
+func validateResponseScript(script string) error {
+	if len(script) > maxScriptSize {
+		return fmt.Errorf("response script may not be larger than %d bytes", maxScriptSize)
+	}
+
+	file, _, err := starlark.SourceProgram(scriptFileName, script, func(name string) bool { return scriptBuiltins[name] })
+	if err != nil {
+		return fmt.Errorf("invalid response script: %s", err)
+	}
+
+	defined := false
+	for _, stmt := range file.Stmts {
+		switch stmt := stmt.(type) {
+		case *syntax.LoadStmt:
+			return fmt.Errorf("invalid response script: load statements are not supported")
+		case *syntax.DefStmt:
+			if stmt.Name.Name == scriptHandler {
+				if len(stmt.Params) != 1 {
+					return fmt.Errorf("invalid response script: function %s should accept exactly one parameter", scriptHandler)
+				}
+				defined = true
+			}
+		}
+	}
+
+	if !defined {
+		return fmt.Errorf("invalid response script: function %s(request) is not defined", scriptHandler)
+	}
+	return nil
+}
+
+// runResponseScript runs response script against collected HTTP request, returns nil response if the script
+// leaves the configured response unchanged
+func runResponseScript(script string, request *RequestData, name string, path string, params map[string]string,
+	state Basket) (*scriptResponse, error) {
+	thread := &starlark.Thread{Name: name, Print: func(*starlark.Thread, string) {}}
+	thread.SetMaxExecutionSteps(maxScriptSteps)
+	timer := time.AfterFunc(scriptTimeout, func() { thread.Cancel("time limit exceeded") })
+	defer timer.Stop()
+
+	thread.SetLocal(scriptMemoryKey, &scriptMemory{})
+
+	file, err := syntax.Parse(scriptFileName, script, 0)
+	if err != nil {
+		return nil, err
+	}
+	guardScript(file)
+
+	predeclared := scriptGuards()
+	predeclared["state"] = newStateModule(state)
+	program, err := starlark.FileProgram(file, predeclared.Has)
+	if err != nil {
+		return nil, err
+	}
+	globals, err := program.Init(thread, predeclared)
+	globals.Freeze()
+	if err != nil {
+		return nil, scriptError(err)
+	}
+
+	handler, ok := globals[scriptHandler].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("function %s(request) is not defined", scriptHandler)
+	}
+
+	result, err := starlark.Call(thread, handler, starlark.Tuple{toScriptRequest(request, name, path, params)}, nil)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+
+	return toScriptResponse(thread, result)
+}
+
+func scriptError(err error) error {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		return fmt.Errorf("%s", evalErr.Backtrace())
+	}
+	return err
+}
+
+// toScriptRequest converts collected HTTP request into value accessible by response script
+func toScriptRequest(request *RequestData, name string, path string, params map[string]string) starlark.Value {
+	headers := new(starlark.Dict)
+	for key, values := range request.Header {
+		if len(values) > 0 {
+			headers.SetKey(starlark.String(key), starlark.String(values[0]))
+		}
+	}
+
+	query := new(starlark.Dict)
+	if values, err := url.ParseQuery(request.Query); err == nil {
+		for key, vals := range values {
+			query.SetKey(starlark.String(key), starlark.String(vals[0]))
+		}
+	}
+
+	pathParams := new(starlark.Dict)
+	for key, value := range params {
+		pathParams.SetKey(starlark.String(key), starlark.String(value))
+	}
+
+	return starlarkstruct.FromStringDict(starlark.String("request"), starlark.StringDict{
+		"basket":  starlark.String(name),
+		"method":  starlark.String(request.Method),
+		"path":    starlark.String(path),
+		"query":   query,
+		"headers": headers,
+		"params":  pathParams,
+		"body":    starlark.String(request.Body)})
+}
+
+// toScriptResponse converts result of handler function: None, string (body) or dict with "status", "headers"
+// and "body" keys; non-string body is encoded as JSON
+func toScriptResponse(thread *starlark.Thread, result starlark.Value) (*scriptResponse, error) {
+	// result is converted to headers and JSON body
+	if err := chargeScriptMemory(thread, scriptValueSize(result, remainingScriptMemory(thread))); err != nil {
+		return nil, err
+	}
+
+	response := &scriptResponse{headers: make(http.Header)}
+	switch value := result.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.String:
+		response.body = []byte(value)
+		return response, nil
+	case *starlark.Dict:
+		var body starlark.Value = starlark.None
+		for _, item := range value.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("unexpected key in result of %s function: %s", scriptHandler, item[0])
+			}
+
+			var err error
+			switch key {
+			case "status":
+				err = starlark.AsInt(item[1], &response.status)
+				if err == nil && (response.status < 100 || response.status >= 600) {
+					err = fmt.Errorf("invalid HTTP status of response: %d", response.status)
+				}
+			case "headers":
+				err = toScriptHeaders(item[1], response.headers)
+			case "body":
+				body = item[1]
+			default:
+				err = fmt.Errorf("unexpected key in result of %s function: %s", scriptHandler, key)
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// body is converted last, so content type of JSON body does not override configured one
+		var err error
+		response.body, err = toScriptBody(thread, body, response.headers)
+		return response, err
+	default:
+		return nil, fmt.Errorf("unexpected result of %s function: %s", scriptHandler, result.Type())
+	}
+}
+
+func toScriptHeaders(value starlark.Value, headers http.Header) error {
+	dict, ok := value.(*starlark.Dict)
+	if !ok {
+		return fmt.Errorf("response headers should be a dict, but was %s", value.Type())
+	}
+
+	for _, item := range dict.Items() {
+		name, ok := starlark.AsString(item[0])
+		if !ok {
+			return fmt.Errorf("invalid response header name: %s", item[0])
+		}
+
+		switch v := item[1].(type) {
+		case starlark.String:
+			headers.Add(name, string(v))
+		case *starlark.List:
+			for i := 0; i < v.Len(); i++ {
+				s, ok := starlark.AsString(v.Index(i))
+				if !ok {
+					return fmt.Errorf("invalid value of response header %s: %s", name, v.Index(i))
+				}
+				headers.Add(name, s)
+			}
+		default:
+			headers.Add(name, v.String())
+		}
+	}
+	return nil
+}
+
+func toScriptBody(thread *starlark.Thread, value starlark.Value, headers http.Header) ([]byte, error) {
+	switch v := value.(type) {
+	case starlark.String:
+		return []byte(v), nil
+	case starlark.Bytes:
+		return []byte(v), nil
+	case starlark.NoneType:
+		return nil, nil
+	}
+
+	encoded, err := starlark.Call(thread, json.Module.Members["encode"], starlark.Tuple{value}, nil)
+	if err != nil {
+		return nil, scriptError(err)
+	}
+	if len(headers.Get("Content-Type")) == 0 {
+		headers.Set("Content-Type", "application/json; charset=UTF-8")
+	}
+	return []byte(encoded.(starlark.String)), nil
+}
+
+// newStateModule creates "state" module that provides response script with access to basket state
+func newStateModule(state Basket) *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "state",
+		Members: starlark.StringDict{
+			"get": starlark.NewBuiltin("state.get", func(thread *starlark.Thread, fn *starlark.Builtin,
+				args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				var key string
+				var def starlark.Value = starlark.None
+				if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "key", &key, "default?", &def); err != nil {
+					return nil, err
+				}
+				if value, exists := state.GetStateValue(key); exists {
+					return starlark.String(value), nil
+				}
+				return def, nil
+			}),
+			"set": starlark.NewBuiltin("state.set", func(thread *starlark.Thread, fn *starlark.Builtin,
+				args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				var key, value string
+				if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "key", &key, "value", &value); err != nil {
+					return nil, err
+				}
+				return starlark.None, state.SetStateValue(key, value)
+			}),
+			"delete": starlark.NewBuiltin("state.delete", func(thread *starlark.Thread, fn *starlark.Builtin,
+				args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				var key string
+				if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "key", &key); err != nil {
+					return nil, err
+				}
+				state.DeleteStateValue(key)
+				return starlark.None, nil
+			}),
+			"keys": starlark.NewBuiltin("state.keys", func(thread *starlark.Thread, fn *starlark.Builtin,
+				args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				if err := starlark.UnpackArgs(fn.Name(), args, kwargs); err != nil {
+					return nil, err
+				}
+				keys := sortedStateKeys(state.GetState())
+				values := make([]starlark.Value, len(keys))
+				for i, key := range keys {
+					values[i] = starlark.String(key)
+				}
+				return starlark.NewList(values), nil
+			})}}
+}
@@ -0,0 +1,649 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// Starlark does not account memory, so response scripts are rewritten before they run: operators, augmented
+// assignments, attributes and slices are evaluated by guards, which charge memory allocated by operation against
+// the budget of script before the value is created
+const (
+	maxScriptMemory = 32 * 1024 * 1024 // 32 MB
+	scriptItemSize  = 16               // size of a single Starlark value in list, tuple or dict
+	scriptMemoryKey = "memory"
+)
+
+// Names of guards in rewritten script, these names can not be used in Starlark source code
+const (
+	scriptOperatorGuard     = "$operator"
+	scriptAugmentedGuard    = "$augmented"
+	scriptAugmentedIndex    = "$augmented_index"
+	scriptAugmentedField    = "$augmented_field"
+	scriptAttrGuard         = "$attr"
+	scriptSliceGuard        = "$slice"
+	scriptMemoryErrorFormat = "memory limit exceeded, response script may not allocate more than %d bytes"
+)
+
+var scriptOperators = map[string]syntax.Token{"+": syntax.PLUS, "*": syntax.STAR, "%": syntax.PERCENT}
+
+var scriptAugmentedOperators = map[syntax.Token]string{syntax.PLUS_EQ: "+", syntax.STAR_EQ: "*",
+	syntax.PERCENT_EQ: "%"}
+
+// scriptSizeFuncs define built-in functions that are replaced by guards: the function estimates memory allocated by
+// the built-in function from its arguments
+var scriptSizeFuncs = map[string]func(args starlark.Tuple, kwargs []starlark.Tuple, limit int) int{
+	"bytes":     scriptArgsSize,
+	"fail":      scriptArgsSize,
+	"print":     scriptArgsSize,
+	"repr":      scriptArgsSize,
+	"str":       scriptArgsSize,
+	"dict":      scriptArgsItems,
+	"enumerate": scriptArgsItems,
+	"list":      scriptArgsItems,
+	"reversed":  scriptArgsItems,
+	"sorted":    scriptArgsItems,
+	"tuple":     scriptArgsItems,
+	"zip":       scriptArgsItems,
+}
+
+// scriptMemory holds memory allocated by response script
+type scriptMemory struct {
+	used int
+}
+
+// scriptGuards returns predeclared values of response script that replace built-in functions and JSON module
+// with their guarded variants and define guards of rewritten script
+func scriptGuards() starlark.StringDict {
+	guards := starlark.StringDict{
+		scriptOperatorGuard:  starlark.NewBuiltin("operator", guardOperator),
+		scriptAugmentedGuard: starlark.NewBuiltin("augmented assignment", guardAugmented),
+		scriptAugmentedIndex: starlark.NewBuiltin("augmented assignment", guardAugmentedIndex),
+		scriptAugmentedField: starlark.NewBuiltin("augmented assignment", guardAugmentedField),
+		scriptAttrGuard:      starlark.NewBuiltin("attribute", guardAttr),
+		scriptSliceGuard:     starlark.NewBuiltin("slice", guardSlice),
+		"getattr":            starlark.NewBuiltin("getattr", guardGetAttr),
+		"json": &starlarkstruct.Module{Name: "json", Members: starlark.StringDict{
+			"encode": guardBuiltin(json.Module.Members["encode"].(*starlark.Builtin), scriptArgsSize),
+			"indent": guardBuiltin(json.Module.Members["indent"].(*starlark.Builtin), scriptArgsSize),
+			"decode": guardBuiltin(json.Module.Members["decode"].(*starlark.Builtin), scriptArgsSize),
+		}},
+	}
+	for name, size := range scriptSizeFuncs {
+		guards[name] = guardBuiltin(starlark.Universe[name].(*starlark.Builtin), size)
+	}
+	return guards
+}
+
+// chargeScriptMemory charges memory allocated by response script, fails if the memory limit is exceeded
+func chargeScriptMemory(thread *starlark.Thread, size int) error {
+	memory, ok := thread.Local(scriptMemoryKey).(*scriptMemory)
+	if !ok || size <= 0 {
+		return nil
+	}
+
+	memory.used += size
+	if memory.used > maxScriptMemory {
+		return fmt.Errorf(scriptMemoryErrorFormat, maxScriptMemory)
+	}
+	return nil
+}
+
+// remainingScriptMemory returns memory that response script may allocate
+func remainingScriptMemory(thread *starlark.Thread) int {
+	if memory, ok := thread.Local(scriptMemoryKey).(*scriptMemory); ok {
+		return maxScriptMemory - memory.used
+	}
+	return maxScriptMemory
+}
+
+// scriptValueSize estimates memory needed to represent the value as a string, e.g. by str() or json.encode();
+// estimation stops as soon as the limit is exceeded
+func scriptValueSize(value starlark.Value, limit int) int {
+	size := 0
+	pending := []starlark.Value{value}
+	for len(pending) > 0 && size <= limit {
+		value = pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		switch v := value.(type) {
+		case starlark.String:
+			size += len(v)
+		case starlark.Bytes:
+			size += len(v)
+		case starlark.Indexable:
+			for i := 0; i < v.Len() && size <= limit; i++ {
+				size += scriptItemSize
+				pending = append(pending, v.Index(i))
+			}
+		case starlark.IterableMapping:
+			for _, item := range v.Items() {
+				size += 2 * scriptItemSize
+				pending = append(pending, item[0], item[1])
+			}
+		case *starlarkstruct.Struct:
+			for _, name := range v.AttrNames() {
+				attr, _ := v.Attr(name)
+				size += len(name) + scriptItemSize
+				pending = append(pending, attr)
+			}
+		default:
+			size += scriptItemSize
+		}
+	}
+
+	return size
+}
+
+// scriptAllocatedSize returns memory allocated for a new string or container, elements of container are not
+// copied and therefore not counted
+func scriptAllocatedSize(value starlark.Value) int {
+	switch v := value.(type) {
+	case starlark.String:
+		return len(v)
+	case starlark.Bytes:
+		return len(v)
+	case starlark.Int:
+		return scriptIntSize(v)
+	case starlark.Sequence:
+		return v.Len() * scriptItemSize
+	}
+	return 0
+}
+
+func scriptIntSize(value starlark.Int) int {
+	if _, ok := value.Int64(); ok {
+		return 0
+	}
+	return value.BigInt().BitLen() / 8
+}
+
+func scriptArgsSize(args starlark.Tuple, kwargs []starlark.Tuple, limit int) int {
+	size := scriptValueSize(args, limit)
+	for _, kwarg := range kwargs {
+		size += scriptValueSize(kwarg[1], limit)
+	}
+	return size
+}
+
+func scriptArgsItems(args starlark.Tuple, kwargs []starlark.Tuple, limit int) int {
+	items := 0
+	for _, arg := range args {
+		if n := starlark.Len(arg); n > 0 {
+			items += n
+		}
+	}
+	return items * scriptItemSize
+}
+
+// guardBuiltin charges memory estimated from arguments of built-in function before it is called
+func guardBuiltin(builtin *starlark.Builtin,
+	size func(args starlark.Tuple, kwargs []starlark.Tuple, limit int) int) *starlark.Builtin {
+	return starlark.NewBuiltin(builtin.Name(), func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+		kwargs []starlark.Tuple) (starlark.Value, error) {
+		if err := chargeScriptMemory(thread, size(args, kwargs, remainingScriptMemory(thread))); err != nil {
+			return nil, err
+		}
+		return builtin.CallInternal(thread, args, kwargs)
+	})
+}
+
+// operatorSize estimates memory allocated by binary operator
+func operatorSize(op syntax.Token, x starlark.Value, y starlark.Value, limit int) int {
+	switch op {
+	case syntax.PLUS:
+		return scriptAllocatedSize(x) + scriptAllocatedSize(y)
+	case syntax.STAR:
+		xi, xint := x.(starlark.Int)
+		yi, yint := y.(starlark.Int)
+		switch {
+		case xint && yint:
+			return scriptIntSize(xi) + scriptIntSize(yi)
+		case yint:
+			return scriptRepeatSize(x, yi)
+		case xint:
+			return scriptRepeatSize(y, xi)
+		}
+	case syntax.PERCENT:
+		if format, ok := x.(starlark.String); ok {
+			return len(format) + strings.Count(string(format), "%")*scriptValueSize(y, limit)
+		}
+	}
+	return 0
+}
+
+func scriptRepeatSize(value starlark.Value, count starlark.Int) int {
+	n, err := starlark.AsInt32(count)
+	if err != nil || n <= 0 {
+		// invalid repeat count is reported by operator itself
+		return 0
+	}
+	return scriptAllocatedSize(value) * n
+}
+
+// evalOperator charges memory allocated by binary operator and evaluates it, lists are extended in place by "+="
+func evalOperator(thread *starlark.Thread, op string, x starlark.Value, y starlark.Value,
+	augmented bool) (starlark.Value, error) {
+	token, ok := scriptOperators[op]
+	if !ok {
+		return nil, fmt.Errorf("unexpected operator: %s", op)
+	}
+
+	if list, ok := x.(*starlark.List); ok && augmented && token == syntax.PLUS {
+		if iterable, ok := y.(starlark.Iterable); ok {
+			if err := chargeScriptMemory(thread, scriptAllocatedSize(y)); err != nil {
+				return nil, err
+			}
+			iter := iterable.Iterate()
+			defer iter.Done()
+			var elem starlark.Value
+			for iter.Next(&elem) {
+				if err := list.Append(elem); err != nil {
+					return nil, err
+				}
+			}
+			return list, nil
+		}
+	}
+
+	if err := chargeScriptMemory(thread, operatorSize(token, x, y, remainingScriptMemory(thread))); err != nil {
+		return nil, err
+	}
+	return starlark.Binary(token, x, y)
+}
+
+// guardOperator evaluates binary operator: $operator(op, x, y)
+func guardOperator(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+	kwargs []starlark.Tuple) (starlark.Value, error) {
+	var op string
+	var x, y starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 3, &op, &x, &y); err != nil {
+		return nil, err
+	}
+	return evalOperator(thread, op, x, y, false)
+}
+
+// guardAugmented evaluates augmented assignment to variable: x = $augmented(op, x, y)
+func guardAugmented(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+	kwargs []starlark.Tuple) (starlark.Value, error) {
+	var op string
+	var x, y starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 3, &op, &x, &y); err != nil {
+		return nil, err
+	}
+	return evalOperator(thread, op, x, y, true)
+}
+
+// guardAugmentedIndex evaluates augmented assignment to element: $augmented_index(op, x, index, y)
+func guardAugmentedIndex(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+	kwargs []starlark.Tuple) (starlark.Value, error) {
+	var op string
+	var x, index, y starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 4, &op, &x, &index, &y); err != nil {
+		return nil, err
+	}
+
+	switch v := x.(type) {
+	case starlark.HasSetKey:
+		elem, found, err := v.Get(index)
+		if err != nil {
+			return nil, err
+		} else if !found {
+			return nil, fmt.Errorf("key %v not in %s", index, x.Type())
+		}
+		if elem, err = evalOperator(thread, op, elem, y, true); err != nil {
+			return nil, err
+		}
+		return starlark.None, v.SetKey(index, elem)
+	case starlark.HasSetIndex:
+		i, err := starlark.AsInt32(index)
+		if err != nil {
+			return nil, fmt.Errorf("%s index: %s", x.Type(), err)
+		}
+		if i < 0 {
+			i += v.Len()
+		}
+		if i < 0 || i >= v.Len() {
+			return nil, fmt.Errorf("%s index %v out of range [%d:%d]", x.Type(), index, -v.Len(), v.Len()-1)
+		}
+		elem, err := evalOperator(thread, op, v.Index(i), y, true)
+		if err != nil {
+			return nil, err
+		}
+		return starlark.None, v.SetIndex(i, elem)
+	}
+	return nil, fmt.Errorf("%s value does not support item assignment", x.Type())
+}
+
+// guardAugmentedField evaluates augmented assignment to field: $augmented_field(op, x, name, y)
+func guardAugmentedField(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+	kwargs []starlark.Tuple) (starlark.Value, error) {
+	var op, name string
+	var x, y starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 4, &op, &x, &name, &y); err != nil {
+		return nil, err
+	}
+
+	v, ok := x.(starlark.HasSetField)
+	if !ok {
+		return nil, fmt.Errorf("can't assign to .%s field of %s", name, x.Type())
+	}
+	field, err := v.Attr(name)
+	if err != nil {
+		return nil, err
+	} else if field == nil {
+		return nil, fmt.Errorf("%s has no .%s field", x.Type(), name)
+	}
+	if field, err = evalOperator(thread, op, field, y, true); err != nil {
+		return nil, err
+	}
+	return starlark.None, v.SetField(name, field)
+}
+
+// guardAttr returns attribute of value, methods of strings, lists and dicts are guarded: $attr(x, name)
+func guardAttr(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+	kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var x starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &x, &name); err != nil {
+		return nil, err
+	}
+
+	attr, err := getScriptAttr(x, name)
+	if err != nil {
+		return nil, err
+	} else if attr == nil {
+		return nil, fmt.Errorf("%s has no .%s field or method", x.Type(), name)
+	}
+	return attr, nil
+}
+
+// guardGetAttr replaces getattr built-in function, so methods are guarded when accessed by name
+func guardGetAttr(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+	kwargs []starlark.Tuple) (starlark.Value, error) {
+	var name string
+	var x, dflt starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &x, &name, &dflt); err != nil {
+		return nil, err
+	}
+
+	attr, err := getScriptAttr(x, name)
+	if err != nil || attr != nil {
+		return attr, err
+	} else if dflt != nil {
+		return dflt, nil
+	}
+	return nil, fmt.Errorf("getattr: %s has no .%s field or method", x.Type(), name)
+}
+
+func getScriptAttr(x starlark.Value, name string) (starlark.Value, error) {
+	v, ok := x.(starlark.HasAttrs)
+	if !ok {
+		return nil, nil
+	}
+
+	attr, err := v.Attr(name)
+	if method, ok := attr.(*starlark.Builtin); ok && method.Receiver() != nil {
+		return guardMethod(method), err
+	}
+	return attr, err
+}
+
+// guardMethod charges memory allocated by built-in method: string methods that may produce strings larger than
+// their arguments are checked before they are called, results of other string methods are charged afterwards
+func guardMethod(method *starlark.Builtin) *starlark.Builtin {
+	return starlark.NewBuiltin(method.Name(), func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+		kwargs []starlark.Tuple) (starlark.Value, error) {
+		size, checked := methodSize(method.Receiver(), method.Name(), args, remainingScriptMemory(thread))
+		if err := chargeScriptMemory(thread, size); err != nil {
+			return nil, err
+		}
+
+		result, err := method.CallInternal(thread, args, kwargs)
+		if err == nil && !checked {
+			err = chargeScriptMemory(thread, scriptAllocatedSize(result))
+		}
+		return result, err
+	})
+}
+
+// methodSize estimates memory allocated by method before it is called, returns false if memory should be charged
+// by result of method
+func methodSize(recv starlark.Value, name string, args starlark.Tuple, limit int) (int, bool) {
+	switch recv := recv.(type) {
+	case starlark.String:
+		s := string(recv)
+		switch name {
+		case "join":
+			if len(args) == 1 {
+				return scriptValueSize(args[0], limit) + len(s)*scriptArgsItems(args, nil, limit)/scriptItemSize, true
+			}
+		case "replace":
+			if len(args) >= 2 {
+				old, _ := starlark.AsString(args[0])
+				new, _ := starlark.AsString(args[1])
+				count := strings.Count(s, old)
+				if len(args) == 3 {
+					if n, err := starlark.AsInt32(args[2]); err == nil && n >= 0 && n < count {
+						count = n
+					}
+				}
+				return len(s) + count*len(new), true
+			}
+		case "format":
+			return len(s) + strings.Count(s, "{")*scriptValueSize(args, limit), true
+		}
+		return 0, false
+	case starlark.Bytes:
+		return 0, false
+	case *starlark.List:
+		switch name {
+		case "append", "insert":
+			return scriptItemSize, true
+		case "extend":
+			return scriptArgsItems(args, nil, limit), true
+		}
+	case *starlark.Dict:
+		switch name {
+		case "items", "keys", "values":
+			return 0, false
+		case "setdefault":
+			return 2 * scriptItemSize, true
+		case "update":
+			return 2 * scriptArgsItems(args, nil, limit), true
+		}
+	}
+	return 0, true
+}
+
+// guardSlice charges memory allocated by slice of list or tuple: $slice(x)[lo:hi:step], slices of strings share
+// memory of the sliced string
+func guardSlice(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+	kwargs []starlark.Tuple) (starlark.Value, error) {
+	var x starlark.Value
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &x); err != nil {
+		return nil, err
+	}
+
+	switch x.(type) {
+	case *starlark.List, starlark.Tuple:
+		return x, chargeScriptMemory(thread, scriptAllocatedSize(x))
+	}
+	return x, nil
+}
+
+// guardScript rewrites syntax tree of response script, so operations that may allocate memory are evaluated by guards
+func guardScript(file *syntax.File) {
+	file.Stmts = guardStmts(file.Stmts)
+}
+
+func guardStmts(stmts []syntax.Stmt) []syntax.Stmt {
+	for i, stmt := range stmts {
+		stmts[i] = guardStmt(stmt)
+	}
+	return stmts
+}
+
+func guardStmt(stmt syntax.Stmt) syntax.Stmt {
+	switch s := stmt.(type) {
+	case *syntax.AssignStmt:
+		s.RHS = guardExpr(s.RHS)
+		op, augmented := scriptAugmentedOperators[s.Op]
+		if !augmented {
+			s.LHS = guardTarget(s.LHS)
+			return s
+		}
+
+		lhs := s.LHS
+		for paren, ok := lhs.(*syntax.ParenExpr); ok; paren, ok = lhs.(*syntax.ParenExpr) {
+			lhs = paren.X
+		}
+		switch target := lhs.(type) {
+		case *syntax.Ident:
+			// x op= y  ->  x = $augmented(op, x, y)
+			return &syntax.AssignStmt{OpPos: s.OpPos, Op: syntax.EQ, LHS: target, RHS: guardCall(scriptAugmentedGuard,
+				s.OpPos, scriptLiteral(op, s.OpPos), &syntax.Ident{NamePos: target.NamePos, Name: target.Name}, s.RHS)}
+		case *syntax.IndexExpr:
+			// x[i] op= y  ->  $augmented_index(op, x, i, y)
+			return &syntax.ExprStmt{X: guardCall(scriptAugmentedIndex, s.OpPos, scriptLiteral(op, s.OpPos),
+				guardExpr(target.X), guardExpr(target.Y), s.RHS)}
+		case *syntax.DotExpr:
+			// x.f op= y  ->  $augmented_field(op, x, "f", y)
+			return &syntax.ExprStmt{X: guardCall(scriptAugmentedField, s.OpPos, scriptLiteral(op, s.OpPos),
+				guardExpr(target.X), scriptLiteral(target.Name.Name, target.NamePos), s.RHS)}
+		}
+		// invalid targets are reported by resolver
+		return s
+	case *syntax.ExprStmt:
+		s.X = guardExpr(s.X)
+	case *syntax.ReturnStmt:
+		s.Result = guardExpr(s.Result)
+	case *syntax.IfStmt:
+		s.Cond = guardExpr(s.Cond)
+		s.True = guardStmts(s.True)
+		s.False = guardStmts(s.False)
+	case *syntax.ForStmt:
+		s.Vars = guardTarget(s.Vars)
+		s.X = guardExpr(s.X)
+		s.Body = guardStmts(s.Body)
+	case *syntax.WhileStmt:
+		s.Cond = guardExpr(s.Cond)
+		s.Body = guardStmts(s.Body)
+	case *syntax.DefStmt:
+		s.Params = guardExprs(s.Params)
+		s.Body = guardStmts(s.Body)
+	}
+	return stmt
+}
+
+// guardTarget rewrites expressions evaluated by assignment target, while the target itself is kept
+func guardTarget(expr syntax.Expr) syntax.Expr {
+	switch e := expr.(type) {
+	case *syntax.IndexExpr:
+		e.X = guardExpr(e.X)
+		e.Y = guardExpr(e.Y)
+	case *syntax.DotExpr:
+		e.X = guardExpr(e.X)
+	case *syntax.ParenExpr:
+		e.X = guardTarget(e.X)
+	case *syntax.TupleExpr:
+		for i, elem := range e.List {
+			e.List[i] = guardTarget(elem)
+		}
+	case *syntax.ListExpr:
+		for i, elem := range e.List {
+			e.List[i] = guardTarget(elem)
+		}
+	}
+	return expr
+}
+
+func guardExprs(exprs []syntax.Expr) []syntax.Expr {
+	for i, expr := range exprs {
+		exprs[i] = guardExpr(expr)
+	}
+	return exprs
+}
+
+func guardExpr(expr syntax.Expr) syntax.Expr {
+	switch e := expr.(type) {
+	case nil:
+		return nil
+	case *syntax.BinaryExpr:
+		e.X = guardExpr(e.X)
+		e.Y = guardExpr(e.Y)
+		op := e.Op.String()
+		if scriptOperators[op] != 0 && (e.Op == syntax.STAR || !isNumberLiteral(e.X) && !isNumberLiteral(e.Y)) {
+			// x op y  ->  $operator(op, x, y)
+			return guardCall(scriptOperatorGuard, e.OpPos, scriptLiteral(op, e.OpPos), e.X, e.Y)
+		}
+	case *syntax.DotExpr:
+		// x.f  ->  $attr(x, "f")
+		return guardCall(scriptAttrGuard, e.Dot, guardExpr(e.X), scriptLiteral(e.Name.Name, e.NamePos))
+	case *syntax.SliceExpr:
+		// x[lo:hi:step]  ->  $slice(x)[lo:hi:step]
+		e.X = guardCall(scriptSliceGuard, e.Lbrack, guardExpr(e.X))
+		e.Lo = guardExpr(e.Lo)
+		e.Hi = guardExpr(e.Hi)
+		e.Step = guardExpr(e.Step)
+	case *syntax.CallExpr:
+		e.Fn = guardExpr(e.Fn)
+		e.Args = guardExprs(e.Args)
+	case *syntax.IndexExpr:
+		e.X = guardExpr(e.X)
+		e.Y = guardExpr(e.Y)
+	case *syntax.UnaryExpr:
+		e.X = guardExpr(e.X)
+	case *syntax.ParenExpr:
+		e.X = guardExpr(e.X)
+	case *syntax.CondExpr:
+		e.Cond = guardExpr(e.Cond)
+		e.True = guardExpr(e.True)
+		e.False = guardExpr(e.False)
+	case *syntax.TupleExpr:
+		e.List = guardExprs(e.List)
+	case *syntax.ListExpr:
+		e.List = guardExprs(e.List)
+	case *syntax.DictExpr:
+		e.List = guardExprs(e.List)
+	case *syntax.DictEntry:
+		e.Key = guardExpr(e.Key)
+		e.Value = guardExpr(e.Value)
+	case *syntax.LambdaExpr:
+		e.Params = guardExprs(e.Params)
+		e.Body = guardExpr(e.Body)
+	case *syntax.Comprehension:
+		e.Body = guardExpr(e.Body)
+		for _, clause := range e.Clauses {
+			switch c := clause.(type) {
+			case *syntax.ForClause:
+				c.Vars = guardTarget(c.Vars)
+				c.X = guardExpr(c.X)
+			case *syntax.IfClause:
+				c.Cond = guardExpr(c.Cond)
+			}
+		}
+	}
+	return expr
+}
+
+// isNumberLiteral checks if operand is a number literal, "+" and "%" operators with such operand never allocate
+// strings or lists
+func isNumberLiteral(expr syntax.Expr) bool {
+	literal, ok := expr.(*syntax.Literal)
+	return ok && (literal.Token == syntax.INT || literal.Token == syntax.FLOAT)
+}
+
+func guardCall(guard string, pos syntax.Position, args ...syntax.Expr) *syntax.CallExpr {
+	return &syntax.CallExpr{Fn: &syntax.Ident{NamePos: pos, Name: guard}, Lparen: pos, Args: args, Rparen: pos}
+}
+
+func scriptLiteral(value string, pos syntax.Position) *syntax.Literal {
+	return &syntax.Literal{Token: syntax.STRING, TokenPos: pos, Raw: strconv.Quote(value), Value: value}
+}
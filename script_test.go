@@ -0,0 +1,230 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ordersScript = `
+def handle(request):
+    if request.method == "POST":
+        order = json.decode(request.body)
+        count = int(state.get("count", "0")) + 1
+        state.set("count", str(count))
+        order["id"] = count
+        state.set("order-%d" % count, json.encode(order))
+        return {"status": 201, "headers": {"Location": "/orders/%d" % count}, "body": order}
+
+    order = state.get("order-" + request.params.get("id", ""))
+    if order == None:
+        return {"status": 404, "body": {"error": "order not found"}}
+    return {"headers": {"Content-Type": "application/json"}, "body": order}
+`
+
+func TestValidateResponseScript(t *testing.T) {
+	assert.NoError(t, validateResponseScript("def handle(request):\n    return 'ok'\n"))
+	assert.NoError(t, validateResponseScript(ordersScript))
+
+	err := validateResponseScript("def handle(request):\n    return undefined_var\n")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "undefined: undefined_var")
+	}
+
+	err = validateResponseScript("x = 1\n")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "function handle(request) is not defined")
+	}
+
+	assert.Error(t, validateResponseScript("def handle():\n    pass\n"))
+	assert.Error(t, validateResponseScript("def handle(request)\n"))
+	assert.Error(t, validateResponseScript("load('other.star', 'x')\ndef handle(request):\n    return x\n"))
+	assert.Error(t, validateResponseScript(strings.Repeat("#", maxScriptSize+1)))
+}
+
+func TestRunResponseScript(t *testing.T) {
+	basketsDb.Create("script02", BasketConfig{Capacity: 20})
+	state := basketsDb.Get("script02")
+	request := &RequestData{Method: "GET", Query: "name=Joe", Header: http.Header{"X-Token": {"abc"}}, Body: ""}
+
+	script := `
+def handle(request):
+    return "%s %s %s %s %s" % (request.basket, request.method, request.path, request.query["name"], request.headers["X-Token"])
+`
+	response, err := runResponseScript(script, request, "demo", "/hello", nil, state)
+	if assert.NoError(t, err) && assert.NotNil(t, response) {
+		assert.Equal(t, 0, response.status)
+		assert.Equal(t, "demo GET /hello Joe abc", string(response.body))
+	}
+
+	// configured response is kept
+	response, err = runResponseScript("def handle(request):\n    pass\n", request, "demo", "/", nil, state)
+	assert.NoError(t, err)
+	assert.Nil(t, response)
+
+	// headers with multiple values and explicit content type
+	script = `
+def handle(request):
+    return {"body": [1, 2], "headers": {"Content-Type": "application/vnd.api+json", "X-Id": ["1", "2"]}, "status": 200}
+`
+	response, err = runResponseScript(script, request, "demo", "/", nil, state)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "[1,2]", string(response.body))
+		assert.Equal(t, []string{"application/vnd.api+json"}, response.headers["Content-Type"])
+		assert.Equal(t, []string{"1", "2"}, response.headers["X-Id"])
+	}
+
+	// errors
+	_, err = runResponseScript("def handle(request):\n    return 1 // 0\n", request, "demo", "/", nil, state)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "floored division by zero")
+	}
+
+	_, err = runResponseScript("def handle(request):\n    return 42\n", request, "demo", "/", nil, state)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unexpected result of handle function: int")
+	}
+
+	_, err = runResponseScript("def handle(request):\n    return {\"status\": 99}\n", request, "demo", "/", nil, state)
+	assert.Error(t, err)
+
+	_, err = runResponseScript("def handle(request):\n    return {\"code\": 200}\n", request, "demo", "/", nil, state)
+	assert.Error(t, err)
+}
+
+func TestRunResponseScript_Limits(t *testing.T) {
+	basketsDb.Create("script03", BasketConfig{Capacity: 20})
+	state := basketsDb.Get("script03")
+	request := &RequestData{Method: "GET", Header: http.Header{}}
+
+	script := `
+def handle(request):
+    total = 0
+    for i in range(100000000):
+        total += i
+    return str(total)
+`
+	_, err := runResponseScript(script, request, "demo", "/", nil, state)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "too many steps")
+	}
+
+	// recursion is not permitted
+	_, err = runResponseScript("def handle(request):\n    return handle(request)\n", request, "demo", "/", nil, state)
+	assert.Error(t, err)
+
+	_, err = runResponseScript("def handle(request):\n    state.set('', 'x')\n", request, "demo", "/", nil, state)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "state key should have length")
+	}
+}
+
+func TestRunResponseScript_MemoryLimit(t *testing.T) {
+	basketsDb.Create("script05", BasketConfig{Capacity: 20})
+	state := basketsDb.Get("script05")
+	request := &RequestData{Method: "GET", Header: http.Header{}, Body: strings.Repeat("x", 1024)}
+
+	scripts := map[string]string{
+		"repeat":        "def handle(request):\n    return 'x' * 1000000000\n",
+		"repeat list":   "def handle(request):\n    return len([0] * 100000000)\n",
+		"doubling":      "def handle(request):\n    s = request.body\n    for i in range(40):\n        s += s\n    return s\n",
+		"index":         "def handle(request):\n    d = {'s': request.body}\n    for i in range(40):\n        d['s'] *= 2\n    return d['s']\n",
+		"retained":      "def handle(request):\n    keep = []\n    for i in range(100000):\n        keep.append(request.body + str(i))\n    return str(len(keep))\n",
+		"join":          "def handle(request):\n    return request.body.join([request.body] * 100000)\n",
+		"method value":  "def handle(request):\n    join = request.body.join\n    return join([request.body] * 100000)\n",
+		"getattr":       "def handle(request):\n    body = request.body * 10\n    return getattr(body, 'replace')('', body)\n",
+		"format":        "def handle(request):\n    return ('{0}' * 10000).format(request.body * 100)\n",
+		"percent":       "def handle(request):\n    return ('%s' * 100000) % tuple([request.body] * 100000)\n",
+		"str":           "def handle(request):\n    return str([request.body] * 1000000)\n",
+		"list of range": "def handle(request):\n    return str(len(list(range(100000000))))\n",
+		"json body":     "def handle(request):\n    return {'body': [request.body] * 1000000}\n",
+	}
+	for name, script := range scripts {
+		_, err := runResponseScript(script, request, "demo", "/", nil, state)
+		if assert.Error(t, err, "memory limit is expected for script: %s", name) {
+			assert.Contains(t, err.Error(), "memory limit exceeded", "wrong error of script: %s", name)
+		}
+	}
+
+	// semantics of guarded operations is kept
+	script := `
+def handle(request):
+    items = [1]
+    alias = items
+    items += [2, 3]
+    counts = {"a": 1}
+    counts["a"] += 2
+    pairs = [[0], [1]]
+    pairs[-1] += [2]
+    text = "%s-%d" % ("a", 1) + "b" * 2
+    text += "/" + ",".join(["x", "y"]).replace(",", ";") + "{}".format(1)
+    return "%s %s %s %s %s" % (alias, counts["a"], pairs, text, items[1:] + [total(3)])
+
+def total(n):
+    result = 0
+    for i in range(n):
+        result += i * 2
+    return result
+`
+	response, err := runResponseScript(script, request, "demo", "/", nil, state)
+	if assert.NoError(t, err) && assert.NotNil(t, response) {
+		assert.Equal(t, "[1, 2, 3] 3 [[0], [1, 2]] a-1bb/x;y1 [2, 3, 6]", string(response.body))
+	}
+
+	_, err = runResponseScript("def handle(request):\n    request.body += 'x'\n", request, "demo", "/", nil, state)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "can't assign to .body field of struct")
+	}
+}
+
+func TestAcceptBasketRequests_Script(t *testing.T) {
+	basket := "script01"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	b := basketsDb.Get(basket)
+	b.SetPathResponse("POST", "/orders", ResponseConfig{Status: 200, Script: ordersScript})
+	b.SetPathResponse("GET", "/orders/:id", ResponseConfig{Status: 200, Script: ordersScript})
+
+	// create order
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket+"/orders", strings.NewReader("{\"item\":\"book\"}"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 201, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "/orders/1", w.Header().Get("Location"), "wrong Location header")
+		assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+		assert.JSONEq(t, `{"id": 1, "item": "book"}`, w.Body.String())
+	}
+
+	// fetch order
+	r, err = http.NewRequest("GET", "http://localhost:55555/r/"+basket+"/orders/1", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+		assert.JSONEq(t, `{"id": 1, "item": "book"}`, w.Body.String())
+	}
+
+	// unknown order
+	r, err = http.NewRequest("GET", "http://localhost:55555/r/"+basket+"/orders/7", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+		assert.Equal(t, 404, w.Code, "wrong HTTP response code")
+	}
+
+	// script error
+	r, err = http.NewRequest("POST", "http://localhost:55555/r/"+basket+"/orders", strings.NewReader("not a json"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 500, w.Code, "wrong HTTP response code")
+		assert.Contains(t, w.Body.String(), "Error in script:", "wrong HTTP response body")
+		assert.Contains(t, w.Body.String(), "json.decode", "wrong HTTP response body")
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,6 +14,7 @@ import (
 var basketsDb BasketsDatabase
 var httpClient *http.Client
 var httpInsecureClient *http.Client
+var httpClientDefaults HTTPClientConfig
 var version *Version
 
 // CreateServer creates an instance of Request Baskets server
@@ -28,6 +28,7 @@ func CreateServer(config *ServerConfig) *http.Server {
 
 	log.Printf("[info] service version: %s from commit: %s (%s)", version.Version, version.CommitShort, version.Commit)
 	// create database
+	autoMigrateSchema = config.AutoMigrate
 	db := createBasketsDatabase(config.DbType, config.DbFile, config.DbConnection)
 	if db == nil {
 		log.Print("[error] failed to create basket database")
@@ -38,9 +39,25 @@ func CreateServer(config *ServerConfig) *http.Server {
 	basketsDb = db
 
 	// HTTP clients
-	httpClient = new(http.Client)
-	insecureTransport := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
-	httpInsecureClient = &http.Client{Transport: insecureTransport}
+	if err := validateEgressPolicy(config.Egress); err != nil {
+		log.Printf("[error] invalid egress policy - %s", err)
+		return nil
+	}
+	egressPolicy = config.Egress
+	operatorProxies = operatorProxyAddresses(config.HTTPClient.Proxy)
+	httpClientDefaults = config.HTTPClient
+	var err error
+	if httpClient, err = newHTTPClient(httpClientDefaults, false); err != nil {
+		log.Printf("[error] failed to create HTTP client - %s", err)
+		return nil
+	}
+	if httpInsecureClient, err = newHTTPClient(httpClientDefaults, true); err != nil {
+		log.Printf("[error] failed to create HTTP client - %s", err)
+		return nil
+	}
+
+	// pending retries of failed forwards
+	resumeForwardRetries(db)
 
 	// configure service HTTP router
 	pathPrefix := getPathPrefix(config)
@@ -71,11 +88,39 @@ func CreateServer(config *ServerConfig) *http.Server {
 	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket", CreateBasket)
 	router.PUT(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket", UpdateBasket)
 	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket", DeleteBasket)
+	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/openapi", CreateBasketFromOpenAPI)
+	router.PUT(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/openapi", ImportBasketOpenAPI)
 	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method", GetBasketResponse)
 	router.PUT(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method", UpdateBasketResponse)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method", DeleteBasketResponse)
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method/payload", GetBasketResponsePayload)
+	router.PUT(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method/payload", UpdateBasketResponsePayload)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method/payload", DeleteBasketResponsePayload)
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/responses/:method/paths", GetBasketPathResponses)
+	// state management
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/state", GetBasketState)
+	router.PUT(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/state", UpdateBasketState)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/state", DeleteBasketState)
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/state/:key", GetBasketStateValue)
+	router.PUT(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/state/:key", UpdateBasketStateValue)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/state/:key", DeleteBasketStateValue)
+	// forward retries management
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/retries", GetBasketRetries)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/retries/:id", DeleteBasketRetry)
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/dead_letters", GetBasketDeadLetters)
+	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/dead_letters", RedriveBasketDeadLetters)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/dead_letters", DeleteBasketDeadLetters)
+	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/dead_letters/:id", RedriveBasketDeadLetter)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/dead_letters/:id", DeleteBasketDeadLetter)
+	// WebSocket sessions
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/websockets", GetBasketWebSockets)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/websockets", DeleteBasketWebSockets)
+	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/websockets/:id", GetBasketWebSocket)
+	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/websockets/:id", DeleteBasketWebSocket)
 	// requests management
 	router.GET(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests", GetBasketRequests)
 	router.DELETE(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests", ClearBasket)
+	router.POST(pathPrefix+"/"+serviceAPIPath+"/baskets/:basket/requests/:id/replay", ReplayBasketRequest)
 
 	// web pages
 	router.GET(pathPrefix+"/", ForwardToWeb)
@@ -138,13 +183,6 @@ func shutdownHook() {
 	os.Exit(0)
 }
 
-func getHTTPClient(insecure bool) *http.Client {
-	if insecure {
-		return httpInsecureClient
-	}
-	return httpClient
-}
-
 func createDefaultBaskets(db BasketsDatabase, baskets []string) {
 	for _, basket := range baskets {
 		createDefaultBasket(db, basket)
@@ -20,6 +20,8 @@ func TestMain(m *testing.M) {
 func testsSetup() {
 	// global config
 	serverConfig = CreateConfig()
+	// test targets are served on loopback interface
+	serverConfig.Egress.Allow = []string{"127.0.0.0/8", "::1"}
 	// global server creation with default settings (performs some global initialization)
 	testServer = CreateServer(serverConfig)
 }
@@ -27,6 +29,10 @@ func testsSetup() {
 func testsShutdown() {
 	// release global DB
 	basketsDb.Release()
+	// remove database file of SQLite tests
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		os.Remove(sqliteTestFile + suffix)
+	}
 }
 
 func TestCreateServer(t *testing.T) {
@@ -63,6 +69,11 @@ func TestCreateBasketsDatabase(t *testing.T) {
 		sqldbconn.Release()
 	}
 
+	sqlitedb := createBasketsDatabase(DbTypeSQL, "./baskets.db", sqliteTestConnection)
+	if assert.NotNil(t, sqlitedb, "SQLite database is expected") {
+		sqlitedb.Release()
+	}
+
 	assert.Nil(t, createBasketsDatabase("xyz", "./xyz", ""), "Database of unknown type is not expected")
 }
 
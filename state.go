@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Limits of basket state
+const (
+	maxStateKeys      = 1000
+	maxStateKeyLength = 250
+	maxStateValueSize = 64 * 1024
+
+	maxStateRequestSize = 1024 * 1024
+)
+
+// validateStateEntry validates key and value of basket state entry
+func validateStateEntry(key string, value string) error {
+	if len(key) == 0 || len(key) > maxStateKeyLength {
+		return fmt.Errorf("state key should have length within [1, %d] range, but was %d", maxStateKeyLength, len(key))
+	}
+	if len(value) > maxStateValueSize {
+		return fmt.Errorf("state value may not be larger than %d bytes", maxStateValueSize)
+	}
+	return nil
+}
+
+// validateState validates complete basket state
+func validateState(state map[string]string) error {
+	if len(state) > maxStateKeys {
+		return fmt.Errorf("basket state may not have more than %d keys", maxStateKeys)
+	}
+	for key, value := range state {
+		if err := validateStateEntry(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkStateLimit verifies that a new state entry can be added to basket state with the given number of keys
+func checkStateLimit(size int, exists bool) error {
+	if !exists && size >= maxStateKeys {
+		return fmt.Errorf("basket state may not have more than %d keys", maxStateKeys)
+	}
+	return nil
+}
+
+// sortedStateKeys lists keys of basket state in sorted order
+func sortedStateKeys(state map[string]string) []string {
+	keys := make([]string, 0, len(state))
+	for key := range state {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateState(t *testing.T) {
+	assert.NoError(t, validateState(map[string]string{"a": "1", "order-1": "{}"}))
+	assert.Error(t, validateState(map[string]string{"": "1"}))
+	assert.Error(t, validateState(map[string]string{strings.Repeat("k", maxStateKeyLength+1): "1"}))
+	assert.Error(t, validateState(map[string]string{"a": strings.Repeat("v", maxStateValueSize+1)}))
+
+	state := make(map[string]string)
+	for i := 0; i <= maxStateKeys; i++ {
+		state[fmt.Sprintf("key%d", i)] = "value"
+	}
+	assert.Error(t, validateState(state))
+
+	assert.NoError(t, checkStateLimit(maxStateKeys, true))
+	assert.Error(t, checkStateLimit(maxStateKeys, false))
+	assert.Equal(t, []string{"a", "b"}, sortedStateKeys(map[string]string{"b": "", "a": ""}))
+}
+
+func TestBasketState_API(t *testing.T) {
+	basket := "state01"
+	auth, _ := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+	// replace state
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/state",
+		strings.NewReader("{\"count\":\"1\",\"name\":\"demo\"}"))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		UpdateBasketState(w, r, ps)
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+	}
+
+	// get state
+	r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/state", nil)
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		GetBasketState(w, r, ps)
+
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		state := make(map[string]string)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &state)) {
+			assert.Equal(t, map[string]string{"count": "1", "name": "demo"}, state)
+		}
+	}
+
+	// single value
+	kps := append(ps, httprouter.Param{Key: "key", Value: "count"})
+	r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/state/count", strings.NewReader("2"))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		UpdateBasketStateValue(w, r, kps)
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+	}
+
+	r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/state/count", nil)
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		GetBasketStateValue(w, r, kps)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Equal(t, "2", w.Body.String(), "wrong state value")
+	}
+
+	r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/state/count", nil)
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		DeleteBasketStateValue(w, r, kps)
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+		w = httptest.NewRecorder()
+		GetBasketStateValue(w, r, kps)
+		assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+	}
+
+	// clear state
+	r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/state", nil)
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		DeleteBasketState(w, r, ps)
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+		assert.Empty(t, basketsDb.Get(basket).GetState(), "state is expected to be empty")
+	}
+}
+
+func TestUpdateBasketState_Invalid(t *testing.T) {
+	basket := "state02"
+	auth, _ := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+
+	r, err := http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/state", strings.NewReader("{\"count\":1}"))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		UpdateBasketState(w, r, ps)
+		assert.Equal(t, 400, w.Code, "wrong HTTP result code")
+	}
+
+	r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/state", strings.NewReader("{\"\":\"1\"}"))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		UpdateBasketState(w, r, ps)
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+	}
+
+	kps := append(ps, httprouter.Param{Key: "key", Value: "big"})
+	r, err = http.NewRequest("PUT", "http://localhost:55555/api/baskets/"+basket+"/state/big",
+		strings.NewReader(strings.Repeat("v", maxStateValueSize+1)))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		UpdateBasketStateValue(w, r, kps)
+		assert.Equal(t, 413, w.Code, "wrong HTTP result code")
+	}
+}
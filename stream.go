@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// maxStreamCaptureSize defines how many bytes of streamed request body are kept in basket
+const maxStreamCaptureSize = 1024 * 1024
+
+// StreamTo forwards request to specified target streaming the given body as is, so body rewrites are not applied;
+// the forwarded request is cancelled along with the given context, e.g. when the client has gone away
+func (req *RequestData) StreamTo(ctx context.Context, client *http.Client, target ForwardTarget, basket string,
+	body io.Reader, contentLength int64) (*http.Response, error) {
+	forwardReq, err := req.newForwardRequest(ctx, target, basket, body)
+	if err != nil {
+		return nil, err
+	}
+
+	forwardReq.ContentLength = contentLength
+	if contentLength == 0 {
+		forwardReq.Body = http.NoBody
+	}
+
+	return doForward(client, forwardReq, target, basket)
+}
+
+// captureBuffer keeps the beginning of streamed body up to the limit and discards the rest
+type captureBuffer struct {
+	sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (capture *captureBuffer) Write(p []byte) (int, error) {
+	capture.Lock()
+	defer capture.Unlock()
+
+	room := capture.limit - capture.buf.Len()
+	if len(p) > room {
+		capture.truncated = true
+		if room > 0 {
+			capture.buf.Write(p[:room])
+		}
+	} else {
+		capture.buf.Write(p)
+	}
+	return len(p), nil
+}
+
+// Bytes returns a copy of captured data
+func (capture *captureBuffer) Bytes() []byte {
+	capture.Lock()
+	defer capture.Unlock()
+
+	return append([]byte(nil), capture.buf.Bytes()...)
+}
+
+// Truncated indicates whether the body was bigger than captured data
+func (capture *captureBuffer) Truncated() bool {
+	capture.Lock()
+	defer capture.Unlock()
+
+	return capture.truncated
+}
+
+// detachableReader passes reads to the underlying reader until it is detached, so the rest of body can be read by
+// the handler without racing with the transport that may still hold the body
+type detachableReader struct {
+	sync.Mutex
+	reader   io.Reader
+	detached bool
+}
+
+func (body *detachableReader) Read(p []byte) (int, error) {
+	body.Lock()
+	defer body.Unlock()
+
+	if body.detached {
+		return 0, io.EOF
+	}
+	return body.reader.Read(p)
+}
+
+func (body *detachableReader) detach() {
+	body.Lock()
+	defer body.Unlock()
+
+	body.detached = true
+}
+
+// streamAndProxyResponse forwards request to the proxy target without buffering: request body is streamed to the target
+// while its beginning is captured in basket, the response is passed back to the client as soon as data arrives
+func streamAndProxyResponse(w http.ResponseWriter, r *http.Request, basket Basket, targets []ForwardTarget, proxy int,
+	config BasketConfig, name string) {
+	target := targets[proxy]
+	head := &RequestData{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Header: r.Header,
+		ContentLength: r.ContentLength}
+	capture := &captureBuffer{limit: maxStreamCaptureSize}
+
+	// streamed responses (e.g. server-sent events) are only limited by the time to receive response headers
+	client := getStreamingHTTPClient(config.HTTPClient, target.InsecureTLS)
+	body := &detachableReader{reader: io.TeeReader(r.Body, capture)}
+	response, err := head.StreamTo(r.Context(), client, target, name, body, r.ContentLength)
+
+	// the rest of body, which is not read by the target if it is down or responds early, is captured as well, so
+	// the collected request and other targets receive the whole body
+	body.detach()
+	io.CopyN(capture, r.Body, maxStreamCaptureSize+1)
+
+	// collect request with captured body once the target started to respond, body of streamed response is not
+	// captured
+	r.Body = ioutil.NopCloser(bytes.NewReader(capture.Bytes()))
+	if capture.Truncated() {
+		r = withTruncatedBody(r)
+	}
+	if err == nil {
+		captured := newResponseData(response)
+		captured.Truncated = true
+		r = withProxiedResponse(r, captured)
+	}
+	request := basket.Add(r)
+	if capture.Truncated() {
+		// other targets would receive truncated body
+		if len(targets) > 1 {
+			log.Printf("[warn] streamed request body is larger than %d bytes, it is not forwarded to other targets "+
+				"of basket: %s", maxStreamCaptureSize, name)
+			targets = targets[proxy : proxy+1]
+			proxy = 0
+		} else {
+			log.Printf("[info] streamed request body is truncated to %d bytes in basket: %s", maxStreamCaptureSize, name)
+		}
+	}
+
+	for i, t := range targets {
+		if i != proxy && matchesForwardFilters(t.Filters, request, name) {
+			go forwardAndForget(basket, request, t, name)
+		}
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	} else {
+		// CORS headers are controlled by basket if configured
+		writeForwardResponse(w, response, config.CORS != nil, true, name)
+	}
+}
+
+// copyAndFlush copies response body to the client flushing every chunk of data as soon as it is received
+func copyAndFlush(w http.ResponseWriter, body io.Reader) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		_, err := io.Copy(w, body)
+		return err
+	}
+
+	flusher.Flush()
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			flusher.Flush()
+		}
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCaptureBuffer(t *testing.T) {
+	capture := &captureBuffer{limit: 5}
+
+	n, err := capture.Write([]byte("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.False(t, capture.Truncated())
+
+	n, err = capture.Write([]byte("defgh"))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n, "all data should be accepted")
+	assert.True(t, capture.Truncated())
+
+	capture.Write([]byte("ijk"))
+	assert.Equal(t, "abcde", string(capture.Bytes()))
+}
+
+func TestAcceptBasketRequests_WithStreamProxy(t *testing.T) {
+	basket := "stream01"
+
+	release := make(chan bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := io.Copy(ioutil.Discard, r.Body)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("data: " + strconv.FormatInt(size, 10) + "\n\n"))
+		w.(http.Flusher).Flush()
+
+		// the rest of stream is sent once the first event is received by the client
+		<-release
+		w.Write([]byte("data: done\n\n"))
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL, ProxyResponse: true, StreamProxy: true})
+
+	server := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer server.Close()
+
+	body := bytes.Repeat([]byte("x"), maxStreamCaptureSize+1000)
+	response, err := http.Post(server.URL+"/r/"+basket+"/upload", "application/octet-stream", bytes.NewReader(body))
+	if assert.NoError(t, err) {
+		defer response.Body.Close()
+		assert.Equal(t, 200, response.StatusCode, "wrong HTTP result code")
+
+		reader := bufio.NewReader(response.Body)
+		event, err := reader.ReadString('\n')
+		assert.NoError(t, err)
+		assert.Equal(t, "data: "+strconv.Itoa(len(body))+"\n", event, "full body should be streamed to the target")
+
+		close(release)
+		rest, _ := ioutil.ReadAll(reader)
+		assert.Equal(t, "\ndata: done\n\n", string(rest), "wrong rest of stream")
+
+		// request is collected with truncated body
+		page := basketsDb.Get(basket).GetRequests(1, 0)
+		if assert.Len(t, page.Requests, 1, "request should be collected") {
+			assert.Equal(t, maxStreamCaptureSize, len(page.Requests[0].Body), "captured body should be truncated")
+			assert.Equal(t, int64(len(body)), page.Requests[0].ContentLength, "wrong content length")
+			if assert.NotNil(t, page.Requests[0].Response, "status of streamed response should be captured") {
+				assert.Equal(t, 200, page.Requests[0].Response.Status, "wrong captured status")
+				assert.True(t, page.Requests[0].Response.Truncated, "body of streamed response is not captured")
+			}
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WithStreamProxy_FanOut(t *testing.T) {
+	basket := "stream02"
+
+	forwarded := make(chan string, 1)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		forwarded <- string(body)
+	}))
+	defer secondary.Close()
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(strings.ToUpper(string(body))))
+	}))
+	defer primary.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: primary.URL, ProxyResponse: true,
+		StreamProxy: true, ForwardTargets: []ForwardTarget{{URL: secondary.URL}}})
+
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("hello"))
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+
+	assert.Equal(t, 201, w.Code, "wrong HTTP result code")
+	assert.Equal(t, "HELLO", w.Body.String(), "wrong proxied response")
+
+	select {
+	case body := <-forwarded:
+		assert.Equal(t, "hello", body, "captured body should be forwarded to other targets")
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "request is not forwarded to secondary target")
+	}
+}
+
+func TestAcceptBasketRequests_WithStreamProxy_HeaderTimeout(t *testing.T) {
+	basket := "stream03"
+
+	release := make(chan bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// response headers are never sent
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL, ProxyResponse: true, StreamProxy: true,
+		HTTPClient: &HTTPClientConfig{Timeout: 200}})
+
+	done := make(chan int)
+	go func() {
+		r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("hello"))
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+		done <- w.Code
+	}()
+
+	select {
+	case code := <-done:
+		assert.True(t, code >= 500, "error is expected if target does not respond, but was %d", code)
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "streamed request should be limited by response header timeout")
+	}
+}
+
+func TestAcceptBasketRequests_WithStreamProxy_TruncatedFanOut(t *testing.T) {
+	basket := "stream04"
+
+	forwarded := make(chan int, 1)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		forwarded <- len(body)
+	}))
+	defer secondary.Close()
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := io.Copy(ioutil.Discard, r.Body)
+		w.Write([]byte(strconv.FormatInt(size, 10)))
+	}))
+	defer primary.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: primary.URL, ProxyResponse: true,
+		StreamProxy: true, ForwardTargets: []ForwardTarget{{URL: secondary.URL}}})
+
+	body := bytes.Repeat([]byte("x"), maxStreamCaptureSize+1)
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+
+	assert.Equal(t, strconv.Itoa(len(body)), w.Body.String(), "full body should be streamed to the proxy target")
+	select {
+	case size := <-forwarded:
+		assert.Fail(t, "truncated body should not be forwarded to other targets", "size: %d", size)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	page := basketsDb.Get(basket).GetRequests(10, 0)
+	if assert.Len(t, page.Requests, 1, "request should be collected") {
+		assert.Len(t, page.Requests[0].Body, maxStreamCaptureSize, "wrong size of collected body")
+		assert.True(t, page.Requests[0].Truncated, "collected body should be marked as truncated")
+	}
+}
+
+func TestAcceptBasketRequests_WithStreamProxy_TargetDown(t *testing.T) {
+	basket := "stream05"
+
+	forwarded := make(chan string, 1)
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		forwarded <- string(body)
+	}))
+	defer secondary.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: "http://127.0.0.1:1", ProxyResponse: true,
+		StreamProxy: true, ForwardTargets: []ForwardTarget{{URL: secondary.URL}}})
+
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("hello world"))
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+	assert.True(t, w.Code >= 500, "error is expected if target is down, but was %d", w.Code)
+
+	select {
+	case body := <-forwarded:
+		assert.Equal(t, "hello world", body, "whole body should be forwarded to other targets")
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "request is not forwarded to secondary target")
+	}
+
+	page := basketsDb.Get(basket).GetRequests(10, 0)
+	if assert.Len(t, page.Requests, 1, "request should be collected") {
+		assert.Equal(t, "hello world", page.Requests[0].Body, "whole body should be collected")
+		assert.False(t, page.Requests[0].Truncated, "collected body is not truncated")
+	}
+}
+
+func TestAcceptBasketRequests_WithStreamProxy_EarlyResponse(t *testing.T) {
+	basket := "stream06"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the body is not read
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL, ProxyResponse: true, StreamProxy: true})
+
+	body := strings.Repeat("x", 256*1024)
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+	assert.Equal(t, 202, w.Code, "wrong HTTP result code")
+
+	page := basketsDb.Get(basket).GetRequests(10, 0)
+	if assert.Len(t, page.Requests, 1, "request should be collected") {
+		assert.Equal(t, len(body), len(page.Requests[0].Body), "whole body should be collected")
+		assert.False(t, page.Requests[0].Truncated, "collected body is not truncated")
+	}
+}
+
+func TestAcceptBasketRequests_WithStreamProxy_Validation(t *testing.T) {
+	basket := "stream07"
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		size, _ := io.Copy(ioutil.Discard, r.Body)
+		w.Write([]byte(strconv.FormatInt(size, 10)))
+	}))
+	defer ts.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, ForwardURL: ts.URL, ProxyResponse: true, StreamProxy: true,
+		Validation: &BasketValidation{Schema: &JSONSchema{Type: schemaTypes{"object"}}}})
+
+	body := bytes.Repeat([]byte("x"), maxValidatedBodySize+1000)
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/"+basket, bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	AcceptBasketRequests(w, r)
+	assert.Equal(t, strconv.Itoa(len(body)), w.Body.String(), "full body should be streamed to the proxy target")
+
+	page := basketsDb.Get(basket).GetRequests(10, 0)
+	if assert.Len(t, page.Requests, 1, "request should be collected") {
+		assert.Equal(t, []string{"request body is larger than 1048576 bytes and is not validated"},
+			page.Requests[0].Violations, "wrong violations")
+	}
+}
+
+// failingResponseWriter emulates client that has gone away
+type failingResponseWriter struct {
+	httptest.ResponseRecorder
+}
+
+func (w *failingResponseWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestWriteForwardResponse_StreamClientGone(t *testing.T) {
+	// endless stream of target
+	reader, writer := io.Pipe()
+	go func() {
+		for {
+			if _, err := writer.Write([]byte("data: tick\n\n")); err != nil {
+				return
+			}
+		}
+	}()
+	response := &http.Response{StatusCode: 200, Header: http.Header{}, Body: reader}
+
+	done := make(chan bool)
+	go func() {
+		writeForwardResponse(&failingResponseWriter{*httptest.NewRecorder()}, response, false, true, "stream05")
+		done <- true
+	}()
+
+	select {
+	case <-done:
+		_, err := writer.Write([]byte("data: tick\n\n"))
+		assert.Error(t, err, "body of streamed response should be closed")
+	case <-time.After(3 * time.Second):
+		reader.Close()
+		assert.Fail(t, "streamed response should not be drained when client has gone away")
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"encoding/hex"
 )
 
 // GenerateToken generates a cryptographically strong token that uses only base64 characters
@@ -15,3 +16,11 @@ func GenerateToken() (string, error) {
 
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
+
+// GenerateID generates a random identifier of collected data, e.g. requests
+func GenerateID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Supported locations of request parameters
+const (
+	ParamInQuery  = "query"
+	ParamInHeader = "header"
+	ParamInPath   = "path"
+	ParamInCookie = "cookie"
+)
+
+// maxValidatedBodySize limits size of request body buffered for validation, larger bodies are not validated
+const maxValidatedBodySize = maxStreamCaptureSize
+
+type violationsKey struct{}
+
+// bodyReader reads buffered beginning of request body followed by the rest of original body
+type bodyReader struct {
+	io.Reader
+	io.Closer
+}
+
+// validateRequestValidation validates configuration of request validation
+func validateRequestValidation(validation *RequestValidation) error {
+	for _, param := range validation.Parameters {
+		if len(param.Name) == 0 {
+			return fmt.Errorf("name of request parameter is required")
+		}
+		switch param.In {
+		case ParamInQuery, ParamInHeader, ParamInPath, ParamInCookie:
+		default:
+			return fmt.Errorf("unknown location of request parameter %s: %s", param.Name, param.In)
+		}
+		if err := validateSchema(param.Schema); err != nil {
+			return fmt.Errorf("request parameter %s: %s", param.Name, err)
+		}
+	}
+
+	for _, ctype := range validation.ContentTypes {
+		if _, _, err := mime.ParseMediaType(ctype); err != nil {
+			return fmt.Errorf("invalid content type: %s - %s", ctype, err)
+		}
+	}
+
+	if err := validateSchema(validation.Body); err != nil {
+		return fmt.Errorf("request body: %s", err)
+	}
+
+	return nil
+}
+
+// validateBasketValidation validates configuration of basket request validation, patterns of the schema are compiled
+// once here and reused to validate incoming requests
+func validateBasketValidation(validation *BasketValidation) error {
+	for _, method := range validation.Methods {
+		if _, err := validateMethod(method); err != nil {
+			return err
+		}
+	}
+
+	if err := validateSchema(validation.Schema); err != nil {
+		return fmt.Errorf("request body: %s", err)
+	}
+
+	return nil
+}
+
+// collectViolations validates incoming HTTP request according to basket and response validation settings,
+// returns list of found violations. Only the first 1 MB of request body is buffered for validation, so streamed
+// requests are passed to the target once that much is received; larger bodies are reported as not validated.
+func collectViolations(basket *BasketValidation, response *RequestValidation, r *http.Request, params map[string]string) []string {
+	validateBody := basket != nil && basket.Schema != nil && basket.appliesTo(r.Method)
+	if !validateBody && response == nil {
+		return nil
+	}
+
+	// the beginning of request body is read and put back in front of the rest of it
+	body, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxValidatedBodySize+1))
+	r.Body = bodyReader{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+	if len(body) > maxValidatedBodySize {
+		violations := []string{fmt.Sprintf("request body is larger than %d bytes and is not validated",
+			maxValidatedBodySize)}
+		if response != nil {
+			violations = append(violations, validateParameters(response, r, params)...)
+		}
+		return violations
+	}
+
+	var violations []string
+	if validateBody && len(body) > 0 {
+		violations = append(violations, validateJSONBody(basket.Schema, body)...)
+	}
+	if response != nil {
+		violations = append(violations, validateRequest(response, r, params, body)...)
+	}
+
+	return violations
+}
+
+// appliesTo checks if basket validation is applicable to requests with the given HTTP method
+func (validation *BasketValidation) appliesTo(method string) bool {
+	if len(validation.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range validation.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateRequest validates parameters and body of incoming HTTP request, returns list of found violations
+func validateRequest(validation *RequestValidation, r *http.Request, params map[string]string, body []byte) []string {
+	violations := validateParameters(validation, r, params)
+	return append(violations, validateRequestBody(validation, r.Header.Get("Content-Type"), body)...)
+}
+
+// validateParameters validates parameters of incoming HTTP request, returns list of found violations
+func validateParameters(validation *RequestValidation, r *http.Request, params map[string]string) []string {
+	var violations []string
+
+	// parameters
+	query := r.URL.Query()
+	for _, param := range validation.Parameters {
+		var values []string
+		switch param.In {
+		case ParamInQuery:
+			values = query[param.Name]
+		case ParamInHeader:
+			values = r.Header.Values(param.Name)
+		case ParamInPath:
+			if value, ok := params[param.Name]; ok {
+				values = []string{value}
+			}
+		case ParamInCookie:
+			if cookie, err := r.Cookie(param.Name); err == nil {
+				values = []string{cookie.Value}
+			}
+		}
+
+		if len(values) == 0 {
+			if param.Required {
+				violations = append(violations, fmt.Sprintf("missing required %s parameter: %s", param.In, param.Name))
+			}
+		} else if param.Schema != nil {
+			for _, err := range param.Schema.Validate(coerceParameter(param.Schema, values)) {
+				violations = append(violations, fmt.Sprintf("%s parameter %s: %s", param.In, param.Name, strings.TrimPrefix(err, "$: ")))
+			}
+		}
+	}
+
+	return violations
+}
+
+// validateRequestBody validates content type and JSON body of HTTP request
+func validateRequestBody(validation *RequestValidation, contentType string, body []byte) []string {
+	if len(body) == 0 {
+		if validation.BodyRequired {
+			return []string{"request body is required"}
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if len(validation.ContentTypes) > 0 && !matchesMediaType(validation.ContentTypes, mediaType) {
+		return []string{fmt.Sprintf("unsupported content type of request body: %s", contentType)}
+	}
+
+	if validation.Body == nil || (len(mediaType) > 0 && !isJSONMediaType(mediaType)) {
+		return nil
+	}
+
+	return validateJSONBody(validation.Body, body)
+}
+
+// validateJSONBody validates JSON body of HTTP request against JSON schema
+func validateJSONBody(schema *JSONSchema, body []byte) []string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []string{fmt.Sprintf("request body is not a valid JSON: %s", err)}
+	}
+
+	violations := schema.Validate(value)
+	for i, err := range violations {
+		violations[i] = "request body " + err
+	}
+	return violations
+}
+
+// writeViolations responds with "400 - Bad Request" listing validation violations of HTTP request
+func writeViolations(w http.ResponseWriter, violations []string) {
+	json, err := json.Marshal(map[string][]string{"violations": violations})
+	writeJSON(w, http.StatusBadRequest, json, err)
+}
+
+// matchesMediaType checks if media type matches one of accepted types, wildcards like "*/*" or "text/*" are supported
+func matchesMediaType(accepted []string, mediaType string) bool {
+	for _, ctype := range accepted {
+		expected, _, _ := mime.ParseMediaType(ctype)
+		if expected == mediaType || expected == "*/*" ||
+			(strings.HasSuffix(expected, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(expected, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+func isJSONMediaType(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// withViolations attaches validation violations to HTTP request, so they are stored with collected request data
+func withViolations(r *http.Request, violations []string) *http.Request {
+	if len(violations) == 0 {
+		return r
+	}
+	return r.WithContext(context.WithValue(r.Context(), violationsKey{}, violations))
+}
+
+// requestViolations retrieves validation violations attached to HTTP request
+func requestViolations(r *http.Request) []string {
+	violations, _ := r.Context().Value(violationsKey{}).([]string)
+	return violations
+}
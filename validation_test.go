@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRequestValidation(t *testing.T) {
+	assert.NoError(t, validateRequestValidation(&RequestValidation{
+		Parameters:   []RequestParameter{{Name: "id", In: ParamInPath, Schema: &JSONSchema{Type: schemaTypes{"integer"}}}},
+		ContentTypes: []string{"application/json"},
+		Body:         &JSONSchema{Type: schemaTypes{"object"}}}))
+
+	err := validateRequestValidation(&RequestValidation{Parameters: []RequestParameter{{Name: "id", In: "body"}}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unknown location of request parameter id: body")
+	}
+
+	assert.Error(t, validateRequestValidation(&RequestValidation{Parameters: []RequestParameter{{In: ParamInQuery}}}))
+	assert.Error(t, validateRequestValidation(&RequestValidation{ContentTypes: []string{"application/"}}))
+	assert.Error(t, validateRequestValidation(&RequestValidation{Body: &JSONSchema{Pattern: "("}}))
+}
+
+func TestValidateRequest(t *testing.T) {
+	validation := &RequestValidation{
+		Parameters: []RequestParameter{
+			{Name: "id", In: ParamInPath, Required: true, Schema: &JSONSchema{Type: schemaTypes{"integer"}}},
+			{Name: "limit", In: ParamInQuery, Schema: &JSONSchema{Type: schemaTypes{"integer"}, Maximum: floatPtr(100)}},
+			{Name: "X-Tenant", In: ParamInHeader, Required: true},
+			{Name: "session", In: ParamInCookie, Required: true}},
+		ContentTypes: []string{"application/json"},
+		BodyRequired: true,
+		Body:         &JSONSchema{Type: schemaTypes{"object"}, Required: []string{"name"}}}
+
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/demo/users/42?limit=10", strings.NewReader("{\"name\":\"Joe\"}"))
+	r.Header.Set("Content-Type", "application/json; charset=utf-8")
+	r.Header.Set("X-Tenant", "acme")
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	assert.Empty(t, validateRequest(validation, r, map[string]string{"id": "42"}, []byte("{\"name\":\"Joe\"}")))
+
+	r = httptest.NewRequest("POST", "http://localhost:55555/r/demo/users/abc?limit=500", strings.NewReader("{}"))
+	r.Header.Set("Content-Type", "application/json")
+	assert.Equal(t, []string{
+		"path parameter id: expected integer, but was string",
+		"query parameter limit: value 500 is greater than maximum 100",
+		"missing required header parameter: X-Tenant",
+		"missing required cookie parameter: session",
+		"request body $: missing required property: name"}, validateRequest(validation, r, map[string]string{"id": "abc"}, []byte("{}")))
+}
+
+func TestValidateRequestBody(t *testing.T) {
+	validation := &RequestValidation{ContentTypes: []string{"application/json", "text/*"}, BodyRequired: true,
+		Body: &JSONSchema{Type: schemaTypes{"array"}}}
+
+	assert.Equal(t, []string{"request body is required"}, validateRequestBody(validation, "", nil))
+	assert.Empty(t, validateRequestBody(validation, "application/json", []byte("[1]")))
+	assert.Empty(t, validateRequestBody(validation, "text/plain", []byte("not validated")))
+	assert.Empty(t, validateRequestBody(&RequestValidation{Body: validation.Body}, "application/vnd.api+json", []byte("[]")))
+	assert.Equal(t, []string{"unsupported content type of request body: "}, validateRequestBody(validation, "", []byte("[]")))
+	assert.Equal(t, []string{"unsupported content type of request body: application/xml"},
+		validateRequestBody(validation, "application/xml", []byte("<a/>")))
+	assert.Equal(t, []string{"request body $: expected array, but was object"},
+		validateRequestBody(validation, "application/json", []byte("{}")))
+
+	violations := validateRequestBody(&RequestValidation{Body: validation.Body}, "", []byte("{"))
+	if assert.Len(t, violations, 1) {
+		assert.Contains(t, violations[0], "request body is not a valid JSON")
+	}
+}
+
+func TestCollectViolations(t *testing.T) {
+	basket := &BasketValidation{Methods: []string{"POST"}, Schema: &JSONSchema{Type: schemaTypes{"object"}, Required: []string{"id"}}}
+	response := &RequestValidation{Parameters: []RequestParameter{{Name: "v", In: ParamInQuery, Required: true}}}
+
+	r := httptest.NewRequest("POST", "http://localhost:55555/r/demo", strings.NewReader("{\"name\":\"Joe\"}"))
+	r.Header.Set("Content-Type", "text/plain")
+	assert.Equal(t, []string{
+		"request body $: missing required property: id",
+		"missing required query parameter: v"}, collectViolations(basket, response, r, nil))
+
+	// body is still available
+	body, _ := ioutil.ReadAll(r.Body)
+	assert.Equal(t, "{\"name\":\"Joe\"}", string(body))
+
+	// not applicable to method
+	r = httptest.NewRequest("PUT", "http://localhost:55555/r/demo", strings.NewReader("[]"))
+	assert.Empty(t, collectViolations(basket, nil, r, nil))
+
+	// empty body is not validated
+	r = httptest.NewRequest("POST", "http://localhost:55555/r/demo", strings.NewReader(""))
+	assert.Empty(t, collectViolations(basket, nil, r, nil))
+
+	r = httptest.NewRequest("POST", "http://localhost:55555/r/demo", strings.NewReader("<xml/>"))
+	violations := collectViolations(basket, nil, r, nil)
+	if assert.Len(t, violations, 1) {
+		assert.Contains(t, violations[0], "request body is not a valid JSON")
+	}
+
+	assert.Nil(t, collectViolations(nil, nil, r, nil))
+
+	// too large body is not validated, but kept as is
+	large := "[" + strings.Repeat(" ", maxValidatedBodySize) + "]"
+	r = httptest.NewRequest("POST", "http://localhost:55555/r/demo", strings.NewReader(large))
+	assert.Equal(t, []string{
+		"request body is larger than 1048576 bytes and is not validated",
+		"missing required query parameter: v"}, collectViolations(basket, response, r, nil))
+	body, _ = ioutil.ReadAll(r.Body)
+	assert.Equal(t, large, string(body), "whole body should be available")
+}
+
+func TestValidateBasketValidation(t *testing.T) {
+	assert.NoError(t, validateBasketValidation(&BasketValidation{Methods: []string{"post", "PUT"}, Schema: &JSONSchema{}}))
+	assert.Error(t, validateBasketValidation(&BasketValidation{Methods: []string{"SEND"}}))
+	assert.Error(t, validateBasketValidation(&BasketValidation{Schema: &JSONSchema{Type: schemaTypes{"map"}}}))
+	assert.Error(t, validateBasketValidation(&BasketValidation{Schema: &JSONSchema{Pattern: "[a-"}}))
+
+	pattern := "^basket-validation-[0-9]+$"
+	assert.NoError(t, validateBasketValidation(&BasketValidation{Schema: &JSONSchema{
+		Properties: map[string]*JSONSchema{"id": {Pattern: pattern}}}}))
+	compiledPatterns.Lock()
+	assert.Contains(t, compiledPatterns.patterns, pattern, "compiled pattern should be cached")
+	compiledPatterns.Unlock()
+}
+
+func TestCreateBasket_InvalidValidation(t *testing.T) {
+	basket := "schema01"
+
+	r, err := http.NewRequest("POST", "http://localhost:55555/api/baskets/"+basket,
+		strings.NewReader("{\"capacity\":20,\"validation\":{\"schema\":{\"type\":\"map\"}}}"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+		CreateBasket(w, r, ps)
+
+		// validate response: 422 - unprocessable entity
+		assert.Equal(t, 422, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "unknown type in JSON schema: map", "wrong error message")
+		assert.Nil(t, basketsDb.Get(basket), "basket is not expected to be created")
+	}
+}
+
+func TestAcceptBasketRequests_SchemaViolations(t *testing.T) {
+	basket := "schema02"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, Validation: &BasketValidation{
+		Methods: []string{"POST"},
+		Schema:  &JSONSchema{Type: schemaTypes{"object"}, Required: []string{"id"}}}})
+
+	// invalid request is answered, but flagged
+	r, err := http.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("{\"name\":\"Joe\"}"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+		request := basketsDb.Get(basket).GetRequests(1, 0).Requests[0]
+		assert.Equal(t, []string{"request body $: missing required property: id"}, request.Violations)
+		assert.Equal(t, "{\"name\":\"Joe\"}", request.Body, "wrong collected body")
+	}
+
+	// reject invalid requests
+	config := basketsDb.Get(basket).Config()
+	config.Validation.Reject = true
+	basketsDb.Get(basket).Update(config)
+
+	r, err = http.NewRequest("POST", "http://localhost:55555/r/"+basket, strings.NewReader("[]"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+
+		// validate response: 400 - bad request
+		assert.Equal(t, 400, w.Code, "wrong HTTP response code")
+		assert.Equal(t, "application/json; charset=UTF-8", w.Header().Get("Content-Type"), "wrong Content-Type")
+		result := make(map[string][]string)
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result)) {
+			assert.Equal(t, []string{"request body $: expected object, but was array"}, result["violations"])
+		}
+		assert.Equal(t, 2, basketsDb.Get(basket).GetRequests(5, 0).TotalCount, "rejected request is expected to be collected")
+	}
+
+	// valid request, method is not validated
+	r, err = http.NewRequest("PUT", "http://localhost:55555/r/"+basket, strings.NewReader("[]"))
+	if assert.NoError(t, err) {
+		w := httptest.NewRecorder()
+		AcceptBasketRequests(w, r)
+		assert.Equal(t, 200, w.Code, "wrong HTTP response code")
+	}
+}
+
+func TestRequestViolations(t *testing.T) {
+	r := httptest.NewRequest("GET", "http://localhost:55555/r/demo", nil)
+	assert.Nil(t, requestViolations(r))
+	assert.Equal(t, r, withViolations(r, nil), "request is not expected to change")
+
+	r = withViolations(r, []string{"missing required query parameter: id"})
+	assert.Equal(t, []string{"missing required query parameter: id"}, requestViolations(r))
+	assert.Equal(t, []string{"missing required query parameter: id"}, ToRequestData(r).Violations)
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
@@ -0,0 +1,635 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Directions of recorded WebSocket frames
+const (
+	WebSocketInbound  = "in"  // frames sent by the client
+	WebSocketOutbound = "out" // frames sent to the client
+)
+
+// Limits of WebSocket sessions
+const (
+	maxWebSocketSessions   = 50
+	maxWebSocketFrames     = 200
+	maxWebSocketPayload    = 64 * 1024
+	maxWebSocketControl    = 125
+	webSocketIdleTimeout   = 5 * time.Minute
+	webSocketSaveDelay     = 500 * time.Millisecond
+	webSocketHandshakeGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+)
+
+// WebSocket frame opcodes
+const (
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xA
+)
+
+// WebSocketFrame describes single frame of WebSocket session, binary payload is base64 encoded.
+type WebSocketFrame struct {
+	Direction string `json:"direction"`
+	Opcode    int    `json:"opcode"`
+	Payload   string `json:"payload"`
+	Base64    bool   `json:"base64,omitempty"`
+	Size      int    `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+	Date      int64  `json:"date"`
+}
+
+// WebSocketSession describes WebSocket connection accepted by basket, only the latest frames are kept.
+type WebSocketSession struct {
+	ID         string           `json:"id"`
+	Date       int64            `json:"date"`
+	Closed     int64            `json:"closed,omitempty"`
+	Path       string           `json:"path"`
+	Query      string           `json:"query"`
+	Header     http.Header      `json:"headers"`
+	Target     string           `json:"target,omitempty"`
+	FrameCount int              `json:"frame_count"`
+	Frames     []WebSocketFrame `json:"frames,omitempty"`
+}
+
+// sortWebSocketSessions orders WebSocket sessions from the newest to the oldest
+func sortWebSocketSessions(sessions []*WebSocketSession) []*WebSocketSession {
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].Date != sessions[j].Date {
+			return sessions[i].Date > sessions[j].Date
+		}
+		return sessions[i].ID > sessions[j].ID
+	})
+	return sessions
+}
+
+// isWebSocketURL checks whether forward URL points to WebSocket server
+func isWebSocketURL(target string) bool {
+	return strings.HasPrefix(target, "ws://") || strings.HasPrefix(target, "wss://")
+}
+
+// getWebSocketTarget returns forward target of basket to proxy WebSocket sessions to, nil if there is none
+func getWebSocketTarget(config BasketConfig) *ForwardTarget {
+	if isWebSocketURL(config.ForwardURL) {
+		return &ForwardTarget{URL: config.ForwardURL, InsecureTLS: config.InsecureTLS, ExpandPath: config.ExpandPath}
+	}
+
+	for _, target := range config.ForwardTargets {
+		if !target.Disabled && isWebSocketURL(target.URL) {
+			t := target
+			return &t
+		}
+	}
+	return nil
+}
+
+func isWebSocketUpgrade(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") && headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func webSocketAccept(key string) string {
+	hash := sha1.Sum([]byte(key + webSocketHandshakeGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// wsFrame is a frame read from WebSocket connection, raw bytes are relayed as is when session is proxied.
+// Only the recorded part of payload is kept in memory, the rest must be consumed from the frame before
+// the next one is read.
+type wsFrame struct {
+	opcode  int
+	size    int64
+	payload []byte
+	raw     []byte
+	rest    io.Reader
+}
+
+// readWebSocketFrame reads single frame from WebSocket connection and unmasks the recorded part of its payload
+func readWebSocketFrame(reader io.Reader) (*wsFrame, error) {
+	head := make([]byte, 2, 14)
+	if _, err := io.ReadFull(reader, head); err != nil {
+		return nil, err
+	}
+
+	frame := &wsFrame{opcode: int(head[0] & 0x0F)}
+	masked := head[1]&0x80 != 0
+	length := uint64(head[1] & 0x7F)
+
+	switch length {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(reader, ext); err != nil {
+			return nil, err
+		}
+		head = append(head, ext...)
+		length = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(reader, ext); err != nil {
+			return nil, err
+		}
+		head = append(head, ext...)
+		length = binary.BigEndian.Uint64(ext)
+	}
+
+	if length > math.MaxInt64 {
+		return nil, fmt.Errorf("invalid WebSocket frame length: %d", length)
+	}
+	if frame.opcode >= wsOpClose && length > maxWebSocketControl {
+		return nil, fmt.Errorf("WebSocket control frame is too big: %d bytes", length)
+	}
+	frame.size = int64(length)
+
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(reader, mask); err != nil {
+			return nil, err
+		}
+		head = append(head, mask...)
+	}
+
+	// payload beyond recorded size is never buffered
+	buffered := frame.size
+	if buffered > maxWebSocketPayload {
+		buffered = maxWebSocketPayload
+	}
+	payload := make([]byte, buffered)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, err
+	}
+	frame.raw = append(head, payload...)
+	frame.rest = io.LimitReader(reader, frame.size-buffered)
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	frame.payload = payload
+
+	return frame, nil
+}
+
+// writeWebSocketFrame writes single unmasked (server) frame to WebSocket connection
+func writeWebSocketFrame(writer io.Writer, opcode int, payload []byte) error {
+	head := []byte{0x80 | byte(opcode), 0}
+	switch length := len(payload); {
+	case length < 126:
+		head[1] = byte(length)
+	case length <= 0xFFFF:
+		head[1] = 126
+		head = append(head, 0, 0)
+		binary.BigEndian.PutUint16(head[2:], uint16(length))
+	default:
+		head[1] = 127
+		head = append(head, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(head[2:], uint64(length))
+	}
+
+	_, err := writer.Write(append(head, payload...))
+	return err
+}
+
+// webSocketRecorder collects frames of WebSocket session and saves the session in basket with a short delay,
+// so bursts of frames do not result in excessive database updates
+type webSocketRecorder struct {
+	sync.Mutex
+	saving  sync.Mutex
+	basket  Basket
+	name    string
+	session *WebSocketSession
+	timer   *time.Timer
+}
+
+func newWebSocketRecorder(basket Basket, name string, session *WebSocketSession) *webSocketRecorder {
+	// keep limited number of sessions, the oldest ones are removed
+	sessions := basket.GetWebSocketSessions()
+	for i := len(sessions) - 1; i >= maxWebSocketSessions-1 && i >= 0; i-- {
+		basket.DeleteWebSocketSession(sessions[i].ID)
+	}
+
+	recorder := &webSocketRecorder{basket: basket, name: name, session: session}
+	recorder.save()
+	return recorder
+}
+
+func (recorder *webSocketRecorder) record(direction string, opcode int, payload []byte, size int64) {
+	frame := WebSocketFrame{Direction: direction, Opcode: opcode, Size: int(size),
+		Date: time.Now().UnixNano() / toMs}
+	if len(payload) > maxWebSocketPayload {
+		payload = payload[:maxWebSocketPayload]
+	}
+	frame.Truncated = int64(len(payload)) < size
+	if opcode == wsOpText && utf8.Valid(payload) {
+		frame.Payload = string(payload)
+	} else {
+		frame.Payload = base64.StdEncoding.EncodeToString(payload)
+		frame.Base64 = true
+	}
+
+	recorder.Lock()
+	defer recorder.Unlock()
+
+	recorder.session.FrameCount++
+	recorder.session.Frames = append(recorder.session.Frames, frame)
+	if len(recorder.session.Frames) > maxWebSocketFrames {
+		recorder.session.Frames = recorder.session.Frames[len(recorder.session.Frames)-maxWebSocketFrames:]
+	}
+
+	if recorder.timer == nil {
+		recorder.timer = time.AfterFunc(webSocketSaveDelay, recorder.save)
+	}
+}
+
+func (recorder *webSocketRecorder) save() {
+	// saves are serialized, so the latest snapshot of session is never overwritten with an older one
+	recorder.saving.Lock()
+	defer recorder.saving.Unlock()
+
+	recorder.Lock()
+	recorder.timer = nil
+	session := *recorder.session
+	session.Frames = append([]WebSocketFrame(nil), recorder.session.Frames...)
+	recorder.Unlock()
+
+	if err := recorder.basket.SaveWebSocketSession(&session); err != nil {
+		log.Printf("[warn] failed to save WebSocket session %s of basket: %s - %s", session.ID, recorder.name, err)
+	}
+}
+
+func (recorder *webSocketRecorder) close() {
+	recorder.Lock()
+	if recorder.timer != nil {
+		recorder.timer.Stop()
+	}
+	recorder.session.Closed = time.Now().UnixNano() / toMs
+	recorder.Unlock()
+
+	recorder.save()
+}
+
+// acceptWebSocket accepts WebSocket connection of basket, records its frames and proxies them to WebSocket
+// forward target if configured
+func acceptWebSocket(w http.ResponseWriter, r *http.Request, basket Basket, config BasketConfig, name string) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || len(key) == 0 || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "invalid WebSocket handshake", http.StatusBadRequest)
+		return
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket connections are not supported", http.StatusInternalServerError)
+		return
+	}
+
+	session := &WebSocketSession{ID: GenerateID(), Date: time.Now().UnixNano() / toMs, Path: r.URL.Path,
+		Query: r.URL.RawQuery, Header: r.Header.Clone()}
+
+	// connect to WebSocket target before the client handshake is completed
+	var upstream net.Conn
+	var upstreamReader *bufio.Reader
+	protocol := firstWebSocketProtocol(r.Header)
+	if target := getWebSocketTarget(config); target != nil && r.Header.Get(DoNotForwardHeader) != "1" &&
+		!isForwardLoop(r.Header, name) {
+		var response *http.Response
+		var err error
+		upstream, upstreamReader, response, err = dialWebSocketTarget(r, *target, config, name)
+		if err != nil {
+			log.Printf("[warn] failed to connect to WebSocket target of basket: %s - %s", name, err)
+			http.Error(w, fmt.Sprintf("Failed to connect to WebSocket target: %s", err),
+				forwardErrorStatus(err, http.StatusBadGateway))
+			return
+		}
+		session.Target = target.URL
+		protocol = response.Header.Get("Sec-WebSocket-Protocol")
+	}
+
+	conn, bufrw, err := hijacker.Hijack()
+	if err != nil {
+		log.Printf("[error] failed to accept WebSocket connection of basket: %s - %s", name, err)
+		if upstream != nil {
+			upstream.Close()
+		}
+		return
+	}
+	defer conn.Close()
+
+	handshake := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + webSocketAccept(key) + "\r\n"
+	if len(protocol) > 0 {
+		handshake += "Sec-WebSocket-Protocol: " + protocol + "\r\n"
+	}
+	if _, err = bufrw.WriteString(handshake + "\r\n"); err == nil {
+		err = bufrw.Flush()
+	}
+	if err != nil {
+		if upstream != nil {
+			upstream.Close()
+		}
+		return
+	}
+
+	recorder := newWebSocketRecorder(basket, name, session)
+	defer recorder.close()
+
+	if upstream != nil {
+		defer upstream.Close()
+		relayWebSocket(conn, bufrw.Reader, upstream, upstreamReader, recorder)
+	} else {
+		serveWebSocket(conn, bufrw.Reader, recorder)
+	}
+}
+
+func firstWebSocketProtocol(header http.Header) string {
+	if protocols := header.Get("Sec-WebSocket-Protocol"); len(protocols) > 0 {
+		return strings.TrimSpace(strings.Split(protocols, ",")[0])
+	}
+	return ""
+}
+
+// dialWebSocketTarget connects to WebSocket forward target and performs the opening handshake
+func dialWebSocketTarget(r *http.Request, target ForwardTarget, config BasketConfig, name string) (net.Conn,
+	*bufio.Reader, *http.Response, error) {
+	head := &RequestData{Method: r.Method, Path: r.URL.Path, Query: r.URL.RawQuery, Header: r.Header}
+	req, err := head.newForwardRequest(r.Context(), target, name, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// extensions (e.g. compression) are not negotiated, so recorded payloads stay readable
+	req.Header.Del("Sec-WebSocket-Extensions")
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+
+	settings := mergeHTTPClientConfig(httpClientDefaults, config.HTTPClient)
+	timeout := time.Duration(settings.ConnectTimeout) * time.Millisecond
+	if timeout == 0 {
+		timeout = defaultForwardConnectTimeout * time.Millisecond
+	}
+
+	conn, err := dialWebSocketHost(req.URL, settings, target.InsecureTLS, timeout)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	reader := bufio.NewReader(conn)
+	var response *http.Response
+	if err = req.Write(conn); err == nil {
+		response, err = http.ReadResponse(reader, req)
+	}
+	if err == nil {
+		response.Body.Close()
+		if response.StatusCode != http.StatusSwitchingProtocols {
+			err = fmt.Errorf("unexpected response status: %s", response.Status)
+		} else if response.Header.Get("Sec-WebSocket-Accept") != webSocketAccept(key) {
+			err = fmt.Errorf("invalid Sec-WebSocket-Accept header")
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, nil, nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return conn, reader, response, nil
+}
+
+// dialWebSocketHost connects to the host of WebSocket target, either directly or through HTTP CONNECT tunnel of
+// outbound proxy selected the same way as for HTTP forwarding
+func dialWebSocketHost(target *url.URL, settings HTTPClientConfig, insecure bool, timeout time.Duration) (net.Conn,
+	error) {
+	host := target.Host
+	if len(target.Port()) == 0 {
+		port := "80"
+		if target.Scheme == "wss" {
+			port = "443"
+		}
+		host = net.JoinHostPort(target.Hostname(), port)
+	}
+
+	proxyURL, err := getWebSocketProxy(target, settings)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	if proxyURL != nil {
+		conn, err = dialWebSocketProxy(proxyURL, host, settings, timeout)
+	} else {
+		conn, err = egressPolicy.guard(&net.Dialer{Timeout: timeout}, target.Hostname()).Dial("tcp", host)
+	}
+	if err != nil || target.Scheme != "wss" {
+		return conn, err
+	}
+
+	tlsConfig, err := newTLSConfig(settings, insecure)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConfig.ServerName = target.Hostname()
+	tlsConfig.NextProtos = []string{"http/1.1"}
+	return handshakeTLS(conn, tlsConfig, timeout)
+}
+
+// getWebSocketProxy selects outbound proxy for WebSocket target, "ws" and "wss" targets use proxies of "http" and
+// "https" URLs respectively
+func getWebSocketProxy(target *url.URL, settings HTTPClientConfig) (*url.URL, error) {
+	proxy := newProxyFunc(settings)
+	if proxy == nil {
+		return nil, nil
+	}
+
+	proxied := *target
+	proxied.Scheme = strings.Replace(target.Scheme, "ws", "http", 1)
+	return proxy(&http.Request{URL: &proxied})
+}
+
+// dialWebSocketProxy opens HTTP CONNECT tunnel to the host through outbound proxy, SOCKS5 proxies are not supported
+func dialWebSocketProxy(proxyURL *url.URL, host string, settings HTTPClientConfig, timeout time.Duration) (net.Conn,
+	error) {
+	if proxyURL.Scheme != "http" && proxyURL.Scheme != "https" {
+		return nil, fmt.Errorf("WebSocket forwarding is not supported through %s proxy", proxyURL.Scheme)
+	}
+
+	address := proxyAddress(proxyURL)
+	conn, err := egressPolicy.guard(&net.Dialer{Timeout: timeout}, address).Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		tlsConfig, err := newTLSConfig(settings, false)
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		tlsConfig.ServerName = proxyURL.Hostname()
+		if conn, err = handshakeTLS(conn, tlsConfig, timeout); err != nil {
+			return nil, err
+		}
+	}
+
+	connect := &http.Request{Method: http.MethodConnect, URL: &url.URL{Opaque: host}, Host: host,
+		Header: make(http.Header)}
+	if proxyURL.User != nil {
+		password, _ := proxyURL.User.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+		connect.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	var response *http.Response
+	if err = connect.Write(conn); err == nil {
+		response, err = http.ReadResponse(bufio.NewReader(conn), connect)
+	}
+	if err == nil {
+		response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			err = fmt.Errorf("proxy refused to connect to %s: %s", host, response.Status)
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+// handshakeTLS performs TLS handshake over established connection within the timeout
+func handshakeTLS(conn net.Conn, config *tls.Config, timeout time.Duration) (net.Conn, error) {
+	tlsConn := tls.Client(conn, config)
+	tlsConn.SetDeadline(time.Now().Add(timeout))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	return tlsConn, nil
+}
+
+// idleReader extends read deadline of connection on every read, so silent peers are disconnected
+type idleReader struct {
+	reader  io.Reader
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (r *idleReader) Read(p []byte) (int, error) {
+	r.conn.SetReadDeadline(time.Now().Add(r.timeout))
+	return r.reader.Read(p)
+}
+
+// idleWriter extends write deadline of connection on every write, so stalled peers are disconnected
+type idleWriter struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func (w *idleWriter) Write(p []byte) (int, error) {
+	w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	return w.conn.Write(p)
+}
+
+// serveWebSocket records frames sent by the client, answers pings and closes session on client's request,
+// payloads beyond recorded size are discarded
+func serveWebSocket(conn net.Conn, reader *bufio.Reader, recorder *webSocketRecorder) {
+	src := &idleReader{reader: reader, conn: conn, timeout: webSocketIdleTimeout}
+	for {
+		frame, err := readWebSocketFrame(src)
+		if err != nil {
+			return
+		}
+		if _, err = io.Copy(ioutil.Discard, frame.rest); err != nil {
+			return
+		}
+		recorder.record(WebSocketInbound, frame.opcode, frame.payload, frame.size)
+
+		conn.SetWriteDeadline(time.Now().Add(webSocketIdleTimeout))
+		switch frame.opcode {
+		case wsOpPing:
+			recorder.record(WebSocketOutbound, wsOpPong, frame.payload, frame.size)
+			if writeWebSocketFrame(conn, wsOpPong, frame.payload) != nil {
+				return
+			}
+		case wsOpClose:
+			recorder.record(WebSocketOutbound, wsOpClose, frame.payload, frame.size)
+			writeWebSocketFrame(conn, wsOpClose, frame.payload)
+			return
+		}
+	}
+}
+
+// relayWebSocket passes frames between the client and WebSocket target recording them, frames are relayed as is
+// and payloads beyond recorded size are streamed without buffering
+func relayWebSocket(client net.Conn, clientReader *bufio.Reader, upstream net.Conn, upstreamReader *bufio.Reader,
+	recorder *webSocketRecorder) {
+	done := make(chan bool, 2)
+	relay := func(src io.Reader, dst net.Conn, direction string) {
+		defer func() { done <- true }()
+		for {
+			frame, err := readWebSocketFrame(src)
+			if err != nil {
+				return
+			}
+			recorder.record(direction, frame.opcode, frame.payload, frame.size)
+			writer := &idleWriter{conn: dst, timeout: webSocketIdleTimeout}
+			if _, err = writer.Write(frame.raw); err != nil {
+				return
+			}
+			if _, err = io.Copy(writer, frame.rest); err != nil {
+				return
+			}
+		}
+	}
+
+	go relay(&idleReader{reader: clientReader, conn: client, timeout: webSocketIdleTimeout}, upstream,
+		WebSocketInbound)
+	go relay(&idleReader{reader: upstreamReader, conn: upstream, timeout: webSocketIdleTimeout}, client,
+		WebSocketOutbound)
+
+	// either side is closed, release both connections
+	<-done
+	client.Close()
+	upstream.Close()
+	<-done
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeMaskedFrame writes client (masked) WebSocket frame
+func writeMaskedFrame(writer io.Writer, opcode int, payload []byte) error {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | byte(opcode)}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(len(payload)))
+	}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := writer.Write(frame)
+	return err
+}
+
+// dialWebSocket opens WebSocket connection to test server
+func dialWebSocket(t *testing.T, serverURL string, path string) (net.Conn, *bufio.Reader, *http.Response) {
+	conn, err := net.Dial("tcp", strings.TrimPrefix(serverURL, "http://"))
+	if !assert.NoError(t, err) {
+		return nil, nil, nil
+	}
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+	conn.Write([]byte("GET " + path + " HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\nSec-WebSocket-Protocol: chat\r\n\r\n"))
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	if assert.NoError(t, err) && assert.Equal(t, 101, response.StatusCode, "wrong HTTP result code") {
+		assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", response.Header.Get("Sec-WebSocket-Accept"), "wrong accept key")
+	}
+	return conn, reader, response
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	for _, size := range []int{0, 5, 300, 70000} {
+		var buf bytes.Buffer
+		payload := bytes.Repeat([]byte("a"), size)
+		assert.NoError(t, writeWebSocketFrame(&buf, wsOpText, payload))
+		raw := append([]byte(nil), buf.Bytes()...)
+
+		frame, err := readWebSocketFrame(&buf)
+		if assert.NoError(t, err) {
+			assert.Equal(t, wsOpText, frame.opcode)
+			assert.Equal(t, int64(size), frame.size, "wrong size of frame")
+			if size > maxWebSocketPayload {
+				assert.Equal(t, payload[:maxWebSocketPayload], frame.payload, "only recorded payload should be read")
+			} else {
+				assert.Equal(t, payload, frame.payload)
+			}
+			rest, _ := ioutil.ReadAll(frame.rest)
+			assert.Equal(t, raw, append(frame.raw, rest...), "raw frame should be kept as is")
+		}
+	}
+
+	// masked frame
+	client, server := net.Pipe()
+	go writeMaskedFrame(client, wsOpPing, []byte("ping"))
+	frame, err := readWebSocketFrame(server)
+	if assert.NoError(t, err) {
+		assert.Equal(t, wsOpPing, frame.opcode)
+		assert.Equal(t, "ping", string(frame.payload), "payload should be unmasked")
+		assert.Len(t, frame.raw, 10, "wrong size of raw frame")
+	}
+	client.Close()
+	server.Close()
+
+	// payload of huge frame is not allocated up front
+	huge := append([]byte{0x82, 127, 0, 0, 0, 0, 0x10, 0, 0, 0}, make([]byte, maxWebSocketPayload)...)
+	frame, err = readWebSocketFrame(bytes.NewReader(huge))
+	if assert.NoError(t, err) {
+		assert.Equal(t, int64(0x10000000), frame.size, "wrong size of frame")
+		assert.Len(t, frame.payload, maxWebSocketPayload, "only recorded payload should be read")
+	}
+
+	// invalid frames
+	_, err = readWebSocketFrame(bytes.NewReader([]byte{0x82, 127, 0x80, 0, 0, 0, 0, 0, 0, 0}))
+	assert.Error(t, err, "frame length is out of range")
+	_, err = readWebSocketFrame(bytes.NewReader(append([]byte{0x89, 126, 0, 200}, make([]byte, 200)...)))
+	assert.Error(t, err, "control frame is too big")
+}
+
+func TestGetWebSocketTarget(t *testing.T) {
+	assert.Nil(t, getWebSocketTarget(BasketConfig{ForwardURL: "http://localhost/ws"}))
+
+	config := BasketConfig{ForwardURL: "http://localhost/api", ForwardTargets: []ForwardTarget{
+		{URL: "ws://localhost:81/off", Disabled: true},
+		{URL: "wss://localhost:82/ws", InsecureTLS: true}}}
+	if target := getWebSocketTarget(config); assert.NotNil(t, target) {
+		assert.Equal(t, "wss://localhost:82/ws", target.URL)
+		assert.True(t, target.InsecureTLS)
+	}
+
+	targets := getForwardTargets(config)
+	if assert.Len(t, targets, 1, "WebSocket targets should not be used for HTTP forwarding") {
+		assert.Equal(t, "http://localhost/api", targets[0].URL)
+	}
+}
+
+func TestAcceptBasketRequests_WebSocket(t *testing.T) {
+	basket := "websocket01"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, WebSocket: true})
+
+	server := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer server.Close()
+
+	conn, reader, response := dialWebSocket(t, server.URL, "/r/"+basket+"/feed?v=1")
+	if conn == nil || response.StatusCode != 101 {
+		return
+	}
+	defer conn.Close()
+	assert.Equal(t, "chat", response.Header.Get("Sec-WebSocket-Protocol"), "wrong sub-protocol")
+
+	assert.NoError(t, writeMaskedFrame(conn, wsOpText, []byte("hello")))
+	assert.NoError(t, writeMaskedFrame(conn, wsOpPing, []byte("p")))
+	if frame, err := readWebSocketFrame(reader); assert.NoError(t, err) {
+		assert.Equal(t, wsOpPong, frame.opcode, "pong is expected")
+		assert.Equal(t, "p", string(frame.payload))
+	}
+
+	assert.NoError(t, writeMaskedFrame(conn, wsOpClose, []byte{0x03, 0xE8}))
+	if frame, err := readWebSocketFrame(reader); assert.NoError(t, err) {
+		assert.Equal(t, wsOpClose, frame.opcode, "close is expected")
+	}
+
+	b := basketsDb.Get(basket)
+	assert.Eventually(t, func() bool {
+		sessions := b.GetWebSocketSessions()
+		return len(sessions) == 1 && sessions[0].Closed > 0
+	}, 3*time.Second, 20*time.Millisecond, "closed session is expected")
+
+	session := b.GetWebSocketSessions()[0]
+	assert.Equal(t, "/r/"+basket+"/feed", session.Path, "wrong path")
+	assert.Equal(t, "v=1", session.Query, "wrong query")
+	assert.Equal(t, 5, session.FrameCount, "wrong number of frames")
+	if assert.Len(t, session.Frames, 5, "wrong number of recorded frames") {
+		assert.Equal(t, WebSocketFrame{Direction: WebSocketInbound, Opcode: wsOpText, Payload: "hello", Size: 5,
+			Date: session.Frames[0].Date}, session.Frames[0])
+		assert.Equal(t, WebSocketOutbound, session.Frames[2].Direction, "wrong direction of pong")
+		assert.True(t, session.Frames[3].Base64, "close payload should be base64 encoded")
+	}
+	assert.Equal(t, 0, b.Size(), "WebSocket handshake should not be collected as request")
+}
+
+func TestAcceptBasketRequests_WebSocketBigFrame(t *testing.T) {
+	basket := "websocket07"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, WebSocket: true})
+
+	server := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer server.Close()
+
+	conn, reader, response := dialWebSocket(t, server.URL, "/r/"+basket)
+	if conn == nil || response.StatusCode != 101 {
+		return
+	}
+	defer conn.Close()
+
+	// the rest of big frame is discarded and the session is still usable
+	assert.NoError(t, writeMaskedFrame(conn, wsOpText, bytes.Repeat([]byte("x"), 100000)))
+	assert.NoError(t, writeMaskedFrame(conn, wsOpPing, []byte("p")))
+	if frame, err := readWebSocketFrame(reader); assert.NoError(t, err) {
+		assert.Equal(t, wsOpPong, frame.opcode, "pong is expected")
+		assert.Equal(t, "p", string(frame.payload))
+	}
+
+	b := basketsDb.Get(basket)
+	assert.Eventually(t, func() bool {
+		sessions := b.GetWebSocketSessions()
+		return len(sessions) == 1 && sessions[0].FrameCount == 3
+	}, 3*time.Second, 20*time.Millisecond, "recorded frames are expected")
+
+	frame := b.GetWebSocketSessions()[0].Frames[0]
+	assert.Equal(t, 100000, frame.Size, "wrong size of frame")
+	assert.Len(t, frame.Payload, maxWebSocketPayload, "wrong size of recorded payload")
+	assert.True(t, frame.Truncated, "recorded payload should be truncated")
+}
+
+func TestWebSocketIdleTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// deadline is extended on every read
+	reader := &idleReader{reader: server, conn: server, timeout: 200 * time.Millisecond}
+	go func() {
+		for i := 0; i < 3; i++ {
+			time.Sleep(100 * time.Millisecond)
+			client.Write([]byte("x"))
+		}
+	}()
+	buf := make([]byte, 1)
+	for i := 0; i < 3; i++ {
+		_, err := reader.Read(buf)
+		assert.NoError(t, err, "active peer should not time out")
+	}
+	_, err := reader.Read(buf)
+	if assert.Error(t, err, "silent peer should time out") {
+		assert.True(t, err.(net.Error).Timeout(), "timeout error is expected")
+	}
+
+	// stalled peer does not block writer forever
+	writer := &idleWriter{conn: server, timeout: 200 * time.Millisecond}
+	_, err = writer.Write([]byte("y"))
+	if assert.Error(t, err, "stalled peer should time out") {
+		assert.True(t, err.(net.Error).Timeout(), "timeout error is expected")
+	}
+}
+
+func TestAcceptBasketRequests_WebSocketProxy(t *testing.T) {
+	basket := "websocket02"
+
+	var upstreamPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPath = r.URL.Path
+		conn, bufrw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+
+		bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n" +
+			"Sec-WebSocket-Protocol: chat\r\n\r\n")
+		bufrw.Flush()
+
+		for {
+			frame, err := readWebSocketFrame(bufrw.Reader)
+			if err != nil {
+				return
+			}
+			if frame.opcode == wsOpClose {
+				writeWebSocketFrame(conn, wsOpClose, frame.payload)
+				return
+			}
+			writeWebSocketFrame(conn, frame.opcode, append([]byte("echo: "), frame.payload...))
+		}
+	}))
+	defer upstream.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, WebSocket: true,
+		ForwardURL: "ws://" + upstream.Listener.Addr().String() + "/upstream", ExpandPath: true})
+
+	server := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer server.Close()
+
+	conn, reader, response := dialWebSocket(t, server.URL, "/r/"+basket+"/chat")
+	if conn == nil || response.StatusCode != 101 {
+		return
+	}
+	defer conn.Close()
+	assert.Equal(t, "chat", response.Header.Get("Sec-WebSocket-Protocol"), "wrong sub-protocol")
+
+	assert.NoError(t, writeMaskedFrame(conn, wsOpText, []byte("hi")))
+	if frame, err := readWebSocketFrame(reader); assert.NoError(t, err) {
+		assert.Equal(t, "echo: hi", string(frame.payload), "wrong proxied frame")
+	}
+	assert.NoError(t, writeMaskedFrame(conn, wsOpClose, nil))
+	readWebSocketFrame(reader)
+
+	assert.Equal(t, "/upstream/r/"+basket+"/chat", upstreamPath, "wrong upstream path")
+
+	b := basketsDb.Get(basket)
+	assert.Eventually(t, func() bool {
+		sessions := b.GetWebSocketSessions()
+		return len(sessions) == 1 && sessions[0].Closed > 0
+	}, 3*time.Second, 20*time.Millisecond, "closed session is expected")
+
+	session := b.GetWebSocketSessions()[0]
+	assert.Equal(t, "ws://"+upstream.Listener.Addr().String()+"/upstream", session.Target, "wrong target")
+	if assert.Len(t, session.Frames, 4, "wrong number of recorded frames") {
+		assert.Equal(t, "hi", session.Frames[0].Payload)
+		assert.Equal(t, WebSocketInbound, session.Frames[0].Direction)
+		assert.Equal(t, "echo: hi", session.Frames[1].Payload)
+		assert.Equal(t, WebSocketOutbound, session.Frames[1].Direction)
+	}
+}
+
+func TestAcceptBasketRequests_WebSocketProxyBigFrame(t *testing.T) {
+	basket := "websocket08"
+
+	payload := bytes.Repeat([]byte("0123456789"), 10000)
+	received := make(chan bool, 1)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, bufrw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+
+		bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		bufrw.Flush()
+
+		var raw bytes.Buffer
+		writeMaskedFrame(&raw, wsOpText, payload)
+		data := make([]byte, raw.Len())
+		io.ReadFull(bufrw.Reader, data)
+		received <- bytes.Equal(raw.Bytes(), data)
+		writeWebSocketFrame(conn, wsOpText, payload)
+		readWebSocketFrame(bufrw.Reader)
+	}))
+	defer upstream.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, WebSocket: true,
+		ForwardURL: "ws://" + upstream.Listener.Addr().String()})
+
+	server := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer server.Close()
+
+	conn, reader, response := dialWebSocket(t, server.URL, "/r/"+basket)
+	if conn == nil || response.StatusCode != 101 {
+		return
+	}
+	defer conn.Close()
+
+	assert.NoError(t, writeMaskedFrame(conn, wsOpText, payload))
+	select {
+	case same := <-received:
+		assert.True(t, same, "big frame should be relayed as is")
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "big frame is not relayed to target")
+	}
+
+	if frame, err := readWebSocketFrame(reader); assert.NoError(t, err) {
+		assert.Equal(t, int64(len(payload)), frame.size, "wrong size of relayed frame")
+		rest, _ := ioutil.ReadAll(frame.rest)
+		assert.Equal(t, payload, append(frame.payload, rest...), "big frame should be relayed to the client")
+	}
+	writeMaskedFrame(conn, wsOpClose, nil)
+}
+
+func TestAcceptBasketRequests_WebSocketThroughProxy(t *testing.T) {
+	basket := "websocket05"
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, bufrw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+
+		bufrw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+			"Sec-WebSocket-Accept: " + webSocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n\r\n")
+		bufrw.Flush()
+
+		for {
+			frame, err := readWebSocketFrame(bufrw.Reader)
+			if err != nil || frame.opcode == wsOpClose {
+				return
+			}
+			writeWebSocketFrame(conn, frame.opcode, append([]byte("echo: "), frame.payload...))
+		}
+	}))
+	defer upstream.Close()
+
+	connected := make(chan string, 1)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect || r.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		connected <- r.Host
+
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+		conn, bufrw, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+
+		bufrw.WriteString("HTTP/1.1 200 Connection Established\r\n\r\n")
+		bufrw.Flush()
+		go io.Copy(target, bufrw)
+		io.Copy(conn, target)
+	}))
+	defer proxy.Close()
+
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, WebSocket: true,
+		ForwardURL: "ws://" + upstream.Listener.Addr().String(),
+		HTTPClient: &HTTPClientConfig{Proxy: "http://user:pass@" + proxy.Listener.Addr().String()}})
+
+	server := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer server.Close()
+
+	conn, reader, response := dialWebSocket(t, server.URL, "/r/"+basket)
+	if conn == nil || response.StatusCode != 101 {
+		return
+	}
+	defer conn.Close()
+
+	select {
+	case host := <-connected:
+		assert.Equal(t, upstream.Listener.Addr().String(), host, "wrong tunnel target")
+	case <-time.After(3 * time.Second):
+		assert.Fail(t, "tunnel through proxy is expected")
+	}
+
+	assert.NoError(t, writeMaskedFrame(conn, wsOpText, []byte("hi")))
+	if frame, err := readWebSocketFrame(reader); assert.NoError(t, err) {
+		assert.Equal(t, "echo: hi", string(frame.payload), "wrong proxied frame")
+	}
+	assert.NoError(t, writeMaskedFrame(conn, wsOpClose, nil))
+}
+
+func TestAcceptBasketRequests_WebSocketSocksProxy(t *testing.T) {
+	basket := "websocket06"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, WebSocket: true, ForwardURL: "ws://localhost:1/ws",
+		HTTPClient: &HTTPClientConfig{Proxy: "socks5://localhost:1080"}})
+
+	server := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		conn.Write([]byte("GET /r/" + basket + " HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\n" +
+			"Connection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+		response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 502, response.StatusCode, "wrong HTTP result code")
+		}
+	}
+}
+
+func TestAcceptBasketRequests_WebSocketBadGateway(t *testing.T) {
+	basket := "websocket03"
+	basketsDb.Create(basket, BasketConfig{Capacity: 20, WebSocket: true, ForwardURL: "ws://localhost:1/ws"})
+
+	server := httptest.NewServer(http.HandlerFunc(AcceptBasketRequests))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if assert.NoError(t, err) {
+		defer conn.Close()
+		conn.Write([]byte("GET /r/" + basket + " HTTP/1.1\r\nHost: localhost\r\nUpgrade: websocket\r\n" +
+			"Connection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+		response, err := http.ReadResponse(bufio.NewReader(conn), nil)
+		if assert.NoError(t, err) {
+			assert.Equal(t, 502, response.StatusCode, "wrong HTTP result code")
+		}
+	}
+	assert.Empty(t, basketsDb.Get(basket).GetWebSocketSessions(), "session is not expected")
+}
+
+func TestGetBasketWebSockets(t *testing.T) {
+	basket := "websocket04"
+	auth, _ := basketsDb.Create(basket, BasketConfig{Capacity: 20})
+	b := basketsDb.Get(basket)
+	b.SaveWebSocketSession(&WebSocketSession{ID: "s1", Date: 1, FrameCount: 1,
+		Frames: []WebSocketFrame{{Direction: WebSocketInbound, Opcode: wsOpText, Payload: "hello", Size: 5}}})
+
+	ps := append(make(httprouter.Params, 0), httprouter.Param{Key: "basket", Value: basket})
+	r, err := http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/websockets", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		GetBasketWebSockets(w, r, ps)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+
+		var sessions []*WebSocketSession
+		if assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &sessions)) && assert.Len(t, sessions, 1) {
+			assert.Equal(t, "s1", sessions[0].ID, "wrong session")
+			assert.Equal(t, 1, sessions[0].FrameCount, "wrong frame count")
+			assert.Empty(t, sessions[0].Frames, "frames are not expected in list of sessions")
+		}
+	}
+
+	ps = append(ps, httprouter.Param{Key: "id", Value: "s1"})
+	r, err = http.NewRequest("GET", "http://localhost:55555/api/baskets/"+basket+"/websockets/s1", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		GetBasketWebSocket(w, r, ps)
+		assert.Equal(t, 200, w.Code, "wrong HTTP result code")
+		assert.Contains(t, w.Body.String(), "\"payload\":\"hello\"", "frames are expected")
+	}
+
+	r, err = http.NewRequest("DELETE", "http://localhost:55555/api/baskets/"+basket+"/websockets/s1", strings.NewReader(""))
+	if assert.NoError(t, err) {
+		r.Header.Add("Authorization", auth.Token)
+		w := httptest.NewRecorder()
+		DeleteBasketWebSocket(w, r, ps)
+		assert.Equal(t, 204, w.Code, "wrong HTTP result code")
+
+		w = httptest.NewRecorder()
+		GetBasketWebSocket(w, r, ps)
+		assert.Equal(t, 404, w.Code, "wrong HTTP result code")
+	}
+}